@@ -17,22 +17,46 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"flag"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/spf13/pflag"
 
 	_ "k8s.io/node-problem-detector/cmd/nodeproblemdetector/problemdaemonplugins"
 	"k8s.io/node-problem-detector/cmd/options"
+	"k8s.io/node-problem-detector/pkg/checkpoint"
 	"k8s.io/node-problem-detector/pkg/exporters/k8sexporter"
 	"k8s.io/node-problem-detector/pkg/exporters/prometheusexporter"
 	"k8s.io/node-problem-detector/pkg/problemdaemon"
 	"k8s.io/node-problem-detector/pkg/problemdetector"
+	"k8s.io/node-problem-detector/pkg/pushapi"
+	"k8s.io/node-problem-detector/pkg/server"
 	"k8s.io/node-problem-detector/pkg/types"
 	"k8s.io/node-problem-detector/pkg/version"
 )
 
+const (
+	// maxRecordedEvents is the number of recent events served by the node problem detector server.
+	maxRecordedEvents = 100
+	// maxRecordedStatuses is the number of recent statuses a stream can be resumed from.
+	maxRecordedStatuses = 1000
+)
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case validateCommand:
+			os.Exit(validate(os.Args[2:], os.Stdout, os.Stderr))
+		case replayCommand:
+			os.Exit(replay(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		}
+	}
+
 	npdo := options.NewNodeProblemDetectorOptions()
 	npdo.AddFlags(pflag.CommandLine)
 
@@ -47,11 +71,26 @@ func main() {
 	npdo.SetConfigFromDeprecatedOptionsOrDie()
 	npdo.ValidOrDie()
 
+	// Catch SIGHUP before initializing problem daemons, so that it won't terminate node
+	// problem detector, and record the configurations the problem daemons are created with.
+	reloadSignals := make(chan os.Signal, 1)
+	signal.Notify(reloadSignals, syscall.SIGHUP)
+	configWatcher := problemdaemon.NewConfigWatcher(npdo.MonitorConfigPaths, npdo.ConfigDir)
+
+	// Set up checkpointing before problem daemons restore their conditions and log positions.
+	checkpoint.SetUpGlobalConditionCheckpointManagerOrDie(npdo.CheckpointDir, npdo.CheckpointMaxAge)
+	checkpoint.SetUpGlobalWatcherCheckpointManagerOrDie(npdo.CheckpointDir)
+
 	// Initialize problem daemons.
-	problemDaemons := problemdaemon.NewProblemDaemons(npdo.MonitorConfigPaths)
+	problemDaemons, err := problemdaemon.NewProblemDaemons(configWatcher.ConfigPaths())
+	if err != nil {
+		glog.Fatalf("Failed to create problem daemons: %v", err)
+	}
 	if len(problemDaemons) == 0 {
 		glog.Fatalf("No problem daemon is configured")
 	}
+	// The load failures are reported by a single monitor.
+	failures := problemdaemon.NewConfigLoadFailures(problemDaemons)
 
 	// Initialize exporters.
 	exporters := []types.Exporter{}
@@ -66,10 +105,112 @@ func main() {
 	if len(exporters) == 0 {
 		glog.Fatalf("No exporter is successfully setup")
 	}
+	if npdo.ExporterQueueSize > 0 {
+		policy := types.OverflowPolicy(npdo.ExporterQueueOverflowPolicy)
+		for i := range exporters {
+			exporters[i] = problemdetector.NewExporterQueue(exporters[i], npdo.ExporterQueueSize, policy)
+		}
+	}
+
+	// Record the statuses to be served by the node problem detector server. Recording is fast,
+	// so the recorder is not queued.
+	var recorder *server.StatusRecorder
+	if npdo.ServerPort > 0 {
+		recorder = server.NewStatusRecorder(maxRecordedEvents, maxRecordedStatuses)
+		exporters = append(exporters, recorder)
+	}
 
 	// Initialize NPD core.
-	p := problemdetector.NewProblemDetector(problemDaemons, exporters)
-	if err := p.Run(); err != nil {
+	ctx := contextWithSignals()
+	newProblemDaemon := func(configPath string) (types.Monitor, error) {
+		return problemdaemon.NewProblemDaemon(configWatcher.ConfigPaths(), configPath)
+	}
+	var merger *problemdetector.ConditionMerger
+	if npdo.ConditionMergePolicy != "" {
+		merger = problemdetector.NewConditionMerger(types.MergePolicy(npdo.ConditionMergePolicy), npdo.ConditionOwners)
+	}
+	p := problemdetector.NewProblemDetector(problemDaemons, newProblemDaemon, exporters, merger)
+	if recorder != nil {
+		server.NewServer(npdo, recorder, configWatcher.ConfigPaths, p.Heartbeats).Start()
+	}
+	if npdo.PushSocket != "" {
+		// The external problem daemons are started by the problem detector once they push statuses.
+		if err := pushapi.NewServer(npdo.PushSocket, p).Start(); err != nil {
+			glog.Fatalf("Failed to start push API: %v", err)
+		}
+	}
+	go reloadProblemDaemons(ctx, p, configWatcher, failures, npdo.ConfigReloadInterval, reloadSignals)
+	if err := p.Run(ctx); err != nil {
 		glog.Fatalf("Problem detector failed with error: %v", err)
 	}
+	glog.Flush()
+}
+
+// parseSubcommandFlags parses the flags of a subcommand, together with the glog flags.
+func parseSubcommandFlags(fs *pflag.FlagSet, args []string) error {
+	fs.AddGoFlagSet(flag.CommandLine)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	// Mark the go flags as parsed, so that glog doesn't complain about logging before flag.Parse.
+	return flag.CommandLine.Parse(nil)
+}
+
+// contextWithSignals returns a context which is cancelled when node problem detector
+// receives SIGTERM or SIGINT.
+func contextWithSignals() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		sig := <-signals
+		glog.Infof("Received signal %v, shutting down", sig)
+		cancel()
+	}()
+	return ctx
+}
+
+// reloadProblemDaemons recreates the problem daemons whose configuration files changed, and stops
+// the problem daemons whose configuration files are removed. A problem daemon keeps running with
+// the previous configuration if the changed one fails to load, and the failure is reported next
+// to it. The configuration files are checked every interval, and whenever a reload signal is
+// received.
+func reloadProblemDaemons(ctx context.Context, p problemdetector.ProblemDetector, configWatcher *problemdaemon.ConfigWatcher,
+	failures *problemdaemon.ConfigLoadFailures, interval time.Duration, reloadSignals <-chan os.Signal) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-reloadSignals:
+			glog.Infof("Received signal %v, reloading configurations", sig)
+		case <-tick:
+		}
+		changed, removed := configWatcher.Changed()
+		monitors := make(map[string]types.Monitor)
+		if len(changed) != 0 {
+			for problemDaemonType, configs := range changed {
+				glog.Infof("Reloading %v with changed configurations %v", problemDaemonType, *configs)
+			}
+			// The problem daemons which fail to be created are reported by the returned monitors,
+			// so the error that none of them is created can be ignored.
+			monitors, _ = problemdaemon.NewProblemDaemons(changed)
+		}
+		failures.Update(monitors, removed)
+		if len(removed) != 0 {
+			glog.Infof("Stopping problem daemons with removed configurations %v", removed)
+			p.RemoveMonitors(removed)
+		}
+		if len(monitors) == 0 {
+			continue
+		}
+		if err := p.ReloadMonitors(monitors); err != nil {
+			glog.Errorf("Failed to reload problem daemons: %v", err)
+		}
+	}
 }
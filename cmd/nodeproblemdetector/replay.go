@@ -0,0 +1,107 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"k8s.io/node-problem-detector/pkg/systemlogmonitor"
+	"k8s.io/node-problem-detector/pkg/systemlogmonitor/logparsers"
+)
+
+// replayCommand is the subcommand which replays a captured log through a log monitor.
+const replayCommand = "replay"
+
+// replay replays the captured log file through a system log monitor, and prints the statuses it
+// reports as JSON, one per line. The log is read from stdin if no log file or "-" is passed in.
+// It returns the exit code.
+func replay(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := pflag.NewFlagSet(replayCommand, pflag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s %s --config=<config file> [flags] [log file]\n", os.Args[0], replayCommand)
+		fs.PrintDefaults()
+	}
+	config := fs.String("config", "", "The system log monitor configuration file to replay the log with.")
+	format := fs.String("format", string(logparsers.Kmsg),
+		fmt.Sprintf("The format of the log file, one of %v. Use kmsg for both /dev/kmsg records and dmesg output, "+
+			"and journald for the output of `journalctl -o json`.", logparsers.Formats))
+	bootTime := fs.String("boot-time", "",
+		"The boot time in RFC3339 format, which kmsg timestamps are relative to. Default to the Unix epoch.")
+	year := fs.Int("year", time.Now().Year(), "The year of syslog timestamps without year.")
+	startTime := fs.String("start-time", "",
+		"The time node-problem-detector is assumed to start at in RFC3339 format. The logs before it are only replayed "+
+			"within the lookback and delay of the configuration. Default to replaying all logs.")
+	if err := parseSubcommandFlags(fs, args); err != nil {
+		return 2
+	}
+	if *config == "" || fs.NArg() > 1 {
+		fs.Usage()
+		return 2
+	}
+
+	options := systemlogmonitor.ReplayOptions{
+		Format:       logparsers.Format(*format),
+		ParseOptions: logparsers.Options{BootTime: time.Unix(0, 0), Year: *year},
+	}
+	if *bootTime != "" {
+		t, err := time.Parse(time.RFC3339Nano, *bootTime)
+		if err != nil {
+			fmt.Fprintf(stderr, "Invalid boot time %q: %v\n", *bootTime, err)
+			return 2
+		}
+		options.ParseOptions.BootTime = t
+	}
+	if *startTime != "" {
+		t, err := time.Parse(time.RFC3339Nano, *startTime)
+		if err != nil {
+			fmt.Fprintf(stderr, "Invalid start time %q: %v\n", *startTime, err)
+			return 2
+		}
+		options.StartTime = t
+	}
+
+	r := stdin
+	if path := fs.Arg(0); path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to open log file: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		r = f
+	}
+	statuses, err := systemlogmonitor.ReplayLogs(*config, r, options)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to replay log with %q: %v\n", *config, err)
+		return 1
+	}
+	encoder := json.NewEncoder(stdout)
+	for _, status := range statuses {
+		if err := encoder.Encode(status); err != nil {
+			fmt.Fprintf(stderr, "Failed to print status: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
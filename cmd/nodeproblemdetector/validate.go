@@ -0,0 +1,139 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/pflag"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"k8s.io/node-problem-detector/cmd/options"
+	"k8s.io/node-problem-detector/pkg/problemdaemon"
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+// validateCommand is the subcommand which validates the configurations without starting
+// node problem detector.
+const validateCommand = "validate"
+
+// validate validates the problem daemon configuration files specified with the same flags as
+// node problem detector, and the configuration files passed in as arguments, whose problem
+// daemon types are specified in the files. It prints all errors found, and returns the exit code.
+func validate(args []string, stdout, stderr io.Writer) int {
+	fs := pflag.NewFlagSet(validateCommand, pflag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s %s [flags] [config files...]\n", os.Args[0], validateCommand)
+		fs.PrintDefaults()
+	}
+	npdo := options.NewNodeProblemDetectorOptions()
+	npdo.AddFlags(fs)
+	if err := parseSubcommandFlags(fs, args); err != nil {
+		return 2
+	}
+
+	// The options errors are counted separately from the invalid configurations.
+	invalidOptions := false
+	invalid, total := 0, 0
+	report := func(source string, err error) {
+		if agg, ok := err.(utilerrors.Aggregate); ok {
+			for _, e := range agg.Errors() {
+				fmt.Fprintf(stderr, "%s: %v\n", source, e)
+			}
+			return
+		}
+		fmt.Fprintf(stderr, "%s: %v\n", source, err)
+	}
+
+	if err := recoverPanic(npdo.SetConfigFromDeprecatedOptionsOrDie); err != nil {
+		invalidOptions = true
+		report("options", err)
+	}
+	// Configuration files passed in as arguments are enough without any configuration flag.
+	if fs.NArg() == 0 {
+		if err := recoverPanic(npdo.ValidOrDie); err != nil {
+			invalidOptions = true
+			report("options", err)
+		}
+	}
+
+	configs := types.ProblemDaemonConfigPathMap{}
+	for problemDaemonType, paths := range npdo.MonitorConfigPaths {
+		for _, path := range *paths {
+			problemdaemon.AppendConfigPath(configs, problemDaemonType, path)
+		}
+	}
+	var typedConfigs []string
+	if npdo.ConfigDir != "" {
+		dirConfigs, err := problemdaemon.ListConfigDir(npdo.ConfigDir)
+		if err != nil {
+			invalidOptions = true
+			report(npdo.ConfigDir, err)
+		}
+		typedConfigs = append(typedConfigs, dirConfigs...)
+	}
+	typedConfigs = append(typedConfigs, fs.Args()...)
+	for _, path := range typedConfigs {
+		problemDaemonType, err := problemdaemon.ConfigType(path)
+		if err != nil {
+			invalid++
+			total++
+			report(path, err)
+			continue
+		}
+		problemdaemon.AppendConfigPath(configs, problemDaemonType, path)
+	}
+
+	for problemDaemonType, paths := range configs {
+		for _, path := range *paths {
+			total++
+			if err := problemdaemon.ValidateConfig(problemDaemonType, path); err != nil {
+				invalid++
+				report(path, err)
+			}
+		}
+	}
+	if invalidOptions {
+		fmt.Fprintln(stderr, "Found errors in options")
+	}
+	if invalid != 0 {
+		fmt.Fprintf(stderr, "Found errors in %d of %d configurations\n", invalid, total)
+	}
+	if invalidOptions || invalid != 0 {
+		return 1
+	}
+	if total == 0 {
+		fmt.Fprintln(stderr, "No configuration file to validate")
+		return 1
+	}
+	fmt.Fprintf(stdout, "All %d configuration files are valid\n", total)
+	return 0
+}
+
+// recoverPanic calls f and returns the panic as an error.
+func recoverPanic(f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	f()
+	return nil
+}
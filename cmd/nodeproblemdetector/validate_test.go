@@ -0,0 +1,124 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	validConfig = `{
+	"problemDaemonType": "system-log-monitor",
+	"plugin": "filelog",
+	"pluginConfig": {"timestamp": "^.{15}", "message": "kernel: \\[.*\\] (.*)", "timestampFormat": "Jan _2 15:04:05"},
+	"logPath": "/var/log/kern.log",
+	"source": "kernel-monitor",
+	"rules": [{"type": "temporary", "reason": "OOMKilling", "pattern": "Kill process \\d+"}]
+}`
+	invalidPatternConfig = `{
+	"problemDaemonType": "system-log-monitor",
+	"plugin": "filelog",
+	"source": "kernel-monitor",
+	"rules": [{"type": "temporary", "reason": "OOMKilling", "pattern": "Kill process ("}]
+}`
+	untypedConfig = `{
+	"plugin": "filelog",
+	"source": "kernel-monitor"
+}`
+)
+
+func TestValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	configDir := filepath.Join(dir, "config.d")
+	assert.NoError(t, os.Mkdir(configDir, 0755))
+	for path, content := range map[string]string{
+		filepath.Join(dir, "valid.json"):                 validConfig,
+		filepath.Join(dir, "invalid-pattern.json"):       invalidPatternConfig,
+		filepath.Join(dir, "untyped.json"):               untypedConfig,
+		filepath.Join(configDir, "valid.json"):           validConfig,
+		filepath.Join(configDir, "invalid-pattern.json"): invalidPatternConfig,
+	} {
+		assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	testCases := []struct {
+		name     string
+		args     []string
+		exitCode int
+		stdout   string
+		stderr   []string
+	}{
+		{
+			name:     "valid config argument",
+			args:     []string{filepath.Join(dir, "valid.json")},
+			exitCode: 0,
+			stdout:   "All 1 configuration files are valid\n",
+		},
+		{
+			name:     "valid config flag",
+			args:     []string{"--config.system-log-monitor=" + filepath.Join(dir, "valid.json")},
+			exitCode: 0,
+			stdout:   "All 1 configuration files are valid\n",
+		},
+		{
+			name:     "invalid pattern",
+			args:     []string{filepath.Join(dir, "valid.json"), filepath.Join(dir, "invalid-pattern.json")},
+			exitCode: 1,
+			stderr:   []string{"invalid pattern", "Found errors in 1 of 2 configurations"},
+		},
+		{
+			name:     "unspecified problem daemon type",
+			args:     []string{filepath.Join(dir, "untyped.json")},
+			exitCode: 1,
+			stderr:   []string{"problemDaemonType is not specified", "Found errors in 1 of 1 configurations"},
+		},
+		{
+			name:     "config directory",
+			args:     []string{"--config-dir=" + configDir},
+			exitCode: 1,
+			stderr:   []string{"invalid pattern", "Found errors in 1 of 2 configurations"},
+		},
+		{
+			name:     "no configuration",
+			exitCode: 1,
+			stderr:   []string{"options: ", "Found errors in options"},
+		},
+		{
+			name:     "unknown flag",
+			args:     []string{"--foo"},
+			exitCode: 2,
+		},
+	}
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			assert.Equal(t, test.exitCode, validate(test.args, &stdout, &stderr), "stderr: %s", stderr.String())
+			assert.Equal(t, test.stdout, stdout.String())
+			for _, s := range test.stderr {
+				assert.Contains(t, stderr.String(), s)
+			}
+		})
+	}
+}
@@ -42,6 +42,14 @@ type NodeProblemDetectorOptions struct {
 	ServerPort int
 	// ServerAddress is the address to bind the node problem detector server.
 	ServerAddress string
+	// EnablePprof enables the pprof handlers on the node problem detector server.
+	EnablePprof bool
+	// PushSocket is the Unix socket path to serve the push API for external problem daemons on.
+	// The push API is disabled if it is empty.
+	PushSocket string
+	// MonitorStalenessThreshold is the time after which a problem daemon without a heartbeat is
+	// considered stale, which fails the health check. Use 0 to disable.
+	MonitorStalenessThreshold time.Duration
 
 	// exporter options
 
@@ -77,6 +85,29 @@ type NodeProblemDetectorOptions struct {
 	CustomPluginMonitorConfigPaths []string
 	// MonitorConfigPaths specifies the list of paths to configuration files for each monitor.
 	MonitorConfigPaths types.ProblemDaemonConfigPathMap
+	// ConfigDir is the configuration directory to load problem daemon configuration files from.
+	// The problem daemon type of each configuration file is specified in the file.
+	ConfigDir string
+	// ConfigReloadInterval is the interval at which configuration files are checked for changes.
+	// Problem daemons whose configuration files changed are reloaded. Use 0 to disable.
+	ConfigReloadInterval time.Duration
+	// ExporterQueueSize is the number of statuses each exporter can queue. Statuses are exported
+	// synchronously when it is 0.
+	ExporterQueueSize int
+	// ExporterQueueOverflowPolicy decides what to do with a new status when an exporter queue is full.
+	ExporterQueueOverflowPolicy string
+	// ConditionMergePolicy decides which condition is exported when several problem daemons report
+	// the same condition type. Conditions are exported as they are reported if it is empty.
+	ConditionMergePolicy string
+	// ConditionOwners maps condition types to the problem daemon sources owning them. The condition
+	// reported by the owner is always exported.
+	ConditionOwners map[string]string
+	// CheckpointDir is the directory to checkpoint the conditions of problem daemons and the
+	// positions of log watchers in, so that they are kept across restarts. Nothing is
+	// checkpointed if it is empty.
+	CheckpointDir string
+	// CheckpointMaxAge is the maximum age of a checkpoint to be restored. Use 0 to always restore.
+	CheckpointMaxAge time.Duration
 
 	// application options
 
@@ -112,12 +143,35 @@ func (npdo *NodeProblemDetectorOptions) AddFlags(fs *pflag.FlagSet) {
 		20256, "The port to bind the node problem detector server. Use 0 to disable.")
 	fs.StringVar(&npdo.ServerAddress, "address",
 		"127.0.0.1", "The address to bind the node problem detector server.")
+	fs.BoolVar(&npdo.EnablePprof, "enable-pprof", false,
+		"Enables the pprof handlers at /debug/pprof/ on the node problem detector server.")
+	fs.StringVar(&npdo.PushSocket, "push-socket", "",
+		"The Unix socket path to serve the push API for external problem daemons on, e.g. /run/node-problem-detector/push.sock. Use empty to disable.")
+	fs.DurationVar(&npdo.MonitorStalenessThreshold, "monitor-staleness-threshold", 0,
+		"The time after which a problem daemon without a heartbeat, e.g. no log line processed or no plugin result received, fails the /healthz check. Use 0 to disable.")
 
 	fs.IntVar(&npdo.PrometheusServerPort, "prometheus-port",
 		20257, "The port to bind the Prometheus scrape endpoint. Prometheus exporter is enabled by default at port 20257. Use 0 to disable.")
 	fs.StringVar(&npdo.PrometheusServerAddress, "prometheus-address",
 		"127.0.0.1", "The address to bind the Prometheus scrape endpoint.")
 
+	fs.StringVar(&npdo.ConfigDir, "config-dir", "",
+		"The directory to load problem daemon configuration files from, e.g. /etc/node-problem-detector/conf.d. Each configuration file specifies its problem daemon type in the \"problemDaemonType\" field.")
+	fs.DurationVar(&npdo.ConfigReloadInterval, "config-reload-interval", 30*time.Second,
+		"The interval at which problem daemon configuration files are checked for changes. Problem daemons whose configuration files changed are reloaded. Use 0 to disable, configuration files are still reloaded on SIGHUP.")
+	fs.IntVar(&npdo.ExporterQueueSize, "exporter-queue-size", 0,
+		"The number of problems each exporter can queue, so that a slow exporter doesn't delay the others. Default to 0, which exports synchronously.")
+	fs.StringVar(&npdo.ExporterQueueOverflowPolicy, "exporter-queue-overflow-policy", string(types.OverflowPolicyCoalesce),
+		fmt.Sprintf("What to do with a new problem when an exporter queue is full, one of %v.", types.OverflowPolicies))
+	fs.StringVar(&npdo.ConditionMergePolicy, "condition-merge-policy", "",
+		fmt.Sprintf("Which condition to export when several problem daemons report the same condition type, one of %v. Empty by default, which exports the conditions as they are reported.", types.MergePolicies))
+	fs.StringToStringVar(&npdo.ConditionOwners, "condition-owners", map[string]string{},
+		"Comma separated condition type to problem daemon source mapping, e.g. ReadonlyFilesystem=kernel-monitor. The condition reported by the owner source is always exported, regardless of --condition-merge-policy. Only takes effect with --condition-merge-policy.")
+	fs.StringVar(&npdo.CheckpointDir, "checkpoint-dir", "",
+		"The directory to checkpoint the conditions of problem daemons and the positions of log watchers in, e.g. /var/lib/node-problem-detector. The conditions are restored when node problem detector restarts, and the log watchers resume where they left off in the same boot. Use empty to disable.")
+	fs.DurationVar(&npdo.CheckpointMaxAge, "checkpoint-max-age", time.Hour,
+		"The maximum age of a checkpoint to be restored, older checkpoints are ignored. Use 0 to always restore.")
+
 	for _, problemDaemonName := range problemdaemon.GetProblemDaemonNames() {
 		fs.StringSliceVar(
 			npdo.MonitorConfigPaths[problemDaemonName],
@@ -147,9 +201,30 @@ func (npdo *NodeProblemDetectorOptions) ValidOrDie() {
 	for _, problemDaemonConfigPaths := range npdo.MonitorConfigPaths {
 		configCount += len(*problemDaemonConfigPaths)
 	}
-	if configCount == 0 {
+	if configCount == 0 && npdo.ConfigDir == "" {
 		panic("No configuration option for any problem daemon is specified.")
 	}
+
+	if npdo.ConditionMergePolicy != "" && !types.IsValidMergePolicy(types.MergePolicy(npdo.ConditionMergePolicy)) {
+		panic(fmt.Sprintf("condition-merge-policy %q is not one of %v",
+			npdo.ConditionMergePolicy, types.MergePolicies))
+	}
+
+	if npdo.MonitorStalenessThreshold < 0 {
+		panic(fmt.Sprintf("monitor-staleness-threshold %v should not be negative", npdo.MonitorStalenessThreshold))
+	}
+
+	if npdo.CheckpointMaxAge < 0 {
+		panic(fmt.Sprintf("checkpoint-max-age %v should not be negative", npdo.CheckpointMaxAge))
+	}
+
+	if npdo.ExporterQueueSize < 0 {
+		panic(fmt.Sprintf("exporter-queue-size %d should not be negative", npdo.ExporterQueueSize))
+	}
+	if npdo.ExporterQueueSize > 0 && !types.IsValidOverflowPolicy(types.OverflowPolicy(npdo.ExporterQueueOverflowPolicy)) {
+		panic(fmt.Sprintf("exporter-queue-overflow-policy %q is not one of %v",
+			npdo.ExporterQueueOverflowPolicy, types.OverflowPolicies))
+	}
 }
 
 // Plugin names for custom plugin monitor and system log monitor.
@@ -178,6 +178,14 @@ func TestValidOrDie(t *testing.T) {
 			},
 			expectPanic: true,
 		},
+		{
+			name: "empty MonitorConfigPaths with ConfigDir",
+			npdo: NodeProblemDetectorOptions{
+				MonitorConfigPaths: emptyMonitorConfigMap,
+				ConfigDir:          "/etc/node-problem-detector/conf.d",
+			},
+			expectPanic: false,
+		},
 		{
 			name:        "un-initialized MonitorConfigPaths",
 			npdo:        NodeProblemDetectorOptions{},
@@ -229,6 +237,48 @@ func TestValidOrDie(t *testing.T) {
 			},
 			expectPanic: true,
 		},
+		{
+			name: "exporter queue with valid overflow policy",
+			npdo: NodeProblemDetectorOptions{
+				MonitorConfigPaths:          fooMonitorConfigMap,
+				ExporterQueueSize:           10,
+				ExporterQueueOverflowPolicy: "drop-oldest",
+			},
+			expectPanic: false,
+		},
+		{
+			name: "exporter queue with invalid overflow policy",
+			npdo: NodeProblemDetectorOptions{
+				MonitorConfigPaths:          fooMonitorConfigMap,
+				ExporterQueueSize:           10,
+				ExporterQueueOverflowPolicy: "foo",
+			},
+			expectPanic: true,
+		},
+		{
+			name: "valid condition merge policy",
+			npdo: NodeProblemDetectorOptions{
+				MonitorConfigPaths:   fooMonitorConfigMap,
+				ConditionMergePolicy: "highest-severity",
+			},
+			expectPanic: false,
+		},
+		{
+			name: "invalid condition merge policy",
+			npdo: NodeProblemDetectorOptions{
+				MonitorConfigPaths:   fooMonitorConfigMap,
+				ConditionMergePolicy: "foo",
+			},
+			expectPanic: true,
+		},
+		{
+			name: "negative exporter queue size",
+			npdo: NodeProblemDetectorOptions{
+				MonitorConfigPaths: fooMonitorConfigMap,
+				ExporterQueueSize:  -1,
+			},
+			expectPanic: true,
+		},
 	}
 
 	for _, test := range testCases {
@@ -0,0 +1,180 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+// ConditionCheckpointRefreshInterval is the max interval between two writes of the checkpoint of a
+// problem daemon whose conditions don't change, so that the timestamp of the checkpoint tells when
+// the problem daemon was last running, not when its conditions last changed.
+const ConditionCheckpointRefreshInterval = time.Minute
+
+// GlobalConditionCheckpointManager is a singleton of ConditionCheckpointManager, which should
+// be used by all problem daemons to checkpoint their conditions. Checkpointing is disabled
+// until it is set up with SetUpGlobalConditionCheckpointManagerOrDie.
+var GlobalConditionCheckpointManager = NewConditionCheckpointManager("", 0, clock.RealClock{})
+
+// SetUpGlobalConditionCheckpointManagerOrDie enables checkpointing conditions into the
+// directory, panics if the directory can't be created. Checkpoints older than maxAge are
+// ignored, use 0 to never ignore checkpoints.
+func SetUpGlobalConditionCheckpointManagerOrDie(dir string, maxAge time.Duration) {
+	if dir == "" {
+		return
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		panic(fmt.Sprintf("Failed to create checkpoint directory %q: %v", dir, err))
+	}
+	GlobalConditionCheckpointManager = NewConditionCheckpointManager(dir, maxAge, clock.RealClock{})
+}
+
+// conditionCheckpoint is the on-disk checkpoint of the conditions of a problem daemon.
+type conditionCheckpoint struct {
+	// ConfigPath is the configuration file path of the problem daemon, which identifies the
+	// problem daemon. Several problem daemons may report the same source.
+	ConfigPath string `json:"configPath"`
+	// Timestamp is when the checkpoint is written. It's rewritten at least once per
+	// ConditionCheckpointRefreshInterval while the problem daemon keeps saving its conditions.
+	Timestamp  time.Time         `json:"timestamp"`
+	Conditions []types.Condition `json:"conditions"`
+}
+
+// ConditionCheckpointManager checkpoints the latest conditions of each problem daemon on disk,
+// keyed by the configuration file path of the problem daemon, so that problem daemons can restore
+// their conditions after node problem detector restarts. ConditionCheckpointManager is
+// thread-safe.
+type ConditionCheckpointManager struct {
+	dir    string
+	maxAge time.Duration
+	clock  clock.Clock
+	// saved are the conditions last saved for each configuration file path.
+	saved map[string][]types.Condition
+	// written is the time when the checkpoint of each configuration file path is last written.
+	written map[string]time.Time
+	sync.Mutex
+}
+
+// NewConditionCheckpointManager creates a condition checkpoint manager saving checkpoints into
+// the directory. Checkpointing is disabled if dir is empty.
+func NewConditionCheckpointManager(dir string, maxAge time.Duration, clock clock.Clock) *ConditionCheckpointManager {
+	return &ConditionCheckpointManager{
+		dir:     dir,
+		maxAge:  maxAge,
+		clock:   clock,
+		saved:   make(map[string][]types.Condition),
+		written: make(map[string]time.Time),
+	}
+}
+
+// Load returns the checkpointed conditions of the problem daemon loaded from configPath. Returns
+// nil if checkpointing is disabled, or there is no valid checkpoint of the problem daemon.
+func (m *ConditionCheckpointManager) Load(configPath string) []types.Condition {
+	if m.dir == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(m.path(configPath))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.Errorf("Failed to read condition checkpoint of %q: %v", configPath, err)
+		}
+		return nil
+	}
+	var checkpoint conditionCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		glog.Errorf("Failed to unmarshal condition checkpoint of %q: %v", configPath, err)
+		return nil
+	}
+	if checkpoint.ConfigPath != configPath {
+		glog.Errorf("Condition checkpoint of %q is for %q, ignoring it", configPath, checkpoint.ConfigPath)
+		return nil
+	}
+	if age := m.clock.Since(checkpoint.Timestamp); m.maxAge > 0 && age > m.maxAge {
+		glog.Infof("Condition checkpoint of %q is %v old, older than %v, ignoring it", configPath, age, m.maxAge)
+		return nil
+	}
+	glog.Infof("Condition checkpoint of %q loaded: %+v", configPath, checkpoint.Conditions)
+	return checkpoint.Conditions
+}
+
+// Save checkpoints the conditions of the problem daemon loaded from configPath. It does nothing
+// if checkpointing is disabled, or the conditions are the same as the ones last saved and the
+// checkpoint has been written within ConditionCheckpointRefreshInterval. Problem daemons should
+// keep saving their conditions even if they don't change, so that the checkpoint doesn't get
+// older than the max age while they are running.
+func (m *ConditionCheckpointManager) Save(configPath string, conditions []types.Condition) error {
+	if m.dir == "" {
+		return nil
+	}
+	m.Lock()
+	defer m.Unlock()
+	now := m.clock.Now()
+	if saved, ok := m.saved[configPath]; ok && reflect.DeepEqual(saved, conditions) &&
+		now.Sub(m.written[configPath]) < ConditionCheckpointRefreshInterval {
+		return nil
+	}
+	data, err := json.Marshal(&conditionCheckpoint{
+		ConfigPath: configPath,
+		Timestamp:  now,
+		Conditions: conditions,
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeFile(m.dir, m.path(configPath), data); err != nil {
+		return err
+	}
+	m.saved[configPath] = append([]types.Condition(nil), conditions...)
+	m.written[configPath] = now
+	return nil
+}
+
+// writeFile writes the data into a temporary file in the directory and renames it to the path,
+// so that the checkpoint is never partially written.
+func writeFile(dir string, path string, data []byte) error {
+	f, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// path returns the path of the checkpoint file of the problem daemon loaded from configPath.
+func (m *ConditionCheckpointManager) path(configPath string) string {
+	return filepath.Join(m.dir, "conditions-"+url.PathEscape(configPath)+".json")
+}
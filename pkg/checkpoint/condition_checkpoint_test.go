@@ -0,0 +1,87 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checkpoint
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+func TestConditionCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fakeClock := clock.NewFakeClock(time.Unix(1000, 0))
+	m := NewConditionCheckpointManager(dir, time.Hour, fakeClock)
+	conditions := []types.Condition{
+		{
+			Type:       "KernelDeadlock",
+			Status:     types.True,
+			Transition: time.Unix(500, 0).UTC(),
+			Reason:     "DockerHung",
+			Message:    "task docker:7 blocked for more than 120 seconds.",
+		},
+	}
+
+	assert.Nil(t, m.Load("config/kernel-monitor.json"), "No checkpoint should be loaded before saved")
+	assert.NoError(t, m.Save("config/kernel-monitor.json", conditions))
+	// Problem daemons reporting the same source are checkpointed separately.
+	assert.NoError(t, m.Save("config/kernel-monitor-filelog.json", nil))
+
+	// Load the checkpoints with a new manager, as node problem detector restarts.
+	m = NewConditionCheckpointManager(dir, time.Hour, fakeClock)
+	assert.Equal(t, conditions, m.Load("config/kernel-monitor.json"))
+	assert.Empty(t, m.Load("config/kernel-monitor-filelog.json"))
+	assert.Nil(t, m.Load("config/docker-monitor.json"), "No checkpoint should be loaded for other problem daemons")
+
+	fakeClock.Step(2 * time.Hour)
+	assert.Nil(t, m.Load("config/kernel-monitor.json"), "Checkpoint older than max age should be ignored")
+	m = NewConditionCheckpointManager(dir, 0, fakeClock)
+	assert.Equal(t, conditions, m.Load("config/kernel-monitor.json"), "Checkpoint should never expire without max age")
+}
+
+func TestConditionCheckpointRefresh(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fakeClock := clock.NewFakeClock(time.Unix(1000, 0))
+	m := NewConditionCheckpointManager(dir, time.Hour, fakeClock)
+	conditions := []types.Condition{{Type: "KernelDeadlock", Status: types.True, Reason: "DockerHung"}}
+
+	// The conditions don't change for longer than the max age while they keep being saved.
+	for i := 0; i < 90; i++ {
+		assert.NoError(t, m.Save("config/kernel-monitor.json", conditions))
+		fakeClock.Step(time.Minute)
+	}
+	m = NewConditionCheckpointManager(dir, time.Hour, fakeClock)
+	assert.Equal(t, conditions, m.Load("config/kernel-monitor.json"), "Unchanged conditions should still be restored")
+}
+
+func TestConditionCheckpointDisabled(t *testing.T) {
+	m := NewConditionCheckpointManager("", time.Hour, clock.RealClock{})
+	assert.NoError(t, m.Save("config/kernel-monitor.json", []types.Condition{{Type: "KernelDeadlock"}}))
+	assert.Nil(t, m.Load("config/kernel-monitor.json"))
+}
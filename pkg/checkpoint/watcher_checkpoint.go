@@ -0,0 +1,190 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"k8s.io/node-problem-detector/pkg/util"
+)
+
+// watcherCheckpointInterval is the min interval between two checkpoints of a log watcher, so that
+// the checkpoint is not written for every log line.
+const watcherCheckpointInterval = time.Second
+
+// GlobalWatcherCheckpointManager is a singleton of WatcherCheckpointManager, which should be used
+// by all log watchers to checkpoint their positions. Checkpointing is disabled until it is set up
+// with SetUpGlobalWatcherCheckpointManagerOrDie.
+var GlobalWatcherCheckpointManager = NewWatcherCheckpointManager("", "", clock.RealClock{})
+
+// SetUpGlobalWatcherCheckpointManagerOrDie enables checkpointing log watcher positions into the
+// directory, panics if the directory can't be created or the boot ID can't be read.
+func SetUpGlobalWatcherCheckpointManagerOrDie(dir string) {
+	if dir == "" {
+		return
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		panic(fmt.Sprintf("Failed to create checkpoint directory %q: %v", dir, err))
+	}
+	bootID, err := util.GetBootID()
+	if err != nil {
+		panic(fmt.Sprintf("Failed to get boot ID: %v", err))
+	}
+	GlobalWatcherCheckpointManager = NewWatcherCheckpointManager(dir, bootID, clock.RealClock{})
+}
+
+// watcherCheckpoint is the on-disk checkpoint of the position of a log watcher.
+type watcherCheckpoint struct {
+	Key       string          `json:"key"`
+	BootID    string          `json:"bootID"`
+	Timestamp time.Time       `json:"timestamp"`
+	Position  json.RawMessage `json:"position"`
+}
+
+// WatcherCheckpointManager checkpoints the positions of log watchers on disk, so that log
+// watchers can resume where they left off after node problem detector restarts, instead of
+// looking back. The positions are only restored in the same boot. The position of a log watcher
+// is written at most once per watcherCheckpointInterval, and the latest one is written when the
+// log watcher is flushed. WatcherCheckpointManager is thread-safe.
+type WatcherCheckpointManager struct {
+	dir    string
+	bootID string
+	clock  clock.Clock
+	// lastSaved is the time when the position of each log watcher is last written.
+	lastSaved map[string]time.Time
+	// pending are the positions of the log watchers which are not written yet.
+	pending map[string]interface{}
+	sync.Mutex
+}
+
+// NewWatcherCheckpointManager creates a watcher checkpoint manager saving checkpoints of the boot
+// into the directory. Checkpointing is disabled if dir is empty.
+func NewWatcherCheckpointManager(dir string, bootID string, clock clock.Clock) *WatcherCheckpointManager {
+	return &WatcherCheckpointManager{
+		dir:       dir,
+		bootID:    bootID,
+		clock:     clock,
+		lastSaved: make(map[string]time.Time),
+		pending:   make(map[string]interface{}),
+	}
+}
+
+// Load unmarshals the checkpointed position of the log watcher into position, and returns true
+// if it's loaded. Returns false if checkpointing is disabled, the key is empty, or there is no
+// valid checkpoint of the log watcher in the current boot.
+func (m *WatcherCheckpointManager) Load(key string, position interface{}) bool {
+	if m.dir == "" || key == "" {
+		return false
+	}
+	data, err := ioutil.ReadFile(m.path(key))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			glog.Errorf("Failed to read watcher checkpoint of %q: %v", key, err)
+		}
+		return false
+	}
+	var checkpoint watcherCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		glog.Errorf("Failed to unmarshal watcher checkpoint of %q: %v", key, err)
+		return false
+	}
+	if checkpoint.Key != key {
+		glog.Errorf("Watcher checkpoint of %q is for %q, ignoring it", key, checkpoint.Key)
+		return false
+	}
+	if checkpoint.BootID != m.bootID {
+		glog.Infof("Watcher checkpoint of %q is of boot %q, not the current boot %q, ignoring it",
+			key, checkpoint.BootID, m.bootID)
+		return false
+	}
+	if err := json.Unmarshal(checkpoint.Position, position); err != nil {
+		glog.Errorf("Failed to unmarshal watcher position of %q: %v", key, err)
+		return false
+	}
+	glog.Infof("Watcher checkpoint of %q loaded: %s", key, checkpoint.Position)
+	return true
+}
+
+// Save checkpoints the position of the log watcher. The position is only written if it hasn't
+// been written within watcherCheckpointInterval, or else it's written by the next Save or Flush.
+// It does nothing if checkpointing is disabled or the key is empty.
+func (m *WatcherCheckpointManager) Save(key string, position interface{}) error {
+	if m.dir == "" || key == "" {
+		return nil
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.pending[key] = position
+	if m.clock.Since(m.lastSaved[key]) < watcherCheckpointInterval {
+		return nil
+	}
+	return m.write(key)
+}
+
+// Flush writes the pending position of the log watcher, if any. It should be called when the log
+// watcher stops.
+func (m *WatcherCheckpointManager) Flush(key string) error {
+	if m.dir == "" || key == "" {
+		return nil
+	}
+	m.Lock()
+	defer m.Unlock()
+	return m.write(key)
+}
+
+// write writes the pending position of the log watcher. m must be locked.
+func (m *WatcherCheckpointManager) write(key string) error {
+	position, ok := m.pending[key]
+	if !ok {
+		return nil
+	}
+	data, err := json.Marshal(position)
+	if err != nil {
+		return err
+	}
+	now := m.clock.Now()
+	data, err = json.Marshal(&watcherCheckpoint{
+		Key:       key,
+		BootID:    m.bootID,
+		Timestamp: now,
+		Position:  data,
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeFile(m.dir, m.path(key), data); err != nil {
+		return err
+	}
+	delete(m.pending, key)
+	m.lastSaved[key] = now
+	return nil
+}
+
+// path returns the path of the checkpoint file of the log watcher.
+func (m *WatcherCheckpointManager) path(key string) string {
+	return filepath.Join(m.dir, "watcher-"+url.PathEscape(key)+".json")
+}
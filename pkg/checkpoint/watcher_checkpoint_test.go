@@ -0,0 +1,72 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package checkpoint
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+type testPosition struct {
+	Offset int `json:"offset"`
+}
+
+func TestWatcherCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fakeClock := clock.NewFakeClock(time.Unix(1000, 0))
+	m := NewWatcherCheckpointManager(dir, "boot-1", fakeClock)
+	var position testPosition
+	assert.False(t, m.Load("kernel-monitor", &position), "No checkpoint should be loaded before saved")
+
+	assert.NoError(t, m.Save("kernel-monitor", testPosition{Offset: 1}))
+	assert.NoError(t, m.Save("kernel/monitor", testPosition{Offset: 1}))
+	// The position saved within the checkpoint interval is not written until flushed.
+	assert.NoError(t, m.Save("kernel-monitor", testPosition{Offset: 2}))
+	assert.True(t, NewWatcherCheckpointManager(dir, "boot-1", fakeClock).Load("kernel-monitor", &position))
+	assert.Equal(t, testPosition{Offset: 1}, position)
+	assert.NoError(t, m.Flush("kernel-monitor"))
+
+	fakeClock.Step(watcherCheckpointInterval)
+	assert.NoError(t, m.Save("kernel/monitor", testPosition{Offset: 3}))
+
+	// Load the checkpoints with a new manager, as node problem detector restarts.
+	m = NewWatcherCheckpointManager(dir, "boot-1", fakeClock)
+	assert.True(t, m.Load("kernel-monitor", &position))
+	assert.Equal(t, testPosition{Offset: 2}, position)
+	assert.True(t, m.Load("kernel/monitor", &position))
+	assert.Equal(t, testPosition{Offset: 3}, position)
+	assert.False(t, m.Load("docker-monitor", &position), "No checkpoint should be loaded for other sources")
+
+	m = NewWatcherCheckpointManager(dir, "boot-2", fakeClock)
+	assert.False(t, m.Load("kernel-monitor", &position), "Checkpoint of another boot should be ignored")
+}
+
+func TestWatcherCheckpointDisabled(t *testing.T) {
+	m := NewWatcherCheckpointManager("", "boot-1", clock.RealClock{})
+	var position testPosition
+	assert.NoError(t, m.Save("kernel-monitor", testPosition{Offset: 1}))
+	assert.NoError(t, m.Flush("kernel-monitor"))
+	assert.False(t, m.Load("kernel-monitor", &position))
+}
@@ -17,12 +17,13 @@ limitations under the License.
 package custompluginmonitor
 
 import (
-	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"time"
 
 	"github.com/golang/glog"
 
+	"k8s.io/node-problem-detector/pkg/checkpoint"
 	"k8s.io/node-problem-detector/pkg/custompluginmonitor/plugin"
 	cpmtypes "k8s.io/node-problem-detector/pkg/custompluginmonitor/types"
 	"k8s.io/node-problem-detector/pkg/problemdaemon"
@@ -38,44 +39,36 @@ func init() {
 	problemdaemon.Register(
 		CustomPluginMonitorName,
 		types.ProblemDaemonHandler{
-			CreateProblemDaemonOrDie: NewCustomPluginMonitorOrDie,
-			CmdOptionDescription:     "Set to config file paths."})
+			CreateProblemDaemon:  NewCustomPluginMonitor,
+			ValidateConfig:       ValidateConfig,
+			CmdOptionDescription: "Set to config file paths."})
 }
 
 type customPluginMonitor struct {
 	configPath string
 	config     cpmtypes.CustomPluginConfig
 	conditions []types.Condition
-	plugin     *plugin.Plugin
-	resultChan <-chan cpmtypes.Result
-	statusChan chan *types.Status
-	tomb       *tomb.Tomb
+	// restoredConditions are the conditions to start with instead of the default conditions.
+	restoredConditions []types.Condition
+	plugin             *plugin.Plugin
+	resultChan         <-chan cpmtypes.Result
+	statusChan         chan *types.Status
+	tomb               *tomb.Tomb
+	// heartbeat is updated whenever a plugin result is received.
+	heartbeat util.HeartbeatTracker
 }
 
-// NewCustomPluginMonitorOrDie create a new customPluginMonitor, panic if error occurs.
-func NewCustomPluginMonitorOrDie(configPath string) types.Monitor {
+// NewCustomPluginMonitor creates a new customPluginMonitor, returns error if the configuration
+// file can't be loaded.
+func NewCustomPluginMonitor(configPath string) (types.Monitor, error) {
 	c := &customPluginMonitor{
 		configPath: configPath,
 		tomb:       tomb.NewTomb(),
 	}
-	f, err := ioutil.ReadFile(configPath)
-	if err != nil {
-		glog.Fatalf("Failed to read configuration file %q: %v", configPath, err)
-	}
-	err = json.Unmarshal(f, &c.config)
-	if err != nil {
-		glog.Fatalf("Failed to unmarshal configuration file %q: %v", configPath, err)
-	}
-	// Apply configurations
-	err = (&c.config).ApplyConfiguration()
+	var err error
+	c.config, err = loadConfig(configPath)
 	if err != nil {
-		glog.Fatalf("Failed to apply configuration for %q: %v", configPath, err)
-	}
-
-	// Validate configurations
-	err = c.config.Validate()
-	if err != nil {
-		glog.Fatalf("Failed to validate custom plugin config %+v: %v", c.config, err)
+		return nil, fmt.Errorf("failed to load configuration file %q: %v", configPath, err)
 	}
 
 	glog.Infof("Finish parsing custom plugin monitor config file %s: %+v", c.configPath, c.config)
@@ -87,7 +80,33 @@ func NewCustomPluginMonitorOrDie(configPath string) types.Monitor {
 	if *c.config.EnableMetricsReporting {
 		initializeProblemMetricsOrDie(c.config.Rules)
 	}
-	return c
+	return c, nil
+}
+
+// ValidateConfig validates the custom plugin monitor configuration file without creating the
+// custom plugin monitor.
+func ValidateConfig(configPath string) error {
+	_, err := loadConfig(configPath)
+	return err
+}
+
+// loadConfig reads the custom plugin monitor configuration file, applies the configurations and
+// validates it.
+func loadConfig(configPath string) (cpmtypes.CustomPluginConfig, error) {
+	var config cpmtypes.CustomPluginConfig
+	f, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return config, err
+	}
+	if err := util.UnmarshalConfig(configPath, f, &config); err != nil {
+		return config, fmt.Errorf("failed to unmarshal: %v", err)
+	}
+	// Apply configurations
+	if err := (&config).ApplyConfiguration(); err != nil {
+		return config, fmt.Errorf("failed to apply configuration: %v", err)
+	}
+	// Validate configurations
+	return config, config.Validate()
 }
 
 // initializeProblemMetricsOrDie creates problem metrics for all problems and set the value to 0,
@@ -101,7 +120,12 @@ func initializeProblemMetricsOrDie(rules []*cpmtypes.CustomRule) {
 					rule.Condition, rule.Reason, err)
 			}
 		}
-		err := problemmetrics.GlobalProblemMetricsManager.IncrementProblemCounter(rule.Reason, 0)
+		// Permanent problems are counted by the condition change events, which are info events.
+		severity := rule.Severity
+		if rule.Type == types.Perm {
+			severity = types.Info
+		}
+		err := problemmetrics.GlobalProblemMetricsManager.IncrementProblemCounter(rule.Reason, severity, 0)
 		if err != nil {
 			glog.Fatalf("Failed to initialize problem counter metrics for %q: %v", rule.Reason, err)
 		}
@@ -110,11 +134,22 @@ func initializeProblemMetricsOrDie(rules []*cpmtypes.CustomRule) {
 
 func (c *customPluginMonitor) Start() (<-chan *types.Status, error) {
 	glog.Infof("Start custom plugin monitor %s", c.configPath)
+	c.heartbeat.Beat(time.Now(), "Custom plugin monitor started")
 	go c.plugin.Run()
 	go c.monitorLoop()
 	return c.statusChan, nil
 }
 
+// Heartbeat returns the time when the last plugin result is received.
+func (c *customPluginMonitor) Heartbeat() types.Heartbeat {
+	return c.heartbeat.Heartbeat()
+}
+
+// RestoreConditions sets the conditions custom plugin monitor starts with.
+func (c *customPluginMonitor) RestoreConditions(conditions []types.Condition) {
+	c.restoredConditions = conditions
+}
+
 func (c *customPluginMonitor) Stop() {
 	glog.Infof("Stop custom plugin monitor %s", c.configPath)
 	c.tomb.Stop()
@@ -122,6 +157,11 @@ func (c *customPluginMonitor) Stop() {
 
 // monitorLoop is the main loop of log monitor.
 func (c *customPluginMonitor) monitorLoop() {
+	defer func() {
+		c.checkpointConditions()
+		close(c.statusChan)
+		c.tomb.Done()
+	}()
 	c.initializeStatus()
 
 	resultChan := c.plugin.GetResultChan()
@@ -130,14 +170,15 @@ func (c *customPluginMonitor) monitorLoop() {
 		select {
 		case result := <-resultChan:
 			glog.V(3).Infof("Receive new plugin result for %s: %+v", c.configPath, result)
+			c.heartbeat.Beat(time.Now(), fmt.Sprintf("Received result of plugin %s", result.Rule.Path))
 			status := c.generateStatus(result)
 			glog.Infof("New status generated: %+v", status)
+			c.checkpointConditions()
 			c.statusChan <- status
 		case <-c.tomb.Stopping():
 			c.plugin.Stop()
 			glog.Infof("Custom plugin monitor stopped: %s", c.configPath)
-			c.tomb.Done()
-			break
+			return
 		}
 	}
 }
@@ -151,10 +192,11 @@ func (c *customPluginMonitor) generateStatus(result cpmtypes.Result) *types.Stat
 		// For temporary error only generate event when exit status is above warning
 		if result.ExitStatus >= cpmtypes.NonOK {
 			activeProblemEvents = append(activeProblemEvents, types.Event{
-				Severity:  types.Warn,
+				Severity:  result.Rule.Severity,
 				Timestamp: timestamp,
 				Reason:    result.Rule.Reason,
 				Message:   result.Message,
+				Labels:    result.Labels,
 			})
 		}
 	} else {
@@ -221,6 +263,11 @@ func (c *customPluginMonitor) generateStatus(result cpmtypes.Result) *types.Stat
 					condition.Status = status
 					condition.Reason = newReason
 					condition.Message = newMessage
+					// Only the problem is labeled.
+					condition.Labels = nil
+					if status == types.True {
+						condition.Labels = result.Labels
+					}
 
 					updateEvent := util.GenerateConditionChangeEvent(
 						condition.Type,
@@ -228,6 +275,7 @@ func (c *customPluginMonitor) generateStatus(result cpmtypes.Result) *types.Stat
 						newReason,
 						timestamp,
 					)
+					updateEvent.Labels = condition.Labels
 
 					if status == types.True {
 						activeProblemEvents = append(activeProblemEvents, updateEvent)
@@ -244,7 +292,7 @@ func (c *customPluginMonitor) generateStatus(result cpmtypes.Result) *types.Stat
 		// Increment problem counter only for active problems which just got detected.
 		for _, event := range activeProblemEvents {
 			err := problemmetrics.GlobalProblemMetricsManager.IncrementProblemCounter(
-				event.Reason, 1)
+				event.Reason, event.Severity, 1)
 			if err != nil {
 				glog.Errorf("Failed to update problem counter metrics for %q: %v",
 					event.Reason, err)
@@ -259,11 +307,12 @@ func (c *customPluginMonitor) generateStatus(result cpmtypes.Result) *types.Stat
 			}
 		}
 	}
+	// Copy the conditions, because they are updated in place while the status is exported.
 	return &types.Status{
 		Source: c.config.Source,
 		// TODO(random-liu): Aggregate events and conditions and then do periodically report.
 		Events:     append(activeProblemEvents, inactiveProblemEvents...),
-		Conditions: c.conditions,
+		Conditions: append([]types.Condition(nil), c.conditions...),
 	}
 }
 
@@ -281,21 +330,53 @@ func toConditionStatus(s cpmtypes.Status) types.ConditionStatus {
 // initializeStatus initializes the internal condition and also reports it to the node problem detector.
 func (c *customPluginMonitor) initializeStatus() {
 	// Initialize the default node conditions
-	c.conditions = initialConditions(c.config.DefaultConditions)
+	restored := c.restoredConditions
+	if restored == nil {
+		// Not restarted by the problem detector, restore the conditions from the checkpoint.
+		restored = checkpoint.GlobalConditionCheckpointManager.Load(c.configPath)
+	}
+	c.conditions = initialConditions(c.config.DefaultConditions, restored)
 	glog.Infof("Initialize condition generated: %+v", c.conditions)
+	if *c.config.EnableMetricsReporting {
+		for _, condition := range c.conditions {
+			err := problemmetrics.GlobalProblemMetricsManager.SetProblemGauge(
+				condition.Type, condition.Reason, condition.Status == types.True)
+			if err != nil {
+				glog.Errorf("Failed to update problem gauge metrics for problem %q, reason %q: %v",
+					condition.Type, condition.Reason, err)
+			}
+		}
+	}
+	c.checkpointConditions()
 	// Update the initial status
 	c.statusChan <- &types.Status{
 		Source:     c.config.Source,
-		Conditions: c.conditions,
+		Conditions: append([]types.Condition(nil), c.conditions...),
 	}
 }
 
-func initialConditions(defaults []types.Condition) []types.Condition {
+// checkpointConditions checkpoints the current conditions, so that they can be restored after
+// node problem detector restarts.
+func (c *customPluginMonitor) checkpointConditions() {
+	if err := checkpoint.GlobalConditionCheckpointManager.Save(c.configPath, c.conditions); err != nil {
+		glog.Errorf("Failed to checkpoint conditions of %q: %v", c.configPath, err)
+	}
+}
+
+// initialConditions generates the initial conditions from the default conditions. Conditions
+// of the same type in restored are used instead of the defaults.
+func initialConditions(defaults []types.Condition, restored []types.Condition) []types.Condition {
 	conditions := make([]types.Condition, len(defaults))
 	copy(conditions, defaults)
 	for i := range conditions {
 		conditions[i].Status = types.False
 		conditions[i].Transition = time.Now()
+		for _, condition := range restored {
+			if condition.Type == conditions[i].Type {
+				conditions[i] = condition
+				break
+			}
+		}
 	}
 	return conditions
 }
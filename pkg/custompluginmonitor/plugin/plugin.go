@@ -17,7 +17,9 @@ limitations under the License.
 package plugin
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -75,7 +77,7 @@ func (p *Plugin) Run() {
 				}()
 
 				start := time.Now()
-				exitStatus, message := p.run(*rule)
+				exitStatus, message, labels := p.run(*rule)
 				end := time.Now()
 
 				glog.V(3).Infof("Rule: %+v. Start time: %v. End time: %v. Duration: %v", rule, start, end, end.Sub(start))
@@ -84,6 +86,7 @@ func (p *Plugin) Run() {
 					Rule:       rule,
 					ExitStatus: exitStatus,
 					Message:    message,
+					Labels:     labels,
 				}
 
 				p.resultChan <- result
@@ -113,7 +116,8 @@ func (p *Plugin) Run() {
 	}
 }
 
-func (p *Plugin) run(rule cpmtypes.CustomRule) (exitStatus cpmtypes.Status, output string) {
+// run runs the plugin, and returns its exit status, output message and labels.
+func (p *Plugin) run(rule cpmtypes.CustomRule) (exitStatus cpmtypes.Status, output string, labels map[string]string) {
 	var ctx context.Context
 	var cancel context.CancelFunc
 
@@ -129,13 +133,14 @@ func (p *Plugin) run(rule cpmtypes.CustomRule) (exitStatus cpmtypes.Status, outp
 	if err != nil {
 		if _, ok := err.(*exec.ExitError); !ok {
 			glog.Errorf("Error in running plugin %q: error - %v. output - %q", rule.Path, err, string(stdout))
-			return cpmtypes.Unknown, "Error in running plugin. Please check the error log"
+			return cpmtypes.Unknown, "Error in running plugin. Please check the error log", nil
 		}
 	}
 
 	// trim suffix useless bytes
 	output = string(stdout)
 	output = strings.TrimSpace(output)
+	output, labels = parseOutput(output)
 
 	if cmd.ProcessState.Sys().(syscall.WaitStatus).Signaled() {
 		output = fmt.Sprintf("Timeout when running plugin %q: state - %s. output - %q", rule.Path, cmd.ProcessState.String(), output)
@@ -149,14 +154,36 @@ func (p *Plugin) run(rule cpmtypes.CustomRule) (exitStatus cpmtypes.Status, outp
 	exitCode := cmd.ProcessState.Sys().(syscall.WaitStatus).ExitStatus()
 	switch exitCode {
 	case 0:
-		return cpmtypes.OK, output
+		return cpmtypes.OK, output, labels
 	case 1:
-		return cpmtypes.NonOK, output
+		return cpmtypes.NonOK, output, labels
 	default:
-		return cpmtypes.Unknown, output
+		return cpmtypes.Unknown, output, labels
 	}
 }
 
+// structuredOutput is the output of the plugins which report labels, e.g.
+// {"message": "Device sda1 is read-only", "labels": {"device": "sda1"}}
+type structuredOutput struct {
+	Message string            `json:"message"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// parseOutput returns the message and the labels in the plugin output, if the output is a
+// structuredOutput. Otherwise the output is returned as the message.
+func parseOutput(output string) (string, map[string]string) {
+	if !strings.HasPrefix(output, "{") {
+		return output, nil
+	}
+	var structured structuredOutput
+	decoder := json.NewDecoder(bytes.NewBufferString(output))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&structured); err != nil || decoder.More() {
+		return output, nil
+	}
+	return structured.Message, structured.Labels
+}
+
 func (p *Plugin) Stop() {
 	p.tomb.Stop()
 	glog.Info("Stop plugin execution")
@@ -17,6 +17,7 @@ limitations under the License.
 package plugin
 
 import (
+	"reflect"
 	"testing"
 	"time"
 
@@ -30,6 +31,7 @@ func TestNewPluginRun(t *testing.T) {
 		Rule       cpmtypes.CustomRule
 		ExitStatus cpmtypes.Status
 		Output     string
+		Labels     map[string]string
 	}{
 		"ok": {
 			Rule: cpmtypes.CustomRule{
@@ -63,6 +65,15 @@ func TestNewPluginRun(t *testing.T) {
 			ExitStatus: cpmtypes.Unknown,
 			Output:     "Error in running plugin. Please check the error log",
 		},
+		"non-ok with labels": {
+			Rule: cpmtypes.CustomRule{
+				Path:    "./test-data/non-ok-with-labels.sh",
+				Timeout: &ruleTimeout,
+			},
+			ExitStatus: cpmtypes.NonOK,
+			Output:     "NonOK",
+			Labels:     map[string]string{"device": "sda1"},
+		},
 		"longer than 80 stdout with ok exit status": {
 			Rule: cpmtypes.CustomRule{
 				Path:    "./test-data/longer-than-80-stdout-with-ok-exit-status.sh",
@@ -93,17 +104,49 @@ func TestNewPluginRun(t *testing.T) {
 	(&conf).ApplyConfiguration()
 	p := Plugin{config: conf}
 	for desp, utMeta := range utMetas {
-		gotExitStatus, gotOutput := p.run(utMeta.Rule)
+		gotExitStatus, gotOutput, gotLabels := p.run(utMeta.Rule)
 		// cut at position max_output_length if expected output is longer than max_output_length bytes
 		if len(utMeta.Output) > *p.config.PluginGlobalConfig.MaxOutputLength {
 			utMeta.Output = utMeta.Output[:*p.config.PluginGlobalConfig.MaxOutputLength]
 		}
-		if gotExitStatus != utMeta.ExitStatus || gotOutput != utMeta.Output {
+		if gotExitStatus != utMeta.ExitStatus || gotOutput != utMeta.Output || !reflect.DeepEqual(gotLabels, utMeta.Labels) {
 			t.Errorf("%s", desp)
 			t.Errorf("Error in run plugin and get exit status and output for %q. "+
 				"Got exit status: %v, Expected exit status: %v. "+
-				"Got output: %q, Expected output: %q",
-				utMeta.Rule.Path, gotExitStatus, utMeta.ExitStatus, gotOutput, utMeta.Output)
+				"Got output: %q, Expected output: %q. "+
+				"Got labels: %v, Expected labels: %v",
+				utMeta.Rule.Path, gotExitStatus, utMeta.ExitStatus, gotOutput, utMeta.Output, gotLabels, utMeta.Labels)
+		}
+	}
+}
+
+func TestParseOutput(t *testing.T) {
+	for desc, test := range map[string]struct {
+		output  string
+		message string
+		labels  map[string]string
+	}{
+		"plain text": {
+			output:  "Device sda1 is read-only",
+			message: "Device sda1 is read-only",
+		},
+		"structured": {
+			output:  `{"message": "Device sda1 is read-only", "labels": {"device": "sda1"}}`,
+			message: "Device sda1 is read-only",
+			labels:  map[string]string{"device": "sda1"},
+		},
+		"json with unknown fields": {
+			output:  `{"device": "sda1"}`,
+			message: `{"device": "sda1"}`,
+		},
+		"json followed by text": {
+			output:  `{"message": "foo"} bar`,
+			message: `{"message": "foo"} bar`,
+		},
+	} {
+		message, labels := parseOutput(test.output)
+		if message != test.message || !reflect.DeepEqual(labels, test.labels) {
+			t.Errorf("%s: expected message %q and labels %v, got %q and %v", desc, test.message, test.labels, message, labels)
 		}
 	}
 }
@@ -0,0 +1,4 @@
+#!/usr/bin/env bash
+
+echo '{"message": "NonOK", "labels": {"device": "sda1"}}'
+exit 1
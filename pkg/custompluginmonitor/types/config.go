@@ -21,6 +21,8 @@ import (
 	"os"
 	"time"
 
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
 	"k8s.io/node-problem-detector/pkg/types"
 )
 
@@ -113,6 +115,9 @@ func (cpc *CustomPluginConfig) ApplyConfiguration() error {
 			}
 			rule.Timeout = &timeout
 		}
+		if rule.Severity == "" {
+			rule.Severity = types.Warn
+		}
 	}
 
 	if cpc.EnableMetricsReporting == nil {
@@ -128,35 +133,30 @@ func (cpc CustomPluginConfig) Validate() error {
 		return fmt.Errorf("NPD does not support %q plugin for now. Only support \"custom\"", cpc.Plugin)
 	}
 
-	for _, rule := range cpc.Rules {
+	var errs []error
+	for i, rule := range cpc.Rules {
 		if rule.Timeout != nil && *rule.Timeout > *cpc.PluginGlobalConfig.Timeout {
-			return fmt.Errorf("plugin timeout is greater than global timeout. "+
-				"Rule: %+v. Global timeout: %v", rule, cpc.PluginGlobalConfig.Timeout)
+			errs = append(errs, fmt.Errorf("rules[%d]: plugin timeout %v is greater than global timeout %v",
+				i, *rule.Timeout, *cpc.PluginGlobalConfig.Timeout))
 		}
-	}
-
-	for _, rule := range cpc.Rules {
 		if _, err := os.Stat(rule.Path); os.IsNotExist(err) {
-			return fmt.Errorf("rule path %q does not exist. Rule: %+v", rule.Path, rule)
+			errs = append(errs, fmt.Errorf("rules[%d]: rule path %q does not exist", i, rule.Path))
 		}
-	}
-
-	for _, rule := range cpc.Rules {
-		if rule.Type != types.Perm {
-			continue
-		}
-		conditionType := rule.Condition
-		defaultConditionExists := false
-		for _, cond := range cpc.DefaultConditions {
-			if conditionType == cond.Type {
-				defaultConditionExists = true
-				break
-			}
+		if rule.Severity != "" && !types.IsValidSeverity(rule.Severity) {
+			errs = append(errs, fmt.Errorf("rules[%d]: severity %q is not one of %q", i, rule.Severity, types.Severities))
 		}
-		if !defaultConditionExists {
-			return fmt.Errorf("Permanent problem %s does not have preset default condition.", conditionType)
+		if rule.Type == types.Perm && !cpc.hasDefaultCondition(rule.Condition) {
+			errs = append(errs, fmt.Errorf("rules[%d]: permanent problem %q does not have preset default condition", i, rule.Condition))
 		}
 	}
+	return utilerrors.NewAggregate(errs)
+}
 
-	return nil
+func (cpc CustomPluginConfig) hasDefaultCondition(conditionType string) bool {
+	for _, condition := range cpc.DefaultConditions {
+		if condition.Type == conditionType {
+			return true
+		}
+	}
+	return false
 }
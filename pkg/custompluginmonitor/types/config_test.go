@@ -51,6 +51,10 @@ func TestCustomPluginConfigApplyConfiguration(t *testing.T) {
 						Path:          "../plugin/test-data/warning.sh",
 						TimeoutString: &ruleTimeoutString,
 					},
+					{
+						Path:     "../plugin/test-data/non-ok.sh",
+						Severity: types.Critical,
+					},
 				},
 			},
 			Wanted: CustomPluginConfig{
@@ -66,13 +70,19 @@ func TestCustomPluginConfigApplyConfiguration(t *testing.T) {
 				EnableMetricsReporting: &defaultEnableMetricsReporting,
 				Rules: []*CustomRule{
 					{
-						Path: "../plugin/test-data/ok.sh",
+						Path:     "../plugin/test-data/ok.sh",
+						Severity: types.Warn,
 					},
 					{
 						Path:          "../plugin/test-data/warning.sh",
+						Severity:      types.Warn,
 						Timeout:       &ruleTimeout,
 						TimeoutString: &ruleTimeoutString,
 					},
+					{
+						Path:     "../plugin/test-data/non-ok.sh",
+						Severity: types.Critical,
+					},
 				},
 			},
 		},
@@ -281,6 +291,25 @@ func TestCustomPluginConfigValidate(t *testing.T) {
 			},
 			IsError: true,
 		},
+		"unknown severity": {
+			Conf: CustomPluginConfig{
+				Plugin: customPluginName,
+				PluginGlobalConfig: pluginGlobalConfig{
+					InvokeInterval:  &defaultInvokeInterval,
+					Timeout:         &defaultGlobalTimeout,
+					MaxOutputLength: &defaultMaxOutputLength,
+					Concurrency:     &defaultConcurrency,
+				},
+				Rules: []*CustomRule{
+					{
+						Severity: "fatal",
+						Path:     "../plugin/test-data/ok.sh",
+						Timeout:  &normalRuleTimeout,
+					},
+				},
+			},
+			IsError: true,
+		},
 		"permanent problem has preset default condition": {
 			Conf: CustomPluginConfig{
 				Plugin: customPluginName,
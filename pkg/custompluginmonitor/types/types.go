@@ -34,6 +34,8 @@ type Result struct {
 	Rule       *CustomRule
 	ExitStatus Status
 	Message    string
+	// Labels are the structured details of the problem reported by the plugin.
+	Labels map[string]string
 }
 
 // CustomRule describes how custom plugin monitor should invoke and analyze plugins.
@@ -46,6 +48,10 @@ type CustomRule struct {
 	Condition string `json:"condition"`
 	// Reason is the short reason of the problem.
 	Reason string `json:"reason"`
+	// Severity is the severity of the event generated for the problem, default to warn. Notice
+	// that the Severity field should be set only when the problem is temporary, or else the
+	// field will be ignored.
+	Severity types.Severity `json:"severity,omitempty"`
 	// Path is the path to the custom plugin.
 	Path string `json:"path"`
 	// Args is the args passed to the custom plugin.
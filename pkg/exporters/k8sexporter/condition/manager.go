@@ -56,6 +56,9 @@ type ConditionManager interface {
 	UpdateCondition(types.Condition)
 	// GetConditions returns all current conditions.
 	GetConditions() []types.Condition
+	// Flush synchronizes all condition updates with the apiserver immediately, and
+	// blocks until the synchronization is done.
+	Flush()
 }
 
 type conditionManager struct {
@@ -75,15 +78,19 @@ type conditionManager struct {
 	client       problemclient.Client
 	updates      map[string]types.Condition
 	conditions   map[string]types.Condition
+	// flushRequests is used to ask the sync routine to synchronize immediately. The
+	// sync routine closes the passed in channel once the synchronization is done.
+	flushRequests chan chan struct{}
 }
 
 // NewConditionManager creates a condition manager.
 func NewConditionManager(client problemclient.Client, clock clock.Clock) ConditionManager {
 	return &conditionManager{
-		client:     client,
-		clock:      clock,
-		updates:    make(map[string]types.Condition),
-		conditions: make(map[string]types.Condition),
+		client:        client,
+		clock:         clock,
+		updates:       make(map[string]types.Condition),
+		conditions:    make(map[string]types.Condition),
+		flushRequests: make(chan chan struct{}),
 	}
 }
 
@@ -109,6 +116,12 @@ func (c *conditionManager) GetConditions() []types.Condition {
 	return conditions
 }
 
+func (c *conditionManager) Flush() {
+	done := make(chan struct{})
+	c.flushRequests <- done
+	<-done
+}
+
 func (c *conditionManager) syncLoop() {
 	updateCh := c.clock.Tick(updatePeriod)
 	for {
@@ -117,6 +130,11 @@ func (c *conditionManager) syncLoop() {
 			if c.needUpdates() || c.needResync() || c.needHeartbeat() {
 				c.sync()
 			}
+		case done := <-c.flushRequests:
+			if c.needUpdates() || c.resyncNeeded {
+				c.sync()
+			}
+			close(done)
 		}
 	}
 }
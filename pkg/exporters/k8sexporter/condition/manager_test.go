@@ -113,6 +113,16 @@ func TestResync(t *testing.T) {
 	assert.True(t, m.needResync(), "Should resync after resync period and resync is needed")
 }
 
+func TestFlush(t *testing.T) {
+	m, fakeClient, _ := newTestManager()
+	m.Start()
+	condition := newTestCondition("TestCondition")
+	m.UpdateCondition(condition)
+	m.Flush()
+	expected := []v1.NodeCondition{problemutil.ConvertToAPICondition(condition)}
+	assert.Nil(t, fakeClient.AssertConditions(expected), "Condition should be updated via client on flush")
+}
+
 func TestHeartbeat(t *testing.T) {
 	m, fakeClient, fakeClock := newTestManager()
 	condition := newTestCondition("TestCondition")
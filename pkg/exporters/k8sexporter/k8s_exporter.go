@@ -17,11 +17,6 @@ limitations under the License.
 package k8sexporter
 
 import (
-	"net"
-	"net/http"
-	_ "net/http/pprof"
-	"strconv"
-
 	"github.com/golang/glog"
 
 	"k8s.io/apimachinery/pkg/util/clock"
@@ -61,7 +56,6 @@ func NewExporterOrDie(npdo *options.NodeProblemDetectorOptions) types.Exporter {
 		conditionManager: condition.NewConditionManager(c, clock.RealClock{}),
 	}
 
-	ke.startHTTPReporting(npdo)
 	ke.conditionManager.Start()
 
 	return &ke
@@ -69,38 +63,17 @@ func NewExporterOrDie(npdo *options.NodeProblemDetectorOptions) types.Exporter {
 
 func (ke *k8sExporter) ExportProblems(status *types.Status) {
 	for _, event := range status.Events {
-		ke.client.Eventf(util.ConvertToAPIEventType(event.Severity), status.Source, event.Reason, event.Message)
+		// The event labels are reported as the annotations of the event.
+		ke.client.AnnotatedEventf(util.ConvertToAPIEventType(event.Severity), status.Source, event.Reason, event.Labels, event.Message)
 	}
 	for _, cdt := range status.Conditions {
 		ke.conditionManager.UpdateCondition(cdt)
 	}
 }
 
-func (ke *k8sExporter) startHTTPReporting(npdo *options.NodeProblemDetectorOptions) {
-	if npdo.ServerPort <= 0 {
-		return
-	}
-	mux := http.NewServeMux()
-
-	// Add healthz http request handler. Always return ok now, add more health check
-	// logic in the future.
-	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("ok"))
-	})
-
-	// Add the handler to serve condition http request.
-	mux.HandleFunc("/conditions", func(w http.ResponseWriter, r *http.Request) {
-		util.ReturnHTTPJson(w, ke.conditionManager.GetConditions())
-	})
-
-	addr := net.JoinHostPort(npdo.ServerAddress, strconv.Itoa(npdo.ServerPort))
-	go func() {
-		err := http.ListenAndServe(addr, mux)
-		if err != nil {
-			glog.Fatalf("Failed to start server: %v", err)
-		}
-	}()
+// Flush synchronizes the pending node condition updates with the apiserver.
+func (ke *k8sExporter) Flush() {
+	ke.conditionManager.Flush()
 }
 
 func waitForAPIServerReadyWithTimeout(c problemclient.Client, npdo *options.NodeProblemDetectorOptions) error {
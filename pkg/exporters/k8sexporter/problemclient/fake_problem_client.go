@@ -93,6 +93,10 @@ func (f *FakeProblemClient) GetConditions(types []v1.NodeConditionType) ([]*v1.N
 func (f *FakeProblemClient) Eventf(eventType string, source, reason, messageFmt string, args ...interface{}) {
 }
 
+// AnnotatedEventf does nothing now.
+func (f *FakeProblemClient) AnnotatedEventf(eventType string, source, reason string, annotations map[string]string, messageFmt string, args ...interface{}) {
+}
+
 func (f *FakeProblemClient) GetNode() (*v1.Node, error) {
 	return nil, fmt.Errorf("GetNode() not implemented")
 }
@@ -28,8 +28,10 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/apimachinery/pkg/watch"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/record"
 
@@ -47,6 +49,8 @@ type Client interface {
 	SetConditions(conditions []v1.NodeCondition) error
 	// Eventf reports the event.
 	Eventf(eventType string, source, reason, messageFmt string, args ...interface{})
+	// AnnotatedEventf reports the event with annotations.
+	AnnotatedEventf(eventType string, source, reason string, annotations map[string]string, messageFmt string, args ...interface{})
 	// GetNode returns the Node object of the node on which the
 	// node-problem-detector runs.
 	GetNode() (*v1.Node, error)
@@ -110,13 +114,59 @@ func (c *nodeProblemClient) SetConditions(newConditions []v1.NodeCondition) erro
 }
 
 func (c *nodeProblemClient) Eventf(eventType, source, reason, messageFmt string, args ...interface{}) {
+	c.getRecorder(source).Eventf(c.nodeRef, eventType, reason, messageFmt, args...)
+}
+
+// eventBroadcaster broadcasts the events to the event sink, where they are aggregated and rate
+// limited. It is implemented by the event recorder.
+type eventBroadcaster interface {
+	Action(action watch.EventType, obj runtime.Object)
+}
+
+// AnnotatedEventf reports the event with annotations. The event recorder doesn't support
+// annotations, so the annotated event is generated the same way as the event recorder does, and
+// broadcast through the event recorder, so that it's aggregated and rate limited with the other
+// events. An event without annotations, or whose recorder can't broadcast it, is reported by
+// Eventf.
+func (c *nodeProblemClient) AnnotatedEventf(eventType, source, reason string, annotations map[string]string, messageFmt string, args ...interface{}) {
+	broadcaster, ok := c.getRecorder(source).(eventBroadcaster)
+	if len(annotations) == 0 || !ok {
+		c.Eventf(eventType, source, reason, messageFmt, args...)
+		return
+	}
+	// The broadcaster drops the events when the event sink falls behind, so this doesn't block.
+	broadcaster.Action(watch.Added, c.makeAnnotatedEvent(eventType, source, reason, annotations, fmt.Sprintf(messageFmt, args...)))
+}
+
+// getRecorder returns the event recorder of the source.
+func (c *nodeProblemClient) getRecorder(source string) record.EventRecorder {
 	recorder, found := c.recorders[source]
 	if !found {
 		// TODO(random-liu): If needed use separate client and QPS limit for event.
 		recorder = getEventRecorder(c.client, c.nodeName, source)
 		c.recorders[source] = recorder
 	}
-	recorder.Eventf(c.nodeRef, eventType, reason, messageFmt, args...)
+	return recorder
+}
+
+// makeAnnotatedEvent generates the annotated event the same way as the event recorder does.
+func (c *nodeProblemClient) makeAnnotatedEvent(eventType, source, reason string, annotations map[string]string, message string) *v1.Event {
+	t := metav1.NewTime(c.clock.Now())
+	return &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%v.%x", c.nodeRef.Name, t.UnixNano()),
+			Namespace:   metav1.NamespaceDefault,
+			Annotations: annotations,
+		},
+		InvolvedObject: *c.nodeRef,
+		Reason:         reason,
+		Message:        message,
+		FirstTimestamp: t,
+		LastTimestamp:  t,
+		Count:          1,
+		Type:           eventType,
+		Source:         v1.EventSource{Component: source, Host: c.nodeName},
+	}
 }
 
 func (c *nodeProblemClient) GetNode() (*v1.Node, error) {
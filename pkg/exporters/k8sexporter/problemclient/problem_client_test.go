@@ -26,6 +26,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/kubernetes/pkg/api/legacyscheme"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -86,3 +87,26 @@ func TestEvent(t *testing.T) {
 		t.Errorf("expected event %q, got %q", expected, got)
 	}
 }
+
+func TestAnnotatedEvent(t *testing.T) {
+	fakeRecorder := record.NewFakeRecorder(1)
+	client := newFakeProblemClient()
+	client.recorders[testSource] = fakeRecorder
+	client.AnnotatedEventf(v1.EventTypeWarning, testSource, "test reason", nil, "test message")
+	expected := fmt.Sprintf("%s %s %s", v1.EventTypeWarning, "test reason", "test message")
+	assert.Equal(t, expected, <-fakeRecorder.Events, "event without annotations should be recorded")
+
+	annotations := map[string]string{"pid": "1234"}
+	broadcaster := record.NewBroadcaster()
+	events := make(chan *v1.Event, 1)
+	broadcaster.StartEventWatcher(func(event *v1.Event) { events <- event })
+	client.recorders[testSource] = broadcaster.NewRecorder(legacyscheme.Scheme, v1.EventSource{Component: testSource, Host: testNode})
+	client.AnnotatedEventf(v1.EventTypeWarning, testSource, "test reason", annotations, "test message")
+	event := <-events
+	assert.Equal(t, annotations, event.Annotations, "annotated event should be broadcast by the recorder")
+	assert.Equal(t, *client.nodeRef, event.InvolvedObject)
+	assert.Equal(t, v1.EventSource{Component: testSource, Host: testNode}, event.Source)
+	assert.Equal(t, v1.EventTypeWarning, event.Type)
+	assert.Equal(t, "test reason", event.Reason)
+	assert.Equal(t, "test message", event.Message)
+}
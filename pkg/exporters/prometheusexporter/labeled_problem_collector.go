@@ -0,0 +1,213 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prometheusexporter
+
+import (
+	"container/list"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+const (
+	labeledProblemCounterName = "labeled_problem_counter"
+	labeledProblemGaugeName   = "labeled_problem_gauge"
+	// maxLabeledEvents is the maximum number of labeled event counters. The label values come
+	// from the problems, so the counters are capped to bound the memory and the Prometheus
+	// series. The least recently counted one is dropped to make room for a new one.
+	maxLabeledEvents = 1000
+	// problemLabelPrefix is the prefix of the Prometheus labels converted from the problem
+	// labels, so that they don't conflict with the reason, severity and type labels.
+	problemLabelPrefix = "label_"
+)
+
+// labeledProblem is a labeled problem exported as a Prometheus metric.
+type labeledProblem struct {
+	// key is the joined Prometheus labels, which identifies the metric.
+	key         string
+	labelNames  []string
+	labelValues []string
+	value       float64
+	// sources is the number of sources reporting the labeled condition.
+	sources int
+}
+
+// labeledProblemCollector exports the problems with labels as Prometheus metrics, with the
+// problem labels as Prometheus labels. The problem metrics can't do that, because the labels
+// of an OpenCensus metric are fixed when the metric is created. labeledProblemCollector is
+// thread-safe.
+type labeledProblemCollector struct {
+	sync.Mutex
+	// events are the elements of the counters of the labeled events in eventsByRecency, keyed
+	// by the joined Prometheus labels. There are at most maxEvents of them.
+	events map[string]*list.Element
+	// eventsByRecency are the counters of the labeled events, from the most recently counted to
+	// the least recently counted.
+	eventsByRecency *list.List
+	maxEvents       int
+	// conditions are the labeled conditions which are True, keyed by the joined Prometheus
+	// labels, which include the condition type, so that the same condition type with different
+	// labels is exported separately.
+	conditions map[string]*labeledProblem
+	// reported are the keys of the labeled conditions reported by each source, keyed by source
+	// and then condition type.
+	reported map[string]map[string]string
+}
+
+func newLabeledProblemCollector(maxEvents int) *labeledProblemCollector {
+	return &labeledProblemCollector{
+		events:          make(map[string]*list.Element),
+		eventsByRecency: list.New(),
+		maxEvents:       maxEvents,
+		conditions:      make(map[string]*labeledProblem),
+		reported:        make(map[string]map[string]string),
+	}
+}
+
+// record counts the labeled events and updates the labeled conditions in the status.
+func (c *labeledProblemCollector) record(status *types.Status) {
+	c.Lock()
+	defer c.Unlock()
+	for _, event := range status.Events {
+		if len(event.Labels) == 0 {
+			continue
+		}
+		problem := newLabeledProblem(event.Labels, map[string]string{
+			"reason":   event.Reason,
+			"severity": string(event.Severity),
+		})
+		if element, ok := c.events[problem.key]; ok {
+			c.eventsByRecency.MoveToFront(element)
+			problem = element.Value.(*labeledProblem)
+		} else {
+			if len(c.events) >= c.maxEvents {
+				// Drop the labeled event counter counted least recently.
+				leastRecent := c.eventsByRecency.Back()
+				c.eventsByRecency.Remove(leastRecent)
+				delete(c.events, leastRecent.Value.(*labeledProblem).key)
+			}
+			c.events[problem.key] = c.eventsByRecency.PushFront(problem)
+		}
+		problem.value++
+	}
+	for _, condition := range status.Conditions {
+		c.recordCondition(status.Source, condition)
+	}
+}
+
+// recordCondition replaces the labeled condition previously reported by the source for the
+// condition type. The labeled condition is only removed once no source reports it. The caller
+// should hold the lock.
+func (c *labeledProblemCollector) recordCondition(source string, condition types.Condition) {
+	reported, ok := c.reported[source]
+	if !ok {
+		reported = make(map[string]string)
+		c.reported[source] = reported
+	}
+	if key, ok := reported[condition.Type]; ok {
+		delete(reported, condition.Type)
+		if problem := c.conditions[key]; problem != nil {
+			problem.sources--
+			if problem.sources == 0 {
+				delete(c.conditions, key)
+			}
+		}
+	}
+	if condition.Status != types.True || len(condition.Labels) == 0 {
+		return
+	}
+	problem := newLabeledProblem(condition.Labels, map[string]string{
+		"type":   condition.Type,
+		"reason": condition.Reason,
+	})
+	if existing, ok := c.conditions[problem.key]; ok {
+		problem = existing
+	} else {
+		problem.value = 1
+		c.conditions[problem.key] = problem
+	}
+	problem.sources++
+	reported[condition.Type] = problem.key
+}
+
+// Describe sends nothing, so that the collector is unchecked, because the label names of the
+// metrics depend on the problems.
+func (c *labeledProblemCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect sends the labeled problem metrics.
+func (c *labeledProblemCollector) Collect(ch chan<- prometheus.Metric) {
+	c.Lock()
+	defer c.Unlock()
+	for element := c.eventsByRecency.Front(); element != nil; element = element.Next() {
+		problem := element.Value.(*labeledProblem)
+		desc := prometheus.NewDesc(labeledProblemCounterName,
+			"Number of times a specific type of problem with labels have occurred.", problem.labelNames, nil)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, problem.value, problem.labelValues...)
+	}
+	for _, problem := range c.conditions {
+		desc := prometheus.NewDesc(labeledProblemGaugeName,
+			"Whether a specific type of problem with labels is affecting the node or not.", problem.labelNames, nil)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, problem.value, problem.labelValues...)
+	}
+}
+
+// newLabeledProblem converts the problem labels to Prometheus labels, and sorts them together
+// with the problem metric labels. The problem labels are sanitized and prefixed, and the ones
+// which still conflict after that are dropped.
+func newLabeledProblem(problemLabels map[string]string, metricLabels map[string]string) *labeledProblem {
+	labels := make(map[string]string)
+	for name, value := range metricLabels {
+		labels[name] = value
+	}
+	var names []string
+	for name := range problemLabels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		labelName := problemLabelPrefix + sanitizeLabelName(name)
+		if _, ok := labels[labelName]; !ok {
+			labels[labelName] = problemLabels[name]
+		}
+	}
+
+	problem := &labeledProblem{}
+	for name := range labels {
+		problem.labelNames = append(problem.labelNames, name)
+	}
+	sort.Strings(problem.labelNames)
+	for _, name := range problem.labelNames {
+		problem.labelValues = append(problem.labelValues, labels[name])
+	}
+	problem.key = strings.Join(append(problem.labelNames, problem.labelValues...), "\x00")
+	return problem
+}
+
+// sanitizeLabelName replaces the characters which are not allowed in Prometheus label names
+// with underscores.
+func sanitizeLabelName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prometheusexporter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+func TestLabeledProblemCollector(t *testing.T) {
+	c := newLabeledProblemCollector(maxLabeledEvents)
+	registry := prometheus.NewRegistry()
+	assert.NoError(t, registry.Register(c))
+
+	oom := types.Event{Severity: types.Warn, Reason: "OOMKilling", Labels: map[string]string{"pid": "1234", "process-name": "foo"}}
+	c.record(&types.Status{
+		Source: "kernel-monitor",
+		Events: []types.Event{oom, oom, {Severity: types.Warn, Reason: "TaskHung"}},
+		Conditions: []types.Condition{
+			{Type: "ReadonlyFilesystem", Status: types.True, Reason: "FilesystemIsReadOnly", Labels: map[string]string{"device": "sda1"}},
+			{Type: "KernelDeadlock", Status: types.True, Reason: "DockerHung"},
+		},
+	})
+	assert.Equal(t, map[string]map[string]float64{
+		labeledProblemCounterName: {
+			"label_pid=1234,label_process_name=foo,reason=OOMKilling,severity=warn": 2,
+		},
+		labeledProblemGaugeName: {
+			"label_device=sda1,reason=FilesystemIsReadOnly,type=ReadonlyFilesystem": 1,
+		},
+	}, gather(t, registry), "only the labeled problems should be exported")
+
+	c.record(&types.Status{
+		Source: "kernel-monitor",
+		Conditions: []types.Condition{
+			{Type: "ReadonlyFilesystem", Status: types.False, Reason: "FilesystemIsNotReadOnly"},
+		},
+	})
+	assert.Equal(t, map[string]map[string]float64{
+		labeledProblemCounterName: {
+			"label_pid=1234,label_process_name=foo,reason=OOMKilling,severity=warn": 2,
+		},
+	}, gather(t, registry), "the condition should not be exported once it's no longer True")
+}
+
+func TestLabeledProblemCollectorConditionLabels(t *testing.T) {
+	c := newLabeledProblemCollector(maxLabeledEvents)
+	registry := prometheus.NewRegistry()
+	assert.NoError(t, registry.Register(c))
+
+	readonly := func(device string) types.Condition {
+		return types.Condition{Type: "ReadonlyFilesystem", Status: types.True, Reason: "FilesystemIsReadOnly", Labels: map[string]string{"device": device}}
+	}
+	c.record(&types.Status{Source: "kernel-monitor", Conditions: []types.Condition{readonly("sda1")}})
+	c.record(&types.Status{Source: "custom-monitor", Conditions: []types.Condition{readonly("sdb1")}})
+	c.record(&types.Status{Source: "abrt-monitor", Conditions: []types.Condition{readonly("sdb1")}})
+	assert.Equal(t, map[string]map[string]float64{
+		labeledProblemGaugeName: {
+			"label_device=sda1,reason=FilesystemIsReadOnly,type=ReadonlyFilesystem": 1,
+			"label_device=sdb1,reason=FilesystemIsReadOnly,type=ReadonlyFilesystem": 1,
+		},
+	}, gather(t, registry), "the same condition type with different labels should be exported separately")
+
+	c.record(&types.Status{Source: "kernel-monitor", Conditions: []types.Condition{readonly("sdb1")}})
+	c.record(&types.Status{Source: "custom-monitor", Conditions: []types.Condition{
+		{Type: "ReadonlyFilesystem", Status: types.False, Reason: "FilesystemIsNotReadOnly"},
+	}})
+	assert.Equal(t, map[string]map[string]float64{
+		labeledProblemGaugeName: {
+			"label_device=sdb1,reason=FilesystemIsReadOnly,type=ReadonlyFilesystem": 1,
+		},
+	}, gather(t, registry), "the condition should be exported while any source still reports it")
+
+	c.record(&types.Status{Source: "kernel-monitor", Conditions: []types.Condition{
+		{Type: "ReadonlyFilesystem", Status: types.False, Reason: "FilesystemIsNotReadOnly"},
+	}})
+	c.record(&types.Status{Source: "abrt-monitor", Conditions: []types.Condition{
+		{Type: "ReadonlyFilesystem", Status: types.False, Reason: "FilesystemIsNotReadOnly"},
+	}})
+	assert.Empty(t, gather(t, registry), "the condition should not be exported once no source reports it")
+}
+
+func TestLabeledProblemCollectorMaxEvents(t *testing.T) {
+	c := newLabeledProblemCollector(2)
+	registry := prometheus.NewRegistry()
+	assert.NoError(t, registry.Register(c))
+
+	oom := func(pid string) types.Event {
+		return types.Event{Severity: types.Warn, Reason: "OOMKilling", Labels: map[string]string{"pid": pid}}
+	}
+	c.record(&types.Status{Source: "kernel-monitor", Events: []types.Event{oom("1"), oom("2"), oom("1"), oom("3")}})
+	assert.Equal(t, map[string]map[string]float64{
+		labeledProblemCounterName: {
+			"label_pid=1,reason=OOMKilling,severity=warn": 2,
+			"label_pid=3,reason=OOMKilling,severity=warn": 1,
+		},
+	}, gather(t, registry), "the least recently counted event should be dropped")
+}
+
+// gather returns the values of the metrics, keyed by metric name and then by joined labels.
+func gather(t *testing.T, registry *prometheus.Registry) map[string]map[string]float64 {
+	families, err := registry.Gather()
+	assert.NoError(t, err)
+	metrics := make(map[string]map[string]float64)
+	for _, family := range families {
+		metrics[family.GetName()] = make(map[string]float64)
+		for _, metric := range family.GetMetric() {
+			var labels []string
+			for _, label := range metric.GetLabel() {
+				labels = append(labels, label.GetName()+"="+label.GetValue())
+			}
+			value := metric.GetGauge().GetValue()
+			if metric.Counter != nil {
+				value = metric.GetCounter().GetValue()
+			}
+			metrics[family.GetName()][strings.Join(labels, ",")] = value
+		}
+	}
+	return metrics
+}
@@ -23,13 +23,16 @@ import (
 
 	"contrib.go.opencensus.io/exporter/prometheus"
 	"github.com/golang/glog"
+	prometheusclient "github.com/prometheus/client_golang/prometheus"
 	"go.opencensus.io/stats/view"
 
 	"k8s.io/node-problem-detector/cmd/options"
 	"k8s.io/node-problem-detector/pkg/types"
 )
 
-type prometheusExporter struct{}
+type prometheusExporter struct {
+	labeledProblems *labeledProblemCollector
+}
 
 // NewExporterOrDie creates an exporter to export metrics to Prometheus, panics if error occurs.
 func NewExporterOrDie(npdo *options.NodeProblemDetectorOptions) types.Exporter {
@@ -38,7 +41,12 @@ func NewExporterOrDie(npdo *options.NodeProblemDetectorOptions) types.Exporter {
 	}
 
 	addr := net.JoinHostPort(npdo.PrometheusServerAddress, strconv.Itoa(npdo.PrometheusServerPort))
-	pe, err := prometheus.NewExporter(prometheus.Options{})
+	registry := prometheusclient.NewRegistry()
+	labeledProblems := newLabeledProblemCollector(maxLabeledEvents)
+	if err := registry.Register(labeledProblems); err != nil {
+		glog.Fatalf("Failed to register labeled problem collector: %v", err)
+	}
+	pe, err := prometheus.NewExporter(prometheus.Options{Registry: registry})
 	if err != nil {
 		glog.Fatalf("Failed to create Prometheus exporter: %v", err)
 	}
@@ -50,11 +58,11 @@ func NewExporterOrDie(npdo *options.NodeProblemDetectorOptions) types.Exporter {
 		}
 	}()
 	view.RegisterExporter(pe)
-	return &prometheusExporter{}
+	return &prometheusExporter{labeledProblems: labeledProblems}
 }
 
-// ExportProblems does nothing.
-// Prometheus exporter only exports metrics.
+// ExportProblems exports the labeled problems as metrics. The other problems are exported by
+// the problem metrics.
 func (pe *prometheusExporter) ExportProblems(status *types.Status) {
-	return
+	pe.labeledProblems.record(status)
 }
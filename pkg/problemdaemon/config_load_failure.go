@@ -0,0 +1,193 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package problemdaemon
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/node-problem-detector/pkg/types"
+	"k8s.io/node-problem-detector/pkg/util/tomb"
+)
+
+const (
+	// ConfigLoadFailedCondition is the condition reported when a problem daemon can't be created
+	// from its configuration file.
+	ConfigLoadFailedCondition = "ConfigLoadFailed"
+	// ConfigLoadFailedKey is the key of the monitor reporting the ConfigLoadFailed condition, and
+	// the source of the statuses it reports. The condition is aggregated over all configuration
+	// files, so that it's only cleared once none of them fails to load.
+	ConfigLoadFailedKey = "problem-daemon-config"
+	// configLoadFailedReason is the reason of the event and the condition reported when a
+	// problem daemon can't be created from its configuration file.
+	configLoadFailedReason = "InvalidConfig"
+	// configLoadRecoveredReason is the reason of the condition reported once no problem daemon
+	// is failing, i.e. the failing configuration files are fixed or removed.
+	configLoadRecoveredReason = "NoConfigLoadFailure"
+)
+
+// ConfigLoadFailureKey returns the key of the monitor reporting the load failure of the
+// configuration file, which is returned by NewProblemDaemons. It's different from the
+// configuration file path, so that the problem daemon still running with the previous
+// configuration is kept next to the monitor. The monitor is aggregated into the one keyed by
+// ConfigLoadFailedKey by ConfigLoadFailures.Update.
+func ConfigLoadFailureKey(configPath string) string {
+	return ConfigLoadFailedKey + ":" + configPath
+}
+
+// configLoadFailureMonitor reports the ConfigLoadFailed condition of the configuration files
+// failing to load when started. The condition is True if any configuration file is failing, and
+// False otherwise. Nothing is reported when the monitor is stopped, so that the condition is kept
+// across restarts of node problem detector.
+type configLoadFailureMonitor struct {
+	// failures are the errors of the failing configuration files, keyed by their paths.
+	failures map[string]error
+	// failed are the paths of the configuration files which just failed to load. An event is
+	// reported for each of them.
+	failed []string
+	tomb   *tomb.Tomb
+}
+
+// newConfigLoadFailureMonitor creates the monitor reporting that the problem daemon configured
+// by configPath can't be created because of err.
+func newConfigLoadFailureMonitor(configPath string, err error) *configLoadFailureMonitor {
+	return &configLoadFailureMonitor{
+		failures: map[string]error{configPath: err},
+		failed:   []string{configPath},
+		tomb:     tomb.NewTomb(),
+	}
+}
+
+func (c *configLoadFailureMonitor) Start() (<-chan *types.Status, error) {
+	glog.Infof("Start reporting the load failures of configurations %v", c.failed)
+	output := make(chan *types.Status, 1)
+	output <- c.status(time.Now())
+	go func() {
+		defer func() {
+			close(output)
+			c.tomb.Done()
+		}()
+		<-c.tomb.Stopping()
+	}()
+	return output, nil
+}
+
+func (c *configLoadFailureMonitor) Stop() {
+	glog.Infof("Stop reporting the load failures of configurations")
+	c.tomb.Stop()
+}
+
+// status generates the status reporting the ConfigLoadFailed condition, and an event for each
+// configuration file which just failed to load.
+func (c *configLoadFailureMonitor) status(timestamp time.Time) *types.Status {
+	var events []types.Event
+	for _, configPath := range c.failed {
+		events = append(events, types.Event{
+			Severity:  types.Warn,
+			Timestamp: timestamp,
+			Reason:    configLoadFailedReason,
+			Message:   fmt.Sprintf("Failed to load problem daemon configuration %s: %v", configPath, c.failures[configPath]),
+		})
+	}
+	condition := types.Condition{
+		Type:       ConfigLoadFailedCondition,
+		Status:     types.False,
+		Transition: timestamp,
+		Reason:     configLoadRecoveredReason,
+		Message:    "No problem daemon configuration is failing to load",
+	}
+	if len(c.failures) != 0 {
+		var configPaths []string
+		for configPath := range c.failures {
+			configPaths = append(configPaths, configPath)
+		}
+		sort.Strings(configPaths)
+		var messages []string
+		for _, configPath := range configPaths {
+			messages = append(messages, fmt.Sprintf("%s: %v", configPath, c.failures[configPath]))
+		}
+		condition.Status = types.True
+		condition.Reason = configLoadFailedReason
+		condition.Message = "Failed to load problem daemon configurations " + strings.Join(messages, "; ")
+	}
+	return &types.Status{
+		Source:     ConfigLoadFailedKey,
+		Events:     events,
+		Conditions: []types.Condition{condition},
+	}
+}
+
+// ConfigLoadFailures tracks the configuration files failing to load, so that the ConfigLoadFailed
+// condition is only cleared once all the failing configuration files are fixed or removed.
+type ConfigLoadFailures struct {
+	failing map[string]error
+}
+
+// NewConfigLoadFailures creates ConfigLoadFailures tracking the failures reported by the
+// monitors created by NewProblemDaemons, which are replaced as Update does.
+func NewConfigLoadFailures(monitors map[string]types.Monitor) *ConfigLoadFailures {
+	f := &ConfigLoadFailures{failing: make(map[string]error)}
+	f.Update(monitors, nil)
+	return f
+}
+
+// Update records the failures reported by the monitors created by NewProblemDaemons for the
+// changed configuration files, and the configuration files which are fixed or removed. The
+// monitors reporting the failures are replaced in monitors by a monitor reporting the aggregated
+// ConfigLoadFailed condition, keyed by ConfigLoadFailedKey, which is only added if the failing
+// configuration files change.
+func (f *ConfigLoadFailures) Update(monitors map[string]types.Monitor, removed []string) {
+	changed := false
+	var failed []string
+	for key, m := range monitors {
+		if failure, ok := m.(*configLoadFailureMonitor); ok {
+			delete(monitors, key)
+			for configPath, err := range failure.failures {
+				f.failing[configPath] = err
+			}
+			failed = append(failed, failure.failed...)
+			changed = true
+		} else if _, ok := f.failing[key]; ok {
+			// The problem daemon is created, so the configuration file is fixed.
+			delete(f.failing, key)
+			changed = true
+		}
+	}
+	for _, configPath := range removed {
+		if _, ok := f.failing[configPath]; ok {
+			delete(f.failing, configPath)
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	failures := make(map[string]error)
+	for configPath, err := range f.failing {
+		failures[configPath] = err
+	}
+	sort.Strings(failed)
+	monitors[ConfigLoadFailedKey] = &configLoadFailureMonitor{
+		failures: failures,
+		failed:   failed,
+		tomb:     tomb.NewTomb(),
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package problemdaemon
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/golang/glog"
+
+	"k8s.io/node-problem-detector/pkg/types"
+	"k8s.io/node-problem-detector/pkg/util"
+)
+
+// ConfigWatcher detects content changes of problem daemon configuration files, including the
+// configuration files added to or removed from the configuration directory.
+type ConfigWatcher struct {
+	monitorConfigPaths types.ProblemDaemonConfigPathMap
+	// configDir is the configuration directory, whose configuration files specify their problem
+	// daemon types. It is not scanned if empty.
+	configDir string
+	// The mutex protects configs and checksums.
+	sync.Mutex
+	// configs maps all valid configuration files to their problem daemon types.
+	configs map[string]types.ProblemDaemonType
+	// checksums are the checksums of the config files when they were last checked.
+	checksums map[string][sha256.Size]byte
+}
+
+// NewConfigWatcher creates a config watcher, which records the current content of all the
+// configuration files, and scans the configuration directory if it is not empty. It should be
+// created before the problem daemons are created, so that no change is missed.
+func NewConfigWatcher(monitorConfigPaths types.ProblemDaemonConfigPathMap, configDir string) *ConfigWatcher {
+	w := &ConfigWatcher{
+		monitorConfigPaths: monitorConfigPaths,
+		configDir:          configDir,
+		configs:            make(map[string]types.ProblemDaemonType),
+		checksums:          make(map[string][sha256.Size]byte),
+	}
+	w.Changed()
+	return w
+}
+
+// ConfigPaths returns all valid configuration files found in the last check.
+func (w *ConfigWatcher) ConfigPaths() types.ProblemDaemonConfigPathMap {
+	w.Lock()
+	defer w.Unlock()
+	configPaths := types.ProblemDaemonConfigPathMap{}
+	for config, problemDaemonType := range w.configs {
+		AppendConfigPath(configPaths, problemDaemonType, config)
+	}
+	for _, configs := range configPaths {
+		sort.Strings(*configs)
+	}
+	return configPaths
+}
+
+// Changed returns the configuration files whose content changed since the last check, and the
+// configuration files removed from the configuration directory. A configuration file which
+// can't be loaded is not considered as changed, so that the problem daemon running with the old
+// configuration is kept. Load errors are only reported once for each content of a file.
+func (w *ConfigWatcher) Changed() (types.ProblemDaemonConfigPathMap, []string) {
+	w.Lock()
+	defer w.Unlock()
+	changed := types.ProblemDaemonConfigPathMap{}
+	found := make(map[string]bool)
+	check := func(config string, problemDaemonType types.ProblemDaemonType) {
+		if found[config] {
+			// Skip the config if it's duplicated.
+			return
+		}
+		found[config] = true
+		data, err := ioutil.ReadFile(config)
+		if err != nil {
+			glog.Errorf("Failed to read configuration file %q: %v", config, err)
+			return
+		}
+		checksum := sha256.Sum256(data)
+		if old, ok := w.checksums[config]; ok && old == checksum {
+			return
+		}
+		w.checksums[config] = checksum
+		if problemDaemonType == "" {
+			if problemDaemonType, err = parseProblemDaemonType(config, data); err != nil {
+				glog.Errorf("Failed to load configuration file %q: %v", config, err)
+				return
+			}
+		}
+		w.configs[config] = problemDaemonType
+		AppendConfigPath(changed, problemDaemonType, config)
+	}
+
+	for problemDaemonType, configs := range w.monitorConfigPaths {
+		for _, config := range *configs {
+			check(config, problemDaemonType)
+		}
+	}
+	if w.configDir != "" {
+		configs, err := ListConfigDir(w.configDir)
+		if err != nil {
+			// Keep all problem daemons if the directory can't be listed.
+			glog.Errorf("Failed to list configuration directory %q: %v", w.configDir, err)
+			return changed, nil
+		}
+		for _, config := range configs {
+			check(config, "")
+		}
+	}
+
+	var removed []string
+	for config := range w.configs {
+		if !found[config] {
+			removed = append(removed, config)
+			delete(w.configs, config)
+			delete(w.checksums, config)
+		}
+	}
+	sort.Strings(removed)
+	return changed, removed
+}
+
+// ListConfigDir lists all configuration files in the directory, skipping hidden files and
+// sub-directories.
+func ListConfigDir(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var configs []string
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || name[0] == '.' || !util.IsConfigFile(name) {
+			continue
+		}
+		configs = append(configs, filepath.Join(dir, name))
+	}
+	return configs, nil
+}
+
+// AppendConfigPath adds the configuration file of the problem daemon type to configPaths.
+func AppendConfigPath(configPaths types.ProblemDaemonConfigPathMap, problemDaemonType types.ProblemDaemonType, config string) {
+	if configPaths[problemDaemonType] == nil {
+		configPaths[problemDaemonType] = &[]string{}
+	}
+	*configPaths[problemDaemonType] = append(*configPaths[problemDaemonType], config)
+}
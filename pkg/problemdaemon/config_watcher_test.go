@@ -0,0 +1,116 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package problemdaemon
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+func TestConfigWatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-watcher")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	fooConfig := filepath.Join(dir, "foo.json")
+	barConfig := filepath.Join(dir, "bar.json")
+	for _, config := range []string{fooConfig, barConfig} {
+		if err := ioutil.WriteFile(config, []byte("{}"), 0644); err != nil {
+			t.Fatalf("Failed to write config %q: %v", config, err)
+		}
+	}
+
+	w := NewConfigWatcher(types.ProblemDaemonConfigPathMap{
+		"foo": &[]string{fooConfig},
+		"bar": &[]string{barConfig},
+	}, "")
+	changed, removed := w.Changed()
+	assert.Empty(t, changed, "Nothing should change right after the watcher is created")
+	assert.Empty(t, removed, "Nothing should be removed right after the watcher is created")
+
+	if err := ioutil.WriteFile(fooConfig, []byte(`{"source": "foo"}`), 0644); err != nil {
+		t.Fatalf("Failed to update config %q: %v", fooConfig, err)
+	}
+	if err := ioutil.WriteFile(barConfig, []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to rewrite config %q: %v", barConfig, err)
+	}
+	changed, _ = w.Changed()
+	assert.Equal(t, types.ProblemDaemonConfigPathMap{"foo": &[]string{fooConfig}}, changed,
+		"Only the config with content change should be reported")
+	changed, _ = w.Changed()
+	assert.Empty(t, changed, "A change should only be reported once")
+
+	if err := os.Remove(barConfig); err != nil {
+		t.Fatalf("Failed to remove config %q: %v", barConfig, err)
+	}
+	changed, removed = w.Changed()
+	assert.Empty(t, changed, "A config which can't be read should not be reported")
+	assert.Empty(t, removed, "A config which can't be read should not be removed")
+}
+
+func TestConfigWatcherWithConfigDir(t *testing.T) {
+	Register("foo", types.ProblemDaemonHandler{})
+	defer delete(handlers, "foo")
+
+	dir, err := ioutil.TempDir("", "config-dir")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	writeConfig := func(name, content string) string {
+		config := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(config, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write config %q: %v", config, err)
+		}
+		return config
+	}
+
+	fooConfig := writeConfig("foo.json", `{"problemDaemonType": "foo"}`)
+	writeConfig("unknown.json", `{"problemDaemonType": "unknown"}`)
+	writeConfig("untyped.json", `{"source": "untyped"}`)
+	writeConfig("invalid.json", `{`)
+	writeConfig("README.md", `foo`)
+	writeConfig(".hidden.json", `{"problemDaemonType": "foo"}`)
+
+	w := NewConfigWatcher(types.ProblemDaemonConfigPathMap{}, dir)
+	assert.Equal(t, types.ProblemDaemonConfigPathMap{"foo": &[]string{fooConfig}}, w.ConfigPaths(),
+		"Only valid config files should be loaded")
+
+	barConfig := writeConfig("bar.yaml", "# YAML config.\nproblemDaemonType: foo\nsource: bar\n")
+	writeConfig("foo.json", `{`)
+	changed, removed := w.Changed()
+	assert.Equal(t, types.ProblemDaemonConfigPathMap{"foo": &[]string{barConfig}}, changed,
+		"Added config should be reported")
+	assert.Empty(t, removed, "A config which can't be loaded should not be removed")
+	assert.Equal(t, types.ProblemDaemonConfigPathMap{"foo": &[]string{barConfig, fooConfig}}, w.ConfigPaths())
+
+	if err := os.Remove(fooConfig); err != nil {
+		t.Fatalf("Failed to remove config %q: %v", fooConfig, err)
+	}
+	changed, removed = w.Changed()
+	assert.Empty(t, changed)
+	assert.Equal(t, []string{fooConfig}, removed, "Removed config should be reported")
+	assert.Equal(t, types.ProblemDaemonConfigPathMap{"foo": &[]string{barConfig}}, w.ConfigPaths())
+}
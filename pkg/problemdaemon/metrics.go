@@ -0,0 +1,42 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package problemdaemon
+
+import (
+	"github.com/golang/glog"
+
+	"k8s.io/node-problem-detector/pkg/util/metrics"
+)
+
+var (
+	// configLoadFailureCounter counts the failures to create problem daemons from their
+	// configuration files.
+	configLoadFailureCounter metrics.Int64MetricInterface
+)
+
+func init() {
+	var err error
+	configLoadFailureCounter, err = metrics.NewInt64Metric(
+		"problem_daemon_config_load_failure_counter",
+		"Number of times a problem daemon can't be created from its configuration file.",
+		"1",
+		metrics.Sum,
+		[]string{"problem_daemon_type", "config"})
+	if err != nil {
+		glog.Fatalf("Failed to create problem_daemon_config_load_failure_counter metric: %v", err)
+	}
+}
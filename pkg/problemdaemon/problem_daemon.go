@@ -18,10 +18,13 @@ package problemdaemon
 
 import (
 	"fmt"
+	"io/ioutil"
 
 	"github.com/golang/glog"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 
 	"k8s.io/node-problem-detector/pkg/types"
+	"k8s.io/node-problem-detector/pkg/util"
 )
 
 var (
@@ -51,23 +54,97 @@ func GetProblemDaemonHandlerOrDie(problemDaemonType types.ProblemDaemonType) typ
 	return handler
 }
 
+// parseProblemDaemonType parses the problem daemon type from the "problemDaemonType" field of
+// a configuration file.
+func parseProblemDaemonType(path string, data []byte) (types.ProblemDaemonType, error) {
+	var config struct {
+		ProblemDaemonType types.ProblemDaemonType `json:"problemDaemonType"`
+	}
+	if err := util.UnmarshalConfig(path, data, &config); err != nil {
+		return "", err
+	}
+	if config.ProblemDaemonType == "" {
+		return "", fmt.Errorf("problemDaemonType is not specified")
+	}
+	if _, ok := handlers[config.ProblemDaemonType]; !ok {
+		return "", fmt.Errorf("problem daemon type %q is not supported", config.ProblemDaemonType)
+	}
+	return config.ProblemDaemonType, nil
+}
+
+// ConfigType reads the problem daemon type specified in the configuration file.
+func ConfigType(configPath string) (types.ProblemDaemonType, error) {
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return "", err
+	}
+	return parseProblemDaemonType(configPath, data)
+}
+
+// ValidateConfig validates the configuration file of the type of problem daemon without creating
+// the problem daemon.
+func ValidateConfig(problemDaemonType types.ProblemDaemonType, configPath string) error {
+	handler, ok := handlers[problemDaemonType]
+	if !ok {
+		return fmt.Errorf("problem daemon type %q is not supported", problemDaemonType)
+	}
+	if handler.ValidateConfig == nil {
+		return nil
+	}
+	return handler.ValidateConfig(configPath)
+}
+
+// NewProblemDaemon creates the problem daemon configured by configPath, which is one of the
+// config paths in monitorConfigPaths. Returns nil if configPath is not found, and error if the
+// problem daemon can't be created.
+func NewProblemDaemon(monitorConfigPaths types.ProblemDaemonConfigPathMap, configPath string) (types.Monitor, error) {
+	for problemDaemonType, configs := range monitorConfigPaths {
+		for _, config := range *configs {
+			if config == configPath {
+				return handlers[problemDaemonType].CreateProblemDaemon(config)
+			}
+		}
+	}
+	return nil, nil
+}
+
 // NewProblemDaemons creates all problem daemons based on the configurations provided.
-func NewProblemDaemons(monitorConfigPaths types.ProblemDaemonConfigPathMap) []types.Monitor {
+// The problem daemons are keyed by their config paths. The configurations which fail to create
+// problem daemons are skipped, and a monitor reporting the ConfigLoadFailed condition is returned
+// for each of them, keyed by ConfigLoadFailureKey, to be aggregated by ConfigLoadFailures. Returns
+// error only if no problem daemon can be created.
+func NewProblemDaemons(monitorConfigPaths types.ProblemDaemonConfigPathMap) (map[string]types.Monitor, error) {
 	problemDaemonMap := make(map[string]types.Monitor)
+	var errs []error
 	for problemDaemonType, configs := range monitorConfigPaths {
 		for _, config := range *configs {
-			if _, ok := problemDaemonMap[config]; ok {
+			_, created := problemDaemonMap[config]
+			if _, failed := problemDaemonMap[ConfigLoadFailureKey(config)]; created || failed {
 				// Skip the config if it's duplicated.
 				glog.Warningf("Duplicated problem daemon configuration %q", config)
 				continue
 			}
-			problemDaemonMap[config] = handlers[problemDaemonType].CreateProblemDaemonOrDie(config)
+			m, err := handlers[problemDaemonType].CreateProblemDaemon(config)
+			if err != nil {
+				glog.Errorf("Failed to create %v with configuration %q, skipping it: %v", problemDaemonType, config, err)
+				recordConfigLoadFailure(problemDaemonType, config)
+				errs = append(errs, err)
+				problemDaemonMap[ConfigLoadFailureKey(config)] = newConfigLoadFailureMonitor(config, err)
+				continue
+			}
+			problemDaemonMap[config] = m
 		}
 	}
+	if len(errs) != 0 && len(errs) == len(problemDaemonMap) {
+		return problemDaemonMap, fmt.Errorf("no problem daemon can be created: %v", utilerrors.NewAggregate(errs))
+	}
+	return problemDaemonMap, nil
+}
 
-	problemDaemons := []types.Monitor{}
-	for _, problemDaemon := range problemDaemonMap {
-		problemDaemons = append(problemDaemons, problemDaemon)
+// recordConfigLoadFailure counts a failure to create a problem daemon from the configuration.
+func recordConfigLoadFailure(problemDaemonType types.ProblemDaemonType, configPath string) {
+	labels := map[string]string{"problem_daemon_type": string(problemDaemonType), "config": configPath}
+	if err := configLoadFailureCounter.Record(labels, 1); err != nil {
+		glog.Errorf("Failed to update config load failure counter for %q: %v", configPath, err)
 	}
-	return problemDaemons
 }
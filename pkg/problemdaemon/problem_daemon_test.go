@@ -17,6 +17,7 @@ limitations under the License.
 package problemdaemon
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -25,20 +26,20 @@ import (
 )
 
 func TestRegistration(t *testing.T) {
-	fooMonitorFactory := func(configPath string) types.Monitor {
-		return nil
+	fooMonitorFactory := func(configPath string) (types.Monitor, error) {
+		return nil, nil
 	}
 	fooMonitorHandler := types.ProblemDaemonHandler{
-		CreateProblemDaemonOrDie: fooMonitorFactory,
-		CmdOptionDescription:     "foo option",
+		CreateProblemDaemon:  fooMonitorFactory,
+		CmdOptionDescription: "foo option",
 	}
 
-	barMonitorFactory := func(configPath string) types.Monitor {
-		return nil
+	barMonitorFactory := func(configPath string) (types.Monitor, error) {
+		return nil, nil
 	}
 	barMonitorHandler := types.ProblemDaemonHandler{
-		CreateProblemDaemonOrDie: barMonitorFactory,
-		CmdOptionDescription:     "bar option",
+		CreateProblemDaemon:  barMonitorFactory,
+		CmdOptionDescription: "bar option",
 	}
 
 	Register("foo", fooMonitorHandler)
@@ -55,12 +56,12 @@ func TestRegistration(t *testing.T) {
 }
 
 func TestGetProblemDaemonHandlerOrDie(t *testing.T) {
-	fooMonitorFactory := func(configPath string) types.Monitor {
-		return nil
+	fooMonitorFactory := func(configPath string) (types.Monitor, error) {
+		return nil, nil
 	}
 	fooMonitorHandler := types.ProblemDaemonHandler{
-		CreateProblemDaemonOrDie: fooMonitorFactory,
-		CmdOptionDescription:     "foo option",
+		CreateProblemDaemon:  fooMonitorFactory,
+		CmdOptionDescription: "foo option",
 	}
 
 	Register("foo", fooMonitorHandler)
@@ -70,3 +71,118 @@ func TestGetProblemDaemonHandlerOrDie(t *testing.T) {
 
 	handlers = make(map[types.ProblemDaemonType]types.ProblemDaemonHandler)
 }
+
+type fakeMonitor struct{}
+
+func (f *fakeMonitor) Start() (<-chan *types.Status, error) { return nil, nil }
+
+func (f *fakeMonitor) Stop() {}
+
+func TestNewProblemDaemons(t *testing.T) {
+	Register("foo", types.ProblemDaemonHandler{
+		CreateProblemDaemon: func(configPath string) (types.Monitor, error) {
+			if configPath == "broken.json" {
+				return nil, fmt.Errorf("invalid configuration")
+			}
+			return &fakeMonitor{}, nil
+		},
+	})
+	defer func() { handlers = make(map[types.ProblemDaemonType]types.ProblemDaemonHandler) }()
+
+	problemDaemons, err := NewProblemDaemons(types.ProblemDaemonConfigPathMap{
+		"foo": &[]string{"good.json", "broken.json"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, problemDaemons, 2)
+	assert.IsType(t, &fakeMonitor{}, problemDaemons["good.json"])
+
+	// The broken configuration is reported by the ConfigLoadFailed condition, which is kept
+	// when the monitor is stopped.
+	ch, err := problemDaemons["problem-daemon-config:broken.json"].Start()
+	assert.NoError(t, err)
+	failed := <-ch
+	assert.Equal(t, "problem-daemon-config", failed.Source)
+	assert.Len(t, failed.Events, 1)
+	if assert.Len(t, failed.Conditions, 1) {
+		assert.Equal(t, ConfigLoadFailedCondition, failed.Conditions[0].Type)
+		assert.Equal(t, types.True, failed.Conditions[0].Status)
+		assert.Contains(t, failed.Conditions[0].Message, "invalid configuration")
+	}
+	problemDaemons["problem-daemon-config:broken.json"].Stop()
+	_, ok := <-ch
+	assert.False(t, ok, "status channel should be closed without recovery")
+
+	// Fail if no problem daemon can be created.
+	problemDaemons, err = NewProblemDaemons(types.ProblemDaemonConfigPathMap{
+		"foo": &[]string{"broken.json"},
+	})
+	assert.Error(t, err)
+	assert.Len(t, problemDaemons, 1)
+}
+
+// startConfigLoadFailureMonitor starts the monitor reporting the aggregated ConfigLoadFailed
+// condition, and returns the status it reports.
+func startConfigLoadFailureMonitor(t *testing.T, monitors map[string]types.Monitor) *types.Status {
+	m, ok := monitors[ConfigLoadFailedKey]
+	if !ok {
+		t.Fatalf("Monitor reporting ConfigLoadFailed condition is not found in %v", monitors)
+	}
+	ch, err := m.Start()
+	if err != nil {
+		t.Fatalf("Failed to start monitor: %v", err)
+	}
+	defer m.Stop()
+	status := <-ch
+	assert.Equal(t, ConfigLoadFailedKey, status.Source)
+	if assert.Len(t, status.Conditions, 1) {
+		assert.Equal(t, ConfigLoadFailedCondition, status.Conditions[0].Type)
+	}
+	return status
+}
+
+func TestConfigLoadFailures(t *testing.T) {
+	monitors := map[string]types.Monitor{
+		"good.json":                          &fakeMonitor{},
+		"problem-daemon-config:broken.json":  newConfigLoadFailureMonitor("broken.json", fmt.Errorf("invalid configuration")),
+		"problem-daemon-config:removed.json": newConfigLoadFailureMonitor("removed.json", fmt.Errorf("invalid configuration")),
+	}
+	failures := NewConfigLoadFailures(monitors)
+	assert.Len(t, monitors, 2, "the failures should be reported by a single monitor")
+	status := startConfigLoadFailureMonitor(t, monitors)
+	assert.Len(t, status.Events, 2)
+	assert.Equal(t, types.True, status.Conditions[0].Status)
+	assert.Equal(t, "Failed to load problem daemon configurations broken.json: invalid configuration; "+
+		"removed.json: invalid configuration", status.Conditions[0].Message)
+
+	// Nothing is reported if the failing configurations don't change.
+	monitors = map[string]types.Monitor{"good.json": &fakeMonitor{}}
+	failures.Update(monitors, []string{"other.json"})
+	assert.NotContains(t, monitors, ConfigLoadFailedKey)
+
+	// The condition is still True while one of the broken configurations is fixed.
+	monitors = map[string]types.Monitor{"broken.json": &fakeMonitor{}}
+	failures.Update(monitors, nil)
+	status = startConfigLoadFailureMonitor(t, monitors)
+	assert.Empty(t, status.Events)
+	assert.Equal(t, types.True, status.Conditions[0].Status)
+	assert.Equal(t, "Failed to load problem daemon configurations removed.json: invalid configuration", status.Conditions[0].Message)
+
+	// The good configuration is broken by a change.
+	monitors = map[string]types.Monitor{
+		"problem-daemon-config:good.json": newConfigLoadFailureMonitor("good.json", fmt.Errorf("invalid configuration")),
+	}
+	failures.Update(monitors, nil)
+	status = startConfigLoadFailureMonitor(t, monitors)
+	if assert.Len(t, status.Events, 1) {
+		assert.Equal(t, "Failed to load problem daemon configuration good.json: invalid configuration", status.Events[0].Message)
+	}
+	assert.Equal(t, types.True, status.Conditions[0].Status)
+
+	// The condition is only False once all the broken configurations are fixed or removed.
+	monitors = map[string]types.Monitor{"good.json": &fakeMonitor{}}
+	failures.Update(monitors, []string{"removed.json"})
+	status = startConfigLoadFailureMonitor(t, monitors)
+	assert.Empty(t, status.Events)
+	assert.Equal(t, types.False, status.Conditions[0].Status)
+	assert.Equal(t, "NoConfigLoadFailure", status.Conditions[0].Reason)
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package problemdetector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+// statusRanks rank the condition statuses for each merge policy, a higher ranked status wins.
+var statusRanks = map[types.MergePolicy]map[types.ConditionStatus]int{
+	types.MergePolicyAnyTrue:         {types.Unknown: 0, types.False: 1, types.True: 2},
+	types.MergePolicyHighestSeverity: {types.False: 0, types.Unknown: 1, types.True: 2},
+}
+
+// ConditionMerger merges the conditions of the same type reported by different problem daemons,
+// so that they don't overwrite each other. The problem daemons are identified by their config
+// paths, because several of them may report the same source. ConditionMerger is not thread-safe.
+type ConditionMerger struct {
+	policy types.MergePolicy
+	// owners maps a condition type to the source which owns it. The condition reported by the
+	// owner is always exported, regardless of the policy.
+	owners map[string]string
+	// conditions are the latest conditions reported by each problem daemon, keyed by condition
+	// type and then config path.
+	conditions map[string]map[string]reportedCondition
+}
+
+// reportedCondition is a condition reported by a problem daemon.
+type reportedCondition struct {
+	source    string
+	condition types.Condition
+}
+
+// NewConditionMerger creates a condition merger with the policy, and the owner sources of
+// condition types.
+func NewConditionMerger(policy types.MergePolicy, owners map[string]string) *ConditionMerger {
+	return &ConditionMerger{
+		policy:     policy,
+		owners:     owners,
+		conditions: make(map[string]map[string]reportedCondition),
+	}
+}
+
+// Merge records the conditions of the status reported by the problem daemon started with the
+// config path, and returns the status with each of its conditions replaced with the merged
+// condition of the same type. The passed in status is never modified.
+func (m *ConditionMerger) Merge(configPath string, status *types.Status) *types.Status {
+	if status.Conditions == nil {
+		return status
+	}
+	merged := &types.Status{
+		Source:     status.Source,
+		Events:     status.Events,
+		Conditions: make([]types.Condition, 0, len(status.Conditions)),
+	}
+	for _, condition := range status.Conditions {
+		reported, ok := m.conditions[condition.Type]
+		if !ok {
+			reported = make(map[string]reportedCondition)
+			m.conditions[condition.Type] = reported
+		}
+		reported[configPath] = reportedCondition{source: status.Source, condition: condition}
+		merged.Conditions = append(merged.Conditions, m.merge(condition.Type))
+	}
+	return merged
+}
+
+// Forget forgets the conditions reported by the problem daemon started with the config path, so
+// that they are no longer merged.
+func (m *ConditionMerger) Forget(configPath string) {
+	for conditionType, reported := range m.conditions {
+		delete(reported, configPath)
+		if len(reported) == 0 {
+			delete(m.conditions, conditionType)
+		}
+	}
+}
+
+// merge returns the merged condition of the condition type.
+func (m *ConditionMerger) merge(conditionType string) types.Condition {
+	reported := m.conditions[conditionType]
+	configPaths := make([]string, 0, len(reported))
+	for configPath := range reported {
+		configPaths = append(configPaths, configPath)
+	}
+	sort.Strings(configPaths)
+
+	// Only the conditions reported by the owner are merged once the owner reports.
+	owner, ownerReported := m.owners[conditionType]
+	if ownerReported {
+		ownerReported = false
+		for _, r := range reported {
+			if r.source == owner {
+				ownerReported = true
+			}
+		}
+	}
+	var winner string
+	ranks := statusRanks[m.policy]
+	for _, configPath := range configPaths {
+		if ownerReported && reported[configPath].source != owner {
+			continue
+		}
+		if winner == "" {
+			winner = configPath
+			continue
+		}
+		condition, best := reported[configPath].condition, reported[winner].condition
+		if ranks[condition.Status] > ranks[best.Status] ||
+			// Among the same status, prefer the one which has been in it the longest.
+			ranks[condition.Status] == ranks[best.Status] && condition.Transition.Before(best.Transition) {
+			winner = configPath
+		}
+	}
+
+	condition := reported[winner].condition
+	if len(reported) > 1 {
+		contributed := make(map[string]bool)
+		var contributors []string
+		for _, r := range reported {
+			if r.condition.Status == condition.Status && !contributed[r.source] {
+				contributed[r.source] = true
+				contributors = append(contributors, r.source)
+			}
+		}
+		sort.Strings(contributors)
+		condition.Message = fmt.Sprintf("%s (reported by %s)", condition.Message, strings.Join(contributors, ", "))
+	}
+	return condition
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package problemdetector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+func TestConditionMerger(t *testing.T) {
+	now := time.Now()
+	newCondition := func(status types.ConditionStatus, reason string, transition time.Time) types.Condition {
+		return types.Condition{
+			Type:       "ReadonlyFilesystem",
+			Status:     status,
+			Transition: transition,
+			Reason:     reason,
+			Message:    reason + " message",
+		}
+	}
+	type report struct {
+		configPath string
+		source     string
+		condition  types.Condition
+	}
+	for desc, test := range map[string]struct {
+		policy   types.MergePolicy
+		owners   map[string]string
+		reports  []report
+		expected types.Condition
+	}{
+		"single source should be exported as it is": {
+			policy:   types.MergePolicyAnyTrue,
+			reports:  []report{{"foo.json", "foo", newCondition(types.True, "Foo", now)}},
+			expected: newCondition(types.True, "Foo", now),
+		},
+		"any-true should not be overwritten by a later False": {
+			policy: types.MergePolicyAnyTrue,
+			reports: []report{
+				{"foo.json", "foo", newCondition(types.True, "Foo", now)},
+				{"bar.json", "bar", newCondition(types.False, "Bar", now)},
+			},
+			expected: types.Condition{
+				Type:       "ReadonlyFilesystem",
+				Status:     types.True,
+				Transition: now,
+				Reason:     "Foo",
+				Message:    "Foo message (reported by foo)",
+			},
+		},
+		"any-true should prefer False over Unknown": {
+			policy: types.MergePolicyAnyTrue,
+			reports: []report{
+				{"foo.json", "foo", newCondition(types.False, "Foo", now)},
+				{"bar.json", "bar", newCondition(types.Unknown, "Bar", now)},
+			},
+			expected: types.Condition{
+				Type:       "ReadonlyFilesystem",
+				Status:     types.False,
+				Transition: now,
+				Reason:     "Foo",
+				Message:    "Foo message (reported by foo)",
+			},
+		},
+		"highest-severity should prefer Unknown over False": {
+			policy: types.MergePolicyHighestSeverity,
+			reports: []report{
+				{"foo.json", "foo", newCondition(types.False, "Foo", now)},
+				{"bar.json", "bar", newCondition(types.Unknown, "Bar", now)},
+			},
+			expected: types.Condition{
+				Type:       "ReadonlyFilesystem",
+				Status:     types.Unknown,
+				Transition: now,
+				Reason:     "Bar",
+				Message:    "Bar message (reported by bar)",
+			},
+		},
+		"same status should prefer the earliest transition and list all contributors": {
+			policy: types.MergePolicyAnyTrue,
+			reports: []report{
+				{"foo.json", "foo", newCondition(types.True, "Foo", now)},
+				{"bar.json", "bar", newCondition(types.True, "Bar", now.Add(-time.Minute))},
+			},
+			expected: types.Condition{
+				Type:       "ReadonlyFilesystem",
+				Status:     types.True,
+				Transition: now.Add(-time.Minute),
+				Reason:     "Bar",
+				Message:    "Bar message (reported by bar, foo)",
+			},
+		},
+		"owner should always win": {
+			policy: types.MergePolicyAnyTrue,
+			owners: map[string]string{"ReadonlyFilesystem": "bar"},
+			reports: []report{
+				{"foo.json", "foo", newCondition(types.True, "Foo", now)},
+				{"bar.json", "bar", newCondition(types.False, "Bar", now)},
+				{"foo.json", "foo", newCondition(types.True, "Foo", now)},
+			},
+			expected: types.Condition{
+				Type:       "ReadonlyFilesystem",
+				Status:     types.False,
+				Transition: now,
+				Reason:     "Bar",
+				Message:    "Bar message (reported by bar)",
+			},
+		},
+		"problem daemons reporting the same source should not overwrite each other": {
+			policy: types.MergePolicyAnyTrue,
+			reports: []report{
+				{"foo.json", "foo", newCondition(types.True, "Foo", now)},
+				{"foo-filelog.json", "foo", newCondition(types.False, "Foo", now)},
+			},
+			expected: types.Condition{
+				Type:       "ReadonlyFilesystem",
+				Status:     types.True,
+				Transition: now,
+				Reason:     "Foo",
+				Message:    "Foo message (reported by foo)",
+			},
+		},
+		"policy should apply before the owner reports": {
+			policy: types.MergePolicyAnyTrue,
+			owners: map[string]string{"ReadonlyFilesystem": "baz"},
+			reports: []report{
+				{"foo.json", "foo", newCondition(types.True, "Foo", now)},
+				{"bar.json", "bar", newCondition(types.False, "Bar", now)},
+			},
+			expected: types.Condition{
+				Type:       "ReadonlyFilesystem",
+				Status:     types.True,
+				Transition: now,
+				Reason:     "Foo",
+				Message:    "Foo message (reported by foo)",
+			},
+		},
+	} {
+		m := NewConditionMerger(test.policy, test.owners)
+		var merged *types.Status
+		for _, r := range test.reports {
+			status := &types.Status{Source: r.source, Conditions: []types.Condition{r.condition}}
+			merged = m.Merge(r.configPath, status)
+			assert.Equal(t, []types.Condition{r.condition}, status.Conditions, "%s: status should not be modified", desc)
+		}
+		assert.Equal(t, []types.Condition{test.expected}, merged.Conditions, desc)
+	}
+}
+
+func TestConditionMergerWithoutConditions(t *testing.T) {
+	m := NewConditionMerger(types.MergePolicyAnyTrue, nil)
+	status := &types.Status{Source: "foo", Events: []types.Event{{Reason: "Foo"}}}
+	assert.Equal(t, status, m.Merge("foo.json", status))
+}
+
+func TestConditionMergerForget(t *testing.T) {
+	now := time.Now()
+	m := NewConditionMerger(types.MergePolicyAnyTrue, nil)
+	m.Merge("foo.json", &types.Status{Source: "foo", Conditions: []types.Condition{
+		{Type: "ReadonlyFilesystem", Status: types.True, Transition: now, Reason: "Foo", Message: "Foo message"},
+	}})
+	m.Forget("foo.json")
+	merged := m.Merge("bar.json", &types.Status{Source: "bar", Conditions: []types.Condition{
+		{Type: "ReadonlyFilesystem", Status: types.False, Transition: now, Reason: "Bar", Message: "Bar message"},
+	}})
+	assert.Equal(t, []types.Condition{
+		{Type: "ReadonlyFilesystem", Status: types.False, Transition: now, Reason: "Bar", Message: "Bar message"},
+	}, merged.Conditions, "forgotten problem daemon should not win")
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package problemdetector
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+// exporterQueue exports statuses with an exporter asynchronously, so that a slow exporter
+// doesn't block the others.
+type exporterQueue struct {
+	exporter types.Exporter
+	name     string
+	size     int
+	policy   types.OverflowPolicy
+
+	// The mutex protects statuses and exporting.
+	sync.Mutex
+	// changed is broadcast whenever a status is queued or exported.
+	changed  *sync.Cond
+	statuses []*types.Status
+	// exporting is true when the worker is exporting a status taken from the queue.
+	exporting bool
+}
+
+// NewExporterQueue wraps the exporter with a queue of the size, and a worker exporting the
+// queued statuses in the background.
+func NewExporterQueue(exporter types.Exporter, size int, policy types.OverflowPolicy) types.FlushableExporter {
+	q := &exporterQueue{
+		exporter: exporter,
+		name:     strings.TrimPrefix(fmt.Sprintf("%T", exporter), "*"),
+		size:     size,
+		policy:   policy,
+	}
+	q.changed = sync.NewCond(&q.Mutex)
+	go q.worker()
+	return q
+}
+
+// ExportProblems queues the status, and handles overflow according to the overflow policy.
+func (q *exporterQueue) ExportProblems(status *types.Status) {
+	q.Lock()
+	defer q.Unlock()
+	if len(q.statuses) >= q.size {
+		switch q.policy {
+		case types.OverflowPolicyBlock:
+			for len(q.statuses) >= q.size {
+				q.changed.Wait()
+			}
+		case types.OverflowPolicyCoalesce:
+			if q.coalesce(status) {
+				q.changed.Broadcast()
+				return
+			}
+			q.dropOldest()
+		default:
+			q.dropOldest()
+		}
+	}
+	q.statuses = append(q.statuses, status)
+	q.recordLength()
+	q.changed.Broadcast()
+}
+
+// Flush waits until all queued statuses are exported, and then flushes the exporter.
+func (q *exporterQueue) Flush() {
+	q.Lock()
+	for len(q.statuses) != 0 || q.exporting {
+		q.changed.Wait()
+	}
+	q.Unlock()
+	if flusher, ok := q.exporter.(types.FlushableExporter); ok {
+		flusher.Flush()
+	}
+}
+
+// worker exports the queued statuses one by one.
+func (q *exporterQueue) worker() {
+	for {
+		q.Lock()
+		for len(q.statuses) == 0 {
+			q.changed.Wait()
+		}
+		status := q.statuses[0]
+		q.statuses[0] = nil
+		q.statuses = q.statuses[1:]
+		q.exporting = true
+		q.recordLength()
+		q.changed.Broadcast()
+		q.Unlock()
+
+		q.exporter.ExportProblems(status)
+
+		q.Lock()
+		q.exporting = false
+		q.changed.Broadcast()
+		q.Unlock()
+	}
+}
+
+// coalesce merges the status into the latest queued status from the same source. The queued
+// conditions are kept if the status doesn't report conditions. Returns false if there is no
+// such status. The caller should hold the lock.
+func (q *exporterQueue) coalesce(status *types.Status) bool {
+	for i := len(q.statuses) - 1; i >= 0; i-- {
+		queued := q.statuses[i]
+		if queued.Source != status.Source {
+			continue
+		}
+		// The statuses are shared with other exporters, so never modify them in place.
+		events := make([]types.Event, 0, len(queued.Events)+len(status.Events))
+		events = append(events, queued.Events...)
+		events = append(events, status.Events...)
+		conditions := status.Conditions
+		if conditions == nil {
+			conditions = queued.Conditions
+		}
+		q.statuses[i] = &types.Status{
+			Source:     status.Source,
+			Events:     events,
+			Conditions: conditions,
+		}
+		return true
+	}
+	return false
+}
+
+// dropOldest drops the oldest queued status. The caller should hold the lock.
+func (q *exporterQueue) dropOldest() {
+	glog.Warningf("Exporter queue of %s is full, dropping status %+v", q.name, q.statuses[0])
+	q.statuses[0] = nil
+	q.statuses = q.statuses[1:]
+	if err := queueDropCounter.Record(map[string]string{"exporter": q.name}, 1); err != nil {
+		glog.Errorf("Failed to update exporter queue drop counter for %s: %v", q.name, err)
+	}
+}
+
+// recordLength records the current queue length. The caller should hold the lock.
+func (q *exporterQueue) recordLength() {
+	if err := queueLengthGauge.Record(map[string]string{"exporter": q.name}, int64(len(q.statuses))); err != nil {
+		glog.Errorf("Failed to update exporter queue length for %s: %v", q.name, err)
+	}
+}
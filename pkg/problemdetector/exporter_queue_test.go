@@ -0,0 +1,144 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package problemdetector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+// slowExporter blocks on exporting each status until it is released.
+type slowExporter struct {
+	fakeExporter
+	exporting chan *types.Status
+	release   chan struct{}
+}
+
+func newSlowExporter() *slowExporter {
+	return &slowExporter{
+		exporting: make(chan *types.Status, 100),
+		release:   make(chan struct{}),
+	}
+}
+
+func (s *slowExporter) ExportProblems(status *types.Status) {
+	s.exporting <- status
+	<-s.release
+	s.fakeExporter.ExportProblems(status)
+}
+
+// fillQueue blocks the worker of the queue on exporting the first status, and then queues
+// the rest statuses.
+func fillQueue(t *testing.T, q types.Exporter, e *slowExporter, statuses []*types.Status) {
+	q.ExportProblems(statuses[0])
+	select {
+	case <-e.exporting:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The first status is not exported")
+	}
+	for _, status := range statuses[1:] {
+		q.ExportProblems(status)
+	}
+}
+
+func TestExporterQueueOverflow(t *testing.T) {
+	foo1 := &types.Status{Source: "foo", Events: []types.Event{{Reason: "Foo1"}}}
+	foo2 := &types.Status{Source: "foo", Events: []types.Event{{Reason: "Foo2"}}}
+	foo3 := &types.Status{Source: "foo", Events: []types.Event{{Reason: "Foo3"}},
+		Conditions: []types.Condition{{Type: "FooProblem", Status: types.True}}}
+	foo4 := &types.Status{Source: "foo", Events: []types.Event{{Reason: "Foo4"}}}
+	bar := &types.Status{Source: "bar", Events: []types.Event{{Reason: "Bar"}}}
+	for desc, test := range map[string]struct {
+		policy   types.OverflowPolicy
+		statuses []*types.Status
+		expected []*types.Status
+	}{
+		"drop-oldest should drop the oldest queued status": {
+			policy:   types.OverflowPolicyDropOldest,
+			statuses: []*types.Status{foo1, foo2, bar, foo3},
+			expected: []*types.Status{foo1, bar, foo3},
+		},
+		"coalesce should merge the status from the same source": {
+			policy:   types.OverflowPolicyCoalesce,
+			statuses: []*types.Status{foo1, foo2, bar, foo3},
+			expected: []*types.Status{
+				foo1,
+				{
+					Source:     "foo",
+					Events:     []types.Event{{Reason: "Foo2"}, {Reason: "Foo3"}},
+					Conditions: foo3.Conditions,
+				},
+				bar,
+			},
+		},
+		"coalesce should keep the queued conditions when merging the status without conditions": {
+			policy:   types.OverflowPolicyCoalesce,
+			statuses: []*types.Status{foo1, foo3, bar, foo4},
+			expected: []*types.Status{
+				foo1,
+				{
+					Source:     "foo",
+					Events:     []types.Event{{Reason: "Foo3"}, {Reason: "Foo4"}},
+					Conditions: foo3.Conditions,
+				},
+				bar,
+			},
+		},
+		"coalesce should drop the oldest status without status from the same source": {
+			policy:   types.OverflowPolicyCoalesce,
+			statuses: []*types.Status{foo1, foo2, foo3, bar},
+			expected: []*types.Status{foo1, foo3, bar},
+		},
+	} {
+		e := newSlowExporter()
+		q := NewExporterQueue(e, 2, test.policy)
+		fillQueue(t, q, e, test.statuses)
+		close(e.release)
+		q.Flush()
+		assert.Equal(t, test.expected, e.statuses, desc)
+		assert.True(t, e.flushed, desc)
+		// The queued statuses should never be modified.
+		assert.Equal(t, []types.Event{{Reason: "Foo2"}}, foo2.Events, desc)
+	}
+}
+
+func TestExporterQueueBlock(t *testing.T) {
+	statuses := []*types.Status{{Source: "foo"}, {Source: "bar"}, {Source: "baz"}}
+	e := newSlowExporter()
+	q := NewExporterQueue(e, 1, types.OverflowPolicyBlock)
+	fillQueue(t, q, e, statuses[:2])
+
+	queued := make(chan struct{})
+	go func() {
+		q.ExportProblems(statuses[2])
+		close(queued)
+	}()
+	select {
+	case <-queued:
+		t.Fatal("Status should not be queued when the queue is full")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(e.release)
+	<-queued
+	q.Flush()
+	assert.Equal(t, statuses, e.statuses)
+}
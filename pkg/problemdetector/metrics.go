@@ -0,0 +1,65 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package problemdetector
+
+import (
+	"github.com/golang/glog"
+
+	"k8s.io/node-problem-detector/pkg/util/metrics"
+)
+
+var (
+	// restartCounter counts the restarts of problem daemons.
+	restartCounter metrics.Int64MetricInterface
+	// queueLengthGauge is the number of statuses waiting in each exporter queue.
+	queueLengthGauge metrics.Int64MetricInterface
+	// queueDropCounter counts the statuses dropped from each exporter queue.
+	queueDropCounter metrics.Int64MetricInterface
+)
+
+func init() {
+	var err error
+	restartCounter, err = metrics.NewInt64Metric(
+		"problem_daemon_restart_counter",
+		"Number of times a problem daemon is restarted after exiting unexpectedly.",
+		"1",
+		metrics.Sum,
+		[]string{"config"})
+	if err != nil {
+		glog.Fatalf("Failed to create problem_daemon_restart_counter metric: %v", err)
+	}
+
+	queueLengthGauge, err = metrics.NewInt64Metric(
+		"exporter_queue_length",
+		"Number of statuses waiting to be exported by an exporter.",
+		"1",
+		metrics.LastValue,
+		[]string{"exporter"})
+	if err != nil {
+		glog.Fatalf("Failed to create exporter_queue_length metric: %v", err)
+	}
+
+	queueDropCounter, err = metrics.NewInt64Metric(
+		"exporter_queue_drop_counter",
+		"Number of statuses dropped because an exporter queue is full.",
+		"1",
+		metrics.Sum,
+		[]string{"exporter"})
+	if err != nil {
+		glog.Fatalf("Failed to create exporter_queue_drop_counter metric: %v", err)
+	}
+}
@@ -17,73 +17,385 @@ limitations under the License.
 package problemdetector
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/golang/glog"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 
 	"k8s.io/node-problem-detector/pkg/types"
 )
 
+// exporterFlushTimeout is the time the problem daemons are given to stop, and then the time
+// each exporter is given to flush its buffered problems, when the problem detector shuts down.
+var exporterFlushTimeout = 10 * time.Second
+
 // ProblemDetector collects statuses from all problem daemons and update the node condition and send node event.
 type ProblemDetector interface {
-	Run() error
+	// Run starts the problem detector and blocks until ctx is cancelled. On cancellation, it
+	// stops all problem daemons, exports the statuses they have already reported and flushes
+	// the exporters before returning.
+	Run(ctx context.Context) error
+	// ReloadMonitors starts the passed in problem daemons, which are keyed by their config
+	// paths. The latest conditions of the problem daemon previously started with the same config
+	// path are carried over to the new problem daemon, and the previous one is stopped once the
+	// new one is started. The previous one keeps running if the new one fails to start. It
+	// returns error if any problem daemon fails to start, or the problem detector is stopping.
+	ReloadMonitors(monitors map[string]types.Monitor) error
+	// RemoveMonitors stops the problem daemons started with the config paths. The conditions
+	// they have reported are not cleared.
+	RemoveMonitors(configPaths []string)
+	// Heartbeats returns the latest heartbeats of the problem daemons which report their
+	// liveness, keyed by their config paths.
+	Heartbeats() map[string]types.Heartbeat
+	// Sources returns the sources reported by the running problem daemons, keyed by their
+	// config paths. Problem daemons which haven't reported any status are not included.
+	Sources() map[string]string
 }
 
+// MonitorFactory creates the problem daemon configured by the config path. It returns nil if the
+// config path is no longer configured, and error if the problem daemon can't be created.
+type MonitorFactory func(configPath string) (types.Monitor, error)
+
 type problemDetector struct {
-	monitors  []types.Monitor
-	exporters []types.Exporter
+	// The mutex protects monitors and stopping, and serializes reloads, restarts and shutdown.
+	sync.Mutex
+	monitors map[string]*monitorHandle
+	stopping bool
+	// monitorsLock also protects monitors, so that Heartbeats doesn't wait for reloads, which
+	// hold the mutex while stopping problem daemons. monitors is only written with both held.
+	monitorsLock sync.RWMutex
+	// stopped is closed when the problem detector starts shutting down.
+	stopped chan struct{}
+	// newMonitor is used to recreate the problem daemons which exit unexpectedly.
+	newMonitor MonitorFactory
+	exporters  []types.Exporter
+	// merger merges the conditions of the same type reported by different problem daemons.
+	merger *ConditionMerger
+	// statuses merges the statuses reported by all problem daemons.
+	statuses chan reportedStatus
+	// forgotten are the config paths of the removed problem daemons, whose conditions are
+	// forgotten by merger after all their statuses are exported.
+	forgotten chan string
+	// forwarders tracks the goroutines forwarding statuses into statuses.
+	forwarders sync.WaitGroup
+	// initialMonitors are the problem daemons started by Run.
+	initialMonitors map[string]types.Monitor
 }
 
-// NewProblemDetector creates the problem detector. Currently we just directly passed in the problem daemons, but
-// in the future we may want to let the problem daemons register themselves.
-func NewProblemDetector(monitors []types.Monitor, exporters []types.Exporter) ProblemDetector {
+// reportedStatus is a status reported by the problem daemon started with the config path.
+type reportedStatus struct {
+	configPath string
+	status     *types.Status
+}
+
+// monitorHandle tracks a started problem daemon.
+type monitorHandle struct {
+	monitor types.Monitor
+	// startTime is the time when the problem daemon is started.
+	startTime time.Time
+	// backoff is the backoff the problem daemon was restarted after, 0 if it is not a restart.
+	backoff time.Duration
+	// stopping is closed before the problem daemon is stopped by the problem detector.
+	stopping chan struct{}
+	// done is closed once all statuses of the problem daemon are forwarded.
+	done chan struct{}
+	// source and conditions are the source and the latest conditions reported by the problem
+	// daemon. They are only written by the forwarding goroutine under the lock, and can be read
+	// without the lock after done is closed.
+	sync.Mutex
+	source     string
+	conditions []types.Condition
+}
+
+// reportedSource returns the source reported by the running problem daemon.
+func (h *monitorHandle) reportedSource() string {
+	h.Lock()
+	defer h.Unlock()
+	return h.source
+}
+
+// latestConditions returns the latest conditions reported by the running problem daemon.
+func (h *monitorHandle) latestConditions() []types.Condition {
+	h.Lock()
+	defer h.Unlock()
+	return h.conditions
+}
+
+// stop stops the problem daemon and waits until all its statuses are forwarded.
+func (h *monitorHandle) stop() {
+	close(h.stopping)
+	h.monitor.Stop()
+	<-h.done
+}
+
+// NewProblemDetector creates the problem detector. The problem daemons are keyed by their config paths.
+// Problem daemons which exit unexpectedly are recreated with newMonitor and restarted. Pass in nil
+// newMonitor to disable the restart. Conditions are merged by merger before exported, pass in nil
+// merger to export the conditions as they are reported.
+func NewProblemDetector(monitors map[string]types.Monitor, newMonitor MonitorFactory, exporters []types.Exporter, merger *ConditionMerger) ProblemDetector {
 	return &problemDetector{
-		monitors:  monitors,
-		exporters: exporters,
+		monitors:        make(map[string]*monitorHandle),
+		stopped:         make(chan struct{}),
+		newMonitor:      newMonitor,
+		exporters:       exporters,
+		merger:          merger,
+		statuses:        make(chan reportedStatus),
+		forgotten:       make(chan string),
+		initialMonitors: monitors,
 	}
 }
 
 // Run starts the problem detector.
-func (p *problemDetector) Run() error {
-	// Start the log monitors one by one.
-	var chans []<-chan *types.Status
-	failureCount := 0
-	for _, m := range p.monitors {
-		ch, err := m.Start()
-		if err != nil {
+func (p *problemDetector) Run(ctx context.Context) error {
+	// Start the problem daemons one by one.
+	p.Lock()
+	for configPath, m := range p.initialMonitors {
+		if _, err := p.startMonitor(configPath, m); err != nil {
 			// Do not return error and keep on trying the following config files.
 			glog.Errorf("Failed to start problem daemon %v: %v", m, err)
-			failureCount += 1
-			continue
-		}
-		if ch != nil {
-			chans = append(chans, ch)
 		}
 	}
-	if len(p.monitors) == failureCount {
+	started := len(p.monitors)
+	p.Unlock()
+	if started == 0 {
 		return fmt.Errorf("no problem daemon is successfully setup")
 	}
-	ch := groupChannel(chans)
 	glog.Info("Problem detector started")
 
 	for {
 		select {
-		case status := <-ch:
-			for _, exporter := range p.exporters {
-				exporter.ExportProblems(status)
+		case reported := <-p.statuses:
+			p.export(reported)
+		case configPath := <-p.forgotten:
+			p.forget(configPath)
+		case <-ctx.Done():
+			glog.Info("Stopping problem detector")
+			p.shutdown()
+			glog.Info("Problem detector stopped")
+			return nil
+		}
+	}
+}
+
+func (p *problemDetector) ReloadMonitors(monitors map[string]types.Monitor) error {
+	p.Lock()
+	defer p.Unlock()
+	if p.stopping {
+		return fmt.Errorf("problem detector is stopping")
+	}
+	var errs []error
+	for configPath, m := range monitors {
+		old, ok := p.monitors[configPath]
+		if ok {
+			if restorer, isRestorer := m.(types.ConditionRestorer); isRestorer {
+				if conditions := old.latestConditions(); len(conditions) != 0 {
+					restorer.RestoreConditions(conditions)
+				}
+			}
+		}
+		// Start the new problem daemon before stopping the old one, so that the old one keeps
+		// running if the new one fails to start.
+		if _, err := p.startMonitor(configPath, m); err != nil {
+			if ok {
+				err = fmt.Errorf("failed to start problem daemon %q, keeping the previous one: %v", configPath, err)
+			} else {
+				err = fmt.Errorf("failed to start problem daemon %q: %v", configPath, err)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			// The conditions reported by the previous problem daemon are not forgotten, but
+			// replaced by the new one, which is started with the same config path.
+			glog.Infof("Stopping previous problem daemon %q for reload", configPath)
+			old.stop()
+		}
+		glog.Infof("Problem daemon %q is reloaded", configPath)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (p *problemDetector) RemoveMonitors(configPaths []string) {
+	p.Lock()
+	defer p.Unlock()
+	if p.stopping {
+		glog.Warningf("Problem detector is stopping, skip removing problem daemons")
+		return
+	}
+	for _, configPath := range configPaths {
+		h, ok := p.monitors[configPath]
+		if !ok {
+			continue
+		}
+		glog.Infof("Stopping removed problem daemon %q", configPath)
+		h.stop()
+		p.monitorsLock.Lock()
+		delete(p.monitors, configPath)
+		p.monitorsLock.Unlock()
+		p.forgetConditions(configPath)
+	}
+}
+
+func (p *problemDetector) Heartbeats() map[string]types.Heartbeat {
+	p.monitorsLock.RLock()
+	defer p.monitorsLock.RUnlock()
+	heartbeats := make(map[string]types.Heartbeat)
+	for configPath, h := range p.monitors {
+		if reporter, ok := h.monitor.(types.HeartbeatReporter); ok {
+			heartbeats[configPath] = reporter.Heartbeat()
+		}
+	}
+	return heartbeats
+}
+
+func (p *problemDetector) Sources() map[string]string {
+	p.monitorsLock.RLock()
+	defer p.monitorsLock.RUnlock()
+	sources := make(map[string]string)
+	for configPath, h := range p.monitors {
+		if source := h.reportedSource(); source != "" {
+			sources[configPath] = source
+		}
+	}
+	return sources
+}
+
+// startMonitor starts a problem daemon and forwards its statuses. The problem daemon is
+// restarted if it exits without being stopped. The caller should hold the lock.
+func (p *problemDetector) startMonitor(configPath string, m types.Monitor) (*monitorHandle, error) {
+	ch, err := m.Start()
+	if err != nil {
+		return nil, err
+	}
+	h := &monitorHandle{
+		monitor:   m,
+		startTime: time.Now(),
+		stopping:  make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	p.monitorsLock.Lock()
+	p.monitors[configPath] = h
+	p.monitorsLock.Unlock()
+	if ch == nil {
+		close(h.done)
+		return h, nil
+	}
+	p.forwarders.Add(1)
+	go func() {
+		defer func() {
+			close(h.done)
+			p.forwarders.Done()
+		}()
+		for status := range ch {
+			h.Lock()
+			h.source = status.Source
+			if status.Conditions != nil {
+				h.conditions = append([]types.Condition(nil), status.Conditions...)
 			}
+			h.Unlock()
+			p.statuses <- reportedStatus{configPath: configPath, status: status}
+		}
+		select {
+		case <-h.stopping:
+		default:
+			p.handleUnexpectedExit(configPath, h)
 		}
+	}()
+	return h, nil
+}
+
+// forgetConditions makes merger forget the conditions reported by the removed problem daemon
+// started with the config path. The conditions are forgotten by the main loop, after the statuses
+// the problem daemon reported are exported. The caller should hold the lock.
+func (p *problemDetector) forgetConditions(configPath string) {
+	if p.merger == nil {
+		return
 	}
+	p.forgotten <- configPath
 }
 
-func groupChannel(chans []<-chan *types.Status) <-chan *types.Status {
-	statuses := make(chan *types.Status)
-	for _, ch := range chans {
-		go func(c <-chan *types.Status) {
-			for status := range c {
-				statuses <- status
+// forget forgets the conditions reported by the problem daemon in merger.
+func (p *problemDetector) forget(configPath string) {
+	glog.Infof("Forgetting the conditions reported by %q", configPath)
+	p.merger.Forget(configPath)
+}
+
+// export exports a reported status with all exporters.
+func (p *problemDetector) export(reported reportedStatus) {
+	status := reported.status
+	if p.merger != nil {
+		status = p.merger.Merge(reported.configPath, status)
+	}
+	for _, exporter := range p.exporters {
+		exporter.ExportProblems(status)
+	}
+}
+
+// shutdown stops the problem daemons, exports all statuses they have reported, and then
+// flushes the exporters. It stops waiting for the problem daemons after exporterFlushTimeout,
+// so that a problem daemon which never stops doesn't block the shutdown.
+func (p *problemDetector) shutdown() {
+	// Stop the problem daemons in another goroutine and keep draining the statuses,
+	// because a problem daemon may be blocked on reporting a status.
+	go func() {
+		p.Lock()
+		p.stopping = true
+		close(p.stopped)
+		for _, h := range p.monitors {
+			close(h.stopping)
+			h.monitor.Stop()
+		}
+		p.Unlock()
+		p.forwarders.Wait()
+		close(p.statuses)
+	}()
+	// Keep forgetting the conditions too, because the problem daemons may be being removed.
+	timeout := time.After(exporterFlushTimeout)
+	for {
+		select {
+		case reported, ok := <-p.statuses:
+			if !ok {
+				p.flushExporters()
+				return
 			}
-		}(ch)
+			p.export(reported)
+		case configPath := <-p.forgotten:
+			p.forget(configPath)
+		case <-timeout:
+			glog.Warningf("Problem daemons are not stopped within %v, some problems may be lost", exporterFlushTimeout)
+			p.flushExporters()
+			return
+		}
+	}
+}
+
+// flushExporters flushes all exporters which buffer problems, giving up on those
+// which do not finish within exporterFlushTimeout.
+func (p *problemDetector) flushExporters() {
+	var wg sync.WaitGroup
+	for _, exporter := range p.exporters {
+		flusher, ok := exporter.(types.FlushableExporter)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(f types.FlushableExporter) {
+			defer wg.Done()
+			f.Flush()
+		}(flusher)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(exporterFlushTimeout):
+		glog.Warningf("Exporters are not flushed within %v, some problems may be lost", exporterFlushTimeout)
 	}
-	return statuses
 }
@@ -0,0 +1,345 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package problemdetector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+// fakeMonitor reports the statuses injected into it, and reports a final status when
+// it is stopped.
+type fakeMonitor struct {
+	source   string
+	startErr error
+	statuses chan *types.Status
+	stopped  bool
+	restored []types.Condition
+}
+
+func newFakeMonitor(source string) *fakeMonitor {
+	return &fakeMonitor{
+		source:   source,
+		statuses: make(chan *types.Status),
+	}
+}
+
+func (f *fakeMonitor) RestoreConditions(conditions []types.Condition) {
+	f.restored = conditions
+}
+
+func (f *fakeMonitor) Start() (<-chan *types.Status, error) {
+	if f.startErr != nil {
+		return nil, f.startErr
+	}
+	return f.statuses, nil
+}
+
+func (f *fakeMonitor) Stop() {
+	f.stopped = true
+	f.statuses <- &types.Status{Source: f.source, Events: []types.Event{{Reason: "Stopped"}}}
+	close(f.statuses)
+}
+
+// fakeExporter records all exported statuses.
+type fakeExporter struct {
+	sync.Mutex
+	statuses []*types.Status
+	flushed  bool
+}
+
+func (f *fakeExporter) ExportProblems(status *types.Status) {
+	f.Lock()
+	defer f.Unlock()
+	f.statuses = append(f.statuses, status)
+}
+
+func (f *fakeExporter) Flush() {
+	f.Lock()
+	defer f.Unlock()
+	f.flushed = true
+}
+
+func (f *fakeExporter) exported() int {
+	f.Lock()
+	defer f.Unlock()
+	return len(f.statuses)
+}
+
+func TestRunWithoutProblemDaemon(t *testing.T) {
+	m := newFakeMonitor("foo")
+	m.startErr = fmt.Errorf("injected error")
+	p := NewProblemDetector(map[string]types.Monitor{"foo": m}, nil, []types.Exporter{&fakeExporter{}}, nil)
+	assert.Error(t, p.Run(context.Background()))
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	foo := newFakeMonitor("foo")
+	bar := newFakeMonitor("bar")
+	exporter := &fakeExporter{}
+	p := NewProblemDetector(map[string]types.Monitor{"foo": foo, "bar": bar}, nil, []types.Exporter{exporter}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() {
+		errCh <- p.Run(ctx)
+	}()
+
+	foo.statuses <- &types.Status{Source: "foo"}
+	bar.statuses <- &types.Status{Source: "bar"}
+	cancel()
+
+	select {
+	case err := <-errCh:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("problem detector did not stop after context is cancelled")
+	}
+	assert.True(t, foo.stopped)
+	assert.True(t, bar.stopped)
+	// The statuses reported while stopping should also be exported.
+	assert.Equal(t, 4, exporter.exported())
+	assert.True(t, exporter.flushed)
+}
+
+// hangingMonitor never stops.
+type hangingMonitor struct {
+	*fakeMonitor
+	hang chan struct{}
+}
+
+func (h *hangingMonitor) Stop() {
+	<-h.hang
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { exporterFlushTimeout = timeout }(exporterFlushTimeout)
+	exporterFlushTimeout = 100 * time.Millisecond
+
+	foo := &hangingMonitor{fakeMonitor: newFakeMonitor("foo"), hang: make(chan struct{})}
+	defer close(foo.hang)
+	exporter := &fakeExporter{}
+	p := NewProblemDetector(map[string]types.Monitor{"foo": foo}, nil, []types.Exporter{exporter}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() {
+		errCh <- p.Run(ctx)
+	}()
+	foo.statuses <- &types.Status{Source: "foo"}
+	cancel()
+
+	select {
+	case err := <-errCh:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("problem detector should stop after timeout even if a problem daemon never stops")
+	}
+	assert.Equal(t, 1, exporter.exported())
+	assert.True(t, exporter.flushed)
+}
+
+func TestReloadMonitors(t *testing.T) {
+	foo := newFakeMonitor("foo")
+	exporter := &fakeExporter{}
+	p := NewProblemDetector(map[string]types.Monitor{"foo": foo}, nil, []types.Exporter{exporter}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() {
+		errCh <- p.Run(ctx)
+	}()
+
+	conditions := []types.Condition{{Type: "TestCondition", Status: types.True}}
+	foo.statuses <- &types.Status{Source: "foo", Conditions: conditions}
+	// Wait until the conditions are recorded and exported.
+	for exporter.exported() != 1 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, map[string]string{"foo": "foo"}, p.Sources())
+
+	// The old problem daemon is kept if the new one fails to start.
+	brokenFoo := newFakeMonitor("foo")
+	brokenFoo.startErr = fmt.Errorf("injected error")
+	assert.Error(t, p.ReloadMonitors(map[string]types.Monitor{"foo": brokenFoo}))
+	assert.False(t, foo.stopped, "old problem daemon should be kept")
+
+	newFoo := newFakeMonitor("foo")
+	bar := newFakeMonitor("bar")
+	assert.NoError(t, p.ReloadMonitors(map[string]types.Monitor{"foo": newFoo, "bar": bar}))
+
+	assert.True(t, foo.stopped, "old problem daemon should be stopped")
+	assert.Equal(t, conditions, newFoo.restored, "conditions should be carried over to the new problem daemon")
+	assert.Nil(t, bar.restored, "conditions should not be restored for a new config")
+
+	newFoo.statuses <- &types.Status{Source: "foo"}
+	bar.statuses <- &types.Status{Source: "bar"}
+	p.RemoveMonitors([]string{"bar", "baz"})
+	assert.True(t, bar.stopped, "removed problem daemon should be stopped")
+	assert.False(t, newFoo.stopped, "problem daemon not removed should be kept")
+
+	cancel()
+	assert.NoError(t, <-errCh)
+	// Each problem daemon reports one status before being stopped, and one when stopped.
+	assert.Equal(t, 6, exporter.exported())
+	assert.Error(t, p.ReloadMonitors(map[string]types.Monitor{"bar": newFakeMonitor("bar")}), "should not reload once stopped")
+}
+
+func TestRemoveMonitorsForgetsConditions(t *testing.T) {
+	foo := newFakeMonitor("foo")
+	bar := newFakeMonitor("bar")
+	exporter := &fakeExporter{}
+	merger := NewConditionMerger(types.MergePolicyAnyTrue, nil)
+	p := NewProblemDetector(map[string]types.Monitor{"foo": foo, "bar": bar}, nil, []types.Exporter{exporter}, merger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() {
+		errCh <- p.Run(ctx)
+	}()
+
+	foo.statuses <- &types.Status{Source: "foo", Conditions: []types.Condition{{Type: "TestCondition", Status: types.True}}}
+	bar.statuses <- &types.Status{Source: "bar", Conditions: []types.Condition{{Type: "TestCondition", Status: types.False}}}
+	for exporter.exported() != 2 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	p.RemoveMonitors([]string{"foo"})
+	bar.statuses <- &types.Status{Source: "bar", Conditions: []types.Condition{{Type: "TestCondition", Status: types.False}}}
+	// Wait for the status reported when foo is stopped, and the new status of bar.
+	for exporter.exported() != 4 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	exporter.Lock()
+	merged := exporter.statuses[3].Conditions
+	exporter.Unlock()
+	assert.Equal(t, types.False, merged[0].Status, "conditions of the removed problem daemon should be forgotten")
+
+	cancel()
+	assert.NoError(t, <-errCh)
+}
+
+func TestMergeSameSource(t *testing.T) {
+	foo := newFakeMonitor("foo")
+	fooFilelog := newFakeMonitor("foo")
+	exporter := &fakeExporter{}
+	merger := NewConditionMerger(types.MergePolicyAnyTrue, nil)
+	p := NewProblemDetector(map[string]types.Monitor{"foo.json": foo, "foo-filelog.json": fooFilelog}, nil,
+		[]types.Exporter{exporter}, merger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() {
+		errCh <- p.Run(ctx)
+	}()
+
+	foo.statuses <- &types.Status{Source: "foo", Conditions: []types.Condition{{Type: "TestCondition", Status: types.True}}}
+	fooFilelog.statuses <- &types.Status{Source: "foo", Conditions: []types.Condition{{Type: "TestCondition", Status: types.False}}}
+	for exporter.exported() != 2 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// The conditions of the previous problem daemon are kept until the reloaded one reports.
+	newFoo := newFakeMonitor("foo")
+	assert.NoError(t, p.ReloadMonitors(map[string]types.Monitor{"foo.json": newFoo}))
+	fooFilelog.statuses <- &types.Status{Source: "foo", Conditions: []types.Condition{{Type: "TestCondition", Status: types.False}}}
+	// Wait for the status reported when foo is stopped, and the new status of foo-filelog.
+	for exporter.exported() != 4 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	exporter.Lock()
+	merged := exporter.statuses[3].Conditions
+	exporter.Unlock()
+	assert.Equal(t, types.True, merged[0].Status, "problem daemons reporting the same source should not overwrite each other")
+
+	cancel()
+	assert.NoError(t, <-errCh)
+}
+
+// heartbeatMonitor is a fakeMonitor which reports a heartbeat.
+type heartbeatMonitor struct {
+	*fakeMonitor
+	heartbeat types.Heartbeat
+}
+
+func (h *heartbeatMonitor) Heartbeat() types.Heartbeat {
+	return h.heartbeat
+}
+
+func TestHeartbeats(t *testing.T) {
+	foo := newFakeMonitor("foo")
+	heartbeat := types.Heartbeat{Timestamp: time.Now(), Message: "Processed log line"}
+	bar := &heartbeatMonitor{fakeMonitor: newFakeMonitor("bar"), heartbeat: heartbeat}
+	p := NewProblemDetector(map[string]types.Monitor{"foo": foo, "bar": bar}, nil, []types.Exporter{&fakeExporter{}}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() {
+		errCh <- p.Run(ctx)
+	}()
+	// Wait until the problem daemons are started.
+	bar.statuses <- &types.Status{Source: "bar"}
+
+	// Only the problem daemons reporting heartbeats are included.
+	assert.Equal(t, map[string]types.Heartbeat{"bar": heartbeat}, p.Heartbeats())
+
+	// Heartbeats should not wait for a reload holding the lock.
+	p.(*problemDetector).Lock()
+	heartbeats := make(chan map[string]types.Heartbeat)
+	go func() {
+		heartbeats <- p.Heartbeats()
+	}()
+	select {
+	case got := <-heartbeats:
+		assert.Equal(t, map[string]types.Heartbeat{"bar": heartbeat}, got)
+	case <-time.After(5 * time.Second):
+		t.Error("Heartbeats is blocked by the lock")
+	}
+	p.(*problemDetector).Unlock()
+
+	cancel()
+	assert.NoError(t, <-errCh)
+}
+
+func TestFlushTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { exporterFlushTimeout = timeout }(exporterFlushTimeout)
+	exporterFlushTimeout = 100 * time.Millisecond
+
+	exporter := &fakeExporter{}
+	// Hold the lock so that the exporter can never be flushed.
+	exporter.Lock()
+	defer exporter.Unlock()
+	p := &problemDetector{exporters: []types.Exporter{exporter}}
+
+	done := make(chan struct{})
+	go func() {
+		p.flushExporters()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("flushing exporters should not block after timeout")
+	}
+}
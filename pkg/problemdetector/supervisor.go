@@ -0,0 +1,143 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package problemdetector
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+const (
+	// problemDaemonDownReason is the reason of the conditions and the event reported when a
+	// problem daemon exits unexpectedly.
+	problemDaemonDownReason = "ProblemDaemonDown"
+)
+
+var (
+	// initialRestartBackoff is the backoff before a problem daemon is restarted for the first time.
+	initialRestartBackoff = 1 * time.Second
+	// maxRestartBackoff is the maximum backoff before a problem daemon is restarted. The backoff
+	// is reset once a problem daemon keeps running for longer than maxRestartBackoff.
+	maxRestartBackoff = 5 * time.Minute
+)
+
+// handleUnexpectedExit marks the conditions of a problem daemon which exited unexpectedly as
+// Unknown, and restarts it with backoff. It is called by the forwarding goroutine of the
+// problem daemon.
+func (p *problemDetector) handleUnexpectedExit(configPath string, h *monitorHandle) {
+	glog.Errorf("Problem daemon %q exited unexpectedly", configPath)
+	if h.source != "" {
+		p.statuses <- reportedStatus{configPath: configPath, status: unknownStatus(h.source, configPath, h.conditions, time.Now())}
+	}
+	if p.newMonitor == nil {
+		glog.Errorf("Problem daemon %q is not restarted, no factory is provided", configPath)
+		return
+	}
+
+	backoff := initialRestartBackoff
+	if h.backoff != 0 && time.Since(h.startTime) < maxRestartBackoff {
+		backoff = nextBackoff(h.backoff)
+	}
+	go p.restartMonitor(configPath, h, backoff)
+}
+
+// restartMonitor recreates and restarts the problem daemon after backoff, unless it is
+// reloaded in the meantime or the problem detector is stopping.
+func (p *problemDetector) restartMonitor(configPath string, old *monitorHandle, backoff time.Duration) {
+	for {
+		glog.Infof("Restarting problem daemon %q in %v", configPath, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-p.stopped:
+			return
+		}
+
+		p.Lock()
+		if p.stopping || p.monitors[configPath] != old {
+			p.Unlock()
+			return
+		}
+		m, startErr := p.newMonitor(configPath)
+		if startErr == nil && m == nil {
+			p.Unlock()
+			glog.Errorf("Problem daemon %q is not restarted, it is no longer configured", configPath)
+			return
+		}
+		if startErr == nil {
+			if restorer, ok := m.(types.ConditionRestorer); ok && len(old.conditions) != 0 {
+				restorer.RestoreConditions(old.conditions)
+			}
+			var h *monitorHandle
+			h, startErr = p.startMonitor(configPath, m)
+			if startErr == nil {
+				h.backoff = backoff
+			}
+		}
+		p.Unlock()
+
+		if err := restartCounter.Record(map[string]string{"config": configPath}, 1); err != nil {
+			glog.Errorf("Failed to update problem daemon restart counter for %q: %v", configPath, err)
+		}
+		if startErr == nil {
+			glog.Infof("Problem daemon %q is restarted", configPath)
+			return
+		}
+		glog.Errorf("Failed to restart problem daemon %q: %v", configPath, startErr)
+		backoff = nextBackoff(backoff)
+	}
+}
+
+// nextBackoff doubles the backoff, up to maxRestartBackoff.
+func nextBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > maxRestartBackoff {
+		backoff = maxRestartBackoff
+	}
+	return backoff
+}
+
+// unknownStatus generates the status reported when a problem daemon is down, which marks all
+// its conditions as Unknown.
+func unknownStatus(source, configPath string, conditions []types.Condition, timestamp time.Time) *types.Status {
+	message := fmt.Sprintf("Problem daemon %s exited unexpectedly and is being restarted", configPath)
+	unknownConditions := make([]types.Condition, len(conditions))
+	for i, condition := range conditions {
+		unknownConditions[i] = types.Condition{
+			Type:       condition.Type,
+			Status:     types.Unknown,
+			Transition: timestamp,
+			Reason:     problemDaemonDownReason,
+			Message:    message,
+		}
+	}
+	return &types.Status{
+		Source: source,
+		Events: []types.Event{
+			{
+				Severity:  types.Warn,
+				Timestamp: timestamp,
+				Reason:    problemDaemonDownReason,
+				Message:   message,
+			},
+		},
+		Conditions: unknownConditions,
+	}
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package problemdetector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+func TestRestartUnexpectedlyExitedMonitor(t *testing.T) {
+	defer func(backoff time.Duration) { initialRestartBackoff = backoff }(initialRestartBackoff)
+	initialRestartBackoff = 10 * time.Millisecond
+
+	foo := newFakeMonitor("foo")
+	restarted := make(chan *fakeMonitor, 1)
+	attempts := 0
+	newMonitor := func(configPath string) (types.Monitor, error) {
+		attempts++
+		// Fail to recreate the problem daemon for the first time, it should be retried.
+		if attempts == 1 {
+			return nil, fmt.Errorf("failed to load configuration file %q", configPath)
+		}
+		m := newFakeMonitor("foo")
+		restarted <- m
+		return m, nil
+	}
+	exporter := &fakeExporter{}
+	p := NewProblemDetector(map[string]types.Monitor{"foo": foo}, newMonitor, []types.Exporter{exporter}, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() {
+		errCh <- p.Run(ctx)
+	}()
+
+	conditions := []types.Condition{{Type: "TestCondition", Status: types.True, Reason: "TestReason"}}
+	foo.statuses <- &types.Status{Source: "foo", Conditions: conditions}
+	// Exit without being stopped.
+	close(foo.statuses)
+
+	var newFoo *fakeMonitor
+	select {
+	case newFoo = <-restarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("problem daemon is not restarted")
+	}
+	newFoo.statuses <- &types.Status{Source: "foo", Conditions: conditions}
+	assert.Equal(t, conditions, newFoo.restored, "conditions should be carried over to the restarted problem daemon")
+
+	cancel()
+	assert.NoError(t, <-errCh)
+	assert.False(t, foo.stopped, "exited problem daemon should not be stopped")
+	assert.True(t, newFoo.stopped)
+	assert.Equal(t, 2, attempts)
+
+	// The statuses should be: the initial status, the unknown status, the status from the
+	// restarted problem daemon, and the final status when it is stopped.
+	exporter.Lock()
+	defer exporter.Unlock()
+	if assert.Len(t, exporter.statuses, 4) {
+		unknown := exporter.statuses[1]
+		assert.Equal(t, "foo", unknown.Source)
+		if assert.Len(t, unknown.Conditions, 1) {
+			assert.Equal(t, "TestCondition", unknown.Conditions[0].Type)
+			assert.Equal(t, types.Unknown, unknown.Conditions[0].Status)
+			assert.Equal(t, problemDaemonDownReason, unknown.Conditions[0].Reason)
+		}
+	}
+}
+
+func TestNextBackoff(t *testing.T) {
+	assert.Equal(t, 2*time.Second, nextBackoff(time.Second))
+	assert.Equal(t, maxRestartBackoff, nextBackoff(maxRestartBackoff-time.Second))
+	assert.Equal(t, maxRestartBackoff, nextBackoff(maxRestartBackoff))
+}
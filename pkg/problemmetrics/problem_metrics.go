@@ -23,6 +23,7 @@ import (
 
 	"github.com/golang/glog"
 
+	"k8s.io/node-problem-detector/pkg/types"
 	"k8s.io/node-problem-detector/pkg/util/metrics"
 )
 
@@ -53,7 +54,7 @@ func NewProblemMetricsManagerOrDie() *ProblemMetricsManager {
 		"Number of times a specific type of problem have occurred.",
 		"1",
 		metrics.Sum,
-		[]string{"reason"})
+		[]string{"reason", "severity"})
 	if err != nil {
 		glog.Fatalf("Failed to create problem_counter metric: %v", err)
 	}
@@ -74,12 +75,12 @@ func NewProblemMetricsManagerOrDie() *ProblemMetricsManager {
 }
 
 // IncrementProblemCounter increments the value of a problem counter.
-func (pmm *ProblemMetricsManager) IncrementProblemCounter(reason string, count int64) error {
+func (pmm *ProblemMetricsManager) IncrementProblemCounter(reason string, severity types.Severity, count int64) error {
 	if pmm.problemCounter == nil {
 		return errors.New("problem counter is being incremented before initialized.")
 	}
 
-	return pmm.problemCounter.Record(map[string]string{"reason": reason}, count)
+	return pmm.problemCounter.Record(map[string]string{"reason": reason, "severity": string(severity)}, count)
 }
 
 // SetProblemGauge sets the value of a problem gauge.
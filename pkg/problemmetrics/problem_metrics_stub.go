@@ -23,7 +23,7 @@ import (
 // NewProblemMetricsManagerStub creates a ProblemMetricsManager stubbed by fake metrics.
 // The stubbed ProblemMetricsManager and fake metrics are returned.
 func NewProblemMetricsManagerStub() (*ProblemMetricsManager, *metrics.FakeInt64Metric, *metrics.FakeInt64Metric) {
-	fakeProblemCounter := metrics.NewFakeInt64Metric("problem_counter", metrics.Sum, []string{"reason"})
+	fakeProblemCounter := metrics.NewFakeInt64Metric("problem_counter", metrics.Sum, []string{"reason", "severity"})
 	fakeProblemGauge := metrics.NewFakeInt64Metric("problem_gauge", metrics.LastValue, []string{"type", "reason"})
 
 	pmm := ProblemMetricsManager{}
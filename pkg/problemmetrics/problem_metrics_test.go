@@ -21,6 +21,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"k8s.io/node-problem-detector/pkg/types"
 	"k8s.io/node-problem-detector/pkg/util/metrics"
 )
 
@@ -44,7 +45,7 @@ func TestNewProblem(t *testing.T) {
 			expectedMetrics: []metrics.Int64MetricRepresentation{
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "foo"},
+					Labels: map[string]string{"reason": "foo", "severity": "warn"},
 					Value:  1,
 				},
 			},
@@ -56,7 +57,7 @@ func TestNewProblem(t *testing.T) {
 			expectedMetrics: []metrics.Int64MetricRepresentation{
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "foo"},
+					Labels: map[string]string{"reason": "foo", "severity": "warn"},
 					Value:  2,
 				},
 			},
@@ -68,12 +69,12 @@ func TestNewProblem(t *testing.T) {
 			expectedMetrics: []metrics.Int64MetricRepresentation{
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "foo"},
+					Labels: map[string]string{"reason": "foo", "severity": "warn"},
 					Value:  2,
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "bar"},
+					Labels: map[string]string{"reason": "bar", "severity": "warn"},
 					Value:  1,
 				},
 			},
@@ -85,12 +86,12 @@ func TestNewProblem(t *testing.T) {
 			expectedMetrics: []metrics.Int64MetricRepresentation{
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "foo"},
+					Labels: map[string]string{"reason": "foo", "severity": "warn"},
 					Value:  0,
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "bar"},
+					Labels: map[string]string{"reason": "bar", "severity": "warn"},
 					Value:  0,
 				},
 			},
@@ -102,12 +103,12 @@ func TestNewProblem(t *testing.T) {
 			expectedMetrics: []metrics.Int64MetricRepresentation{
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "foo"},
+					Labels: map[string]string{"reason": "foo", "severity": "warn"},
 					Value:  2,
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "bar"},
+					Labels: map[string]string{"reason": "bar", "severity": "warn"},
 					Value:  1,
 				},
 			},
@@ -118,7 +119,7 @@ func TestNewProblem(t *testing.T) {
 			pmm, fakeProblemCounter, fakeProblemGauge := NewProblemMetricsManagerStub()
 
 			for idx, reason := range test.reasons {
-				pmm.IncrementProblemCounter(reason, test.counts[idx])
+				pmm.IncrementProblemCounter(reason, types.Warn, test.counts[idx])
 			}
 
 			gotMetrics := append(fakeProblemCounter.ListMetrics(), fakeProblemGauge.ListMetrics()...)
@@ -0,0 +1,88 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pushapi
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/golang/glog"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+var (
+	// errMonitorStopped is returned when a status is pushed to a stopped external monitor.
+	errMonitorStopped = errors.New("the source is stopped")
+	// errMonitorBusy is returned when the statuses of an external monitor are not forwarded
+	// fast enough.
+	errMonitorBusy = errors.New("too many statuses are pending for the source")
+)
+
+// externalMonitorBufferSize is the number of statuses an external monitor can buffer before
+// they are forwarded.
+const externalMonitorBufferSize = 100
+
+// externalMonitor is the monitor of an external problem daemon, which reports the statuses
+// pushed by the external problem daemon. It doesn't report heartbeats, so that an external
+// problem daemon which stops pushing doesn't fail the health check of node problem detector.
+type externalMonitor struct {
+	source string
+	// The mutex protects output and stopped, so that no status is pushed once output is closed.
+	sync.Mutex
+	output  chan *types.Status
+	stopped bool
+}
+
+// newExternalMonitor creates the monitor of the external problem daemon reporting the source.
+func newExternalMonitor(source string) *externalMonitor {
+	return &externalMonitor{
+		source: source,
+		output: make(chan *types.Status, externalMonitorBufferSize),
+	}
+}
+
+func (e *externalMonitor) Start() (<-chan *types.Status, error) {
+	glog.Infof("Start external monitor %s", e.source)
+	return e.output, nil
+}
+
+func (e *externalMonitor) Stop() {
+	glog.Infof("Stop external monitor %s", e.source)
+	e.Lock()
+	defer e.Unlock()
+	if !e.stopped {
+		e.stopped = true
+		close(e.output)
+	}
+}
+
+// push reports a status pushed by the external problem daemon. It doesn't block, and returns
+// error if the status can't be reported.
+func (e *externalMonitor) push(status *types.Status) error {
+	e.Lock()
+	defer e.Unlock()
+	if e.stopped {
+		return errMonitorStopped
+	}
+	select {
+	case e.output <- status:
+	default:
+		return errMonitorBusy
+	}
+	return nil
+}
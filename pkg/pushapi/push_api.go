@@ -0,0 +1,198 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pushapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+const (
+	// APIVersion is the version of the push API. The statuses are pushed to /<APIVersion>/statuses
+	// in the JSON format of types.Status.
+	APIVersion = "v1"
+	// statusesPath is the path to push statuses to.
+	statusesPath = "/" + APIVersion + "/statuses"
+	// maxStatusBytes is the maximum size of a pushed status.
+	maxStatusBytes = 1 << 20
+	// socketMode is the file mode of the socket, so that only the user node problem detector
+	// runs as can push statuses.
+	socketMode = 0600
+	// externalMonitorPrefix is the prefix of the keys of the external monitors in the problem
+	// detector. The source is appended.
+	externalMonitorPrefix = "external:"
+)
+
+// Registry is where the external monitors are registered, e.g. the problem detector.
+type Registry interface {
+	// ReloadMonitors starts the external monitors keyed by their names.
+	ReloadMonitors(monitors map[string]types.Monitor) error
+	// Sources returns the sources reported by the running monitors, keyed by their names.
+	Sources() map[string]string
+}
+
+// sourceConflictError is returned when a source pushed by an external problem daemon is
+// reported by another problem daemon.
+type sourceConflictError struct {
+	source string
+	name   string
+}
+
+func (e *sourceConflictError) Error() string {
+	return fmt.Sprintf("source %q is reported by problem daemon %q", e.source, e.name)
+}
+
+// Server is the push API server, which lets external problem daemons push their statuses to
+// node problem detector over a Unix socket. Each source is reported by an external monitor,
+// which is registered with the problem detector when the source pushes its first status.
+type Server struct {
+	socketPath string
+	registry   Registry
+	mux        *http.ServeMux
+	// The mutex protects monitors.
+	sync.Mutex
+	monitors map[string]*externalMonitor
+}
+
+// NewServer creates the push API server listening on socketPath. The external monitors are
+// registered with registry.
+func NewServer(socketPath string, registry Registry) *Server {
+	s := &Server{
+		socketPath: socketPath,
+		registry:   registry,
+		mux:        http.NewServeMux(),
+		monitors:   make(map[string]*externalMonitor),
+	}
+	s.mux.HandleFunc(statusesPath, s.handleStatus)
+	return s
+}
+
+// Start starts serving in the background. It returns error if the socket can't be listened on,
+// and panics if the server fails afterwards.
+func (s *Server) Start() error {
+	// Only the socket left over by the previous run is replaced.
+	if info, err := os.Lstat(s.socketPath); err == nil && info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%q already exists and is not a socket", s.socketPath)
+	}
+	listener, err := s.listen()
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := http.Serve(listener, s.mux); err != nil {
+			glog.Fatalf("Failed to serve push API: %v", err)
+		}
+	}()
+	glog.Infof("Push API started at %s", s.socketPath)
+	return nil
+}
+
+// listen listens on the socket. The socket is created with the mode allowed by the umask, so it
+// is created in a private directory, and only moved to the socket path once its mode is
+// restricted.
+func (s *Server) listen() (net.Listener, error) {
+	dir, err := ioutil.TempDir(filepath.Dir(s.socketPath), "."+filepath.Base(s.socketPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the directory for the socket %q: %v", s.socketPath, err)
+	}
+	defer os.RemoveAll(dir)
+	tmpPath := filepath.Join(dir, "socket")
+	listener, err := net.Listen("unix", tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	// The socket is moved away, don't remove it from the temporary path when closed.
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmpPath, socketMode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set the mode of the socket %q: %v", s.socketPath, err)
+	}
+	if err := os.Rename(tmpPath, s.socketPath); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to move the socket to %q: %v", s.socketPath, err)
+	}
+	return listener, nil
+}
+
+// handleStatus handles a status pushed by an external problem daemon.
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, fmt.Sprintf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	var status types.Status
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxStatusBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&status); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode status: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := validateStatus(&status, time.Now()); err != nil {
+		http.Error(w, fmt.Sprintf("invalid status: %v", err), http.StatusBadRequest)
+		return
+	}
+	m, err := s.monitor(status.Source)
+	if err == nil {
+		err = m.push(&status)
+	}
+	if err != nil {
+		code := http.StatusServiceUnavailable
+		if _, ok := err.(*sourceConflictError); ok {
+			code = http.StatusConflict
+		}
+		http.Error(w, fmt.Sprintf("failed to report status: %v", err), code)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// monitor returns the external monitor of the source, which is created and registered when the
+// source pushes its first status. It returns error if the source is reported by another problem
+// daemon, so that the external problem daemon can't override its conditions, or the external
+// monitor can't be registered.
+func (s *Server) monitor(source string) (*externalMonitor, error) {
+	s.Lock()
+	defer s.Unlock()
+	name := externalMonitorPrefix + source
+	for other, reported := range s.registry.Sources() {
+		if other != name && reported == source {
+			return nil, &sourceConflictError{source: source, name: other}
+		}
+	}
+	if m, ok := s.monitors[source]; ok {
+		return m, nil
+	}
+	glog.Infof("Registering external monitor for source %q", source)
+	m := newExternalMonitor(source)
+	if err := s.registry.ReloadMonitors(map[string]types.Monitor{name: m}); err != nil {
+		return nil, fmt.Errorf("failed to register the source: %v", err)
+	}
+	s.monitors[source] = m
+	return m, nil
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pushapi
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+// fakeRegistry starts the registered monitors, and records their status channels.
+type fakeRegistry struct {
+	channels map[string]<-chan *types.Status
+	sources  map[string]string
+	err      error
+}
+
+func newFakeRegistry() *fakeRegistry {
+	return &fakeRegistry{
+		channels: make(map[string]<-chan *types.Status),
+		sources:  make(map[string]string),
+	}
+}
+
+func (f *fakeRegistry) ReloadMonitors(monitors map[string]types.Monitor) error {
+	if f.err != nil {
+		return f.err
+	}
+	for name, m := range monitors {
+		ch, _ := m.Start()
+		f.channels[name] = ch
+	}
+	return nil
+}
+
+func (f *fakeRegistry) Sources() map[string]string {
+	return f.sources
+}
+
+func push(s *Server, method, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.mux.ServeHTTP(w, httptest.NewRequest(method, statusesPath, strings.NewReader(body)))
+	return w
+}
+
+func TestHandleStatus(t *testing.T) {
+	registry := newFakeRegistry()
+	s := NewServer("", registry)
+
+	w := push(s, "POST", `{"source":"foo","events":[{"severity":"warn","reason":"FooEvent","message":"foo"}],
+		"conditions":[{"type":"FooProblem","status":"True","reason":"Foo","message":"foo"}]}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	if assert.Contains(t, registry.channels, "external:foo", "the source should be registered") {
+		status := <-registry.channels["external:foo"]
+		assert.Equal(t, "foo", status.Source)
+		if assert.Len(t, status.Events, 1) {
+			assert.Equal(t, "FooEvent", status.Events[0].Reason)
+			assert.False(t, status.Events[0].Timestamp.IsZero(), "event timestamp should be set")
+		}
+		if assert.Len(t, status.Conditions, 1) {
+			assert.Equal(t, types.True, status.Conditions[0].Status)
+			assert.False(t, status.Conditions[0].Transition.IsZero(), "condition transition should be set")
+		}
+	}
+
+	w = push(s, "POST", `{"source":"foo"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Len(t, registry.channels, 1, "the source should only be registered once")
+
+	for desc, test := range map[string]struct {
+		method string
+		body   string
+		code   int
+		error  string
+	}{
+		"wrong method": {
+			method: "GET",
+			code:   http.StatusMethodNotAllowed,
+		},
+		"malformed status": {
+			method: "POST",
+			body:   `{"source":`,
+			code:   http.StatusBadRequest,
+			error:  "failed to decode status",
+		},
+		"unknown field": {
+			method: "POST",
+			body:   `{"source":"foo","condition":[]}`,
+			code:   http.StatusBadRequest,
+			error:  `unknown field "condition"`,
+		},
+		"invalid status": {
+			method: "POST",
+			body:   `{"source":"foo","conditions":[{"type":"FooProblem","status":"Yes","reason":"Foo"}]}`,
+			code:   http.StatusBadRequest,
+			error:  `conditions[0]: status "Yes" is not one of`,
+		},
+	} {
+		w := push(s, test.method, test.body)
+		assert.Equal(t, test.code, w.Code, desc)
+		assert.Contains(t, w.Body.String(), test.error, desc)
+	}
+
+	// The statuses of a stopped source are rejected.
+	s.monitors["foo"].Stop()
+	w = push(s, "POST", `{"source":"foo"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestSourceConflict(t *testing.T) {
+	registry := newFakeRegistry()
+	registry.sources["config/kernel-monitor.json"] = "kernel-monitor"
+	s := NewServer("", registry)
+
+	// The sources reported by the other problem daemons can't be pushed.
+	w := push(s, "POST", `{"source":"kernel-monitor","conditions":[{"type":"KernelDeadlock","status":"False","reason":"Fake"}]}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `source "kernel-monitor" is reported by problem daemon "config/kernel-monitor.json"`)
+	assert.Empty(t, registry.channels, "the conflicting source should not be registered")
+
+	// The external monitor reporting the source doesn't conflict with itself.
+	w = push(s, "POST", `{"source":"foo"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	registry.sources["external:foo"] = "foo"
+	w = push(s, "POST", `{"source":"foo"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+}
+
+func TestRegisterFailure(t *testing.T) {
+	registry := newFakeRegistry()
+	registry.err = errors.New("problem detector is stopping")
+	s := NewServer("", registry)
+
+	w := push(s, "POST", `{"source":"foo"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "problem detector is stopping")
+	assert.Empty(t, s.monitors, "the external monitor should not be cached if it fails to register")
+
+	// The source is registered once the registry recovers.
+	registry.err = nil
+	w = push(s, "POST", `{"source":"foo"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Contains(t, registry.channels, "external:foo")
+}
+
+func TestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "push-api")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "push.sock")
+
+	// A regular file should not be replaced.
+	if err := ioutil.WriteFile(socketPath, nil, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	assert.Error(t, NewServer(socketPath, newFakeRegistry()).Start())
+	os.Remove(socketPath)
+
+	registry := newFakeRegistry()
+	if err := NewServer(socketPath, registry).Start(); err != nil {
+		t.Fatalf("Failed to start push API: %v", err)
+	}
+	info, err := os.Stat(socketPath)
+	if err != nil {
+		t.Fatalf("Failed to stat socket: %v", err)
+	}
+	assert.Equal(t, os.FileMode(socketMode), info.Mode().Perm(), "only the owner should access the socket")
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+	resp, err := client.Post("http://unix"+statusesPath, "application/json", strings.NewReader(`{"source":"foo"}`))
+	if err != nil {
+		t.Fatalf("Failed to push status: %v", err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	assert.Contains(t, registry.channels, "external:foo")
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read dir: %v", err)
+	}
+	assert.Len(t, files, 1, "only the socket should be left in the directory")
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pushapi
+
+import (
+	"fmt"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+// validateStatus validates a status pushed by an external problem daemon. The missing
+// timestamps of the events and the conditions are set to now.
+func validateStatus(status *types.Status, now time.Time) error {
+	var errs []error
+	if status.Source == "" {
+		errs = append(errs, fmt.Errorf("source is not specified"))
+	}
+	for i := range status.Events {
+		event := &status.Events[i]
+		if !types.IsValidSeverity(event.Severity) {
+			errs = append(errs, fmt.Errorf("events[%d]: severity %q is not one of %q", i, event.Severity, types.Severities))
+		}
+		if event.Reason == "" {
+			errs = append(errs, fmt.Errorf("events[%d]: reason is not specified", i))
+		}
+		if event.Timestamp.IsZero() {
+			event.Timestamp = now
+		}
+	}
+	conditionTypes := make(map[string]bool)
+	for i := range status.Conditions {
+		condition := &status.Conditions[i]
+		if condition.Type == "" {
+			errs = append(errs, fmt.Errorf("conditions[%d]: type is not specified", i))
+		} else if conditionTypes[condition.Type] {
+			errs = append(errs, fmt.Errorf("conditions[%d]: condition %q is duplicated", i, condition.Type))
+		}
+		conditionTypes[condition.Type] = true
+		switch condition.Status {
+		case types.True, types.False, types.Unknown:
+		default:
+			errs = append(errs, fmt.Errorf("conditions[%d]: status %q is not one of %q, %q and %q",
+				i, condition.Status, types.True, types.False, types.Unknown))
+		}
+		if condition.Reason == "" {
+			errs = append(errs, fmt.Errorf("conditions[%d]: reason is not specified", i))
+		}
+		if condition.Transition.IsZero() {
+			condition.Transition = now
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
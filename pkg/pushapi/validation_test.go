@@ -0,0 +1,88 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pushapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+func TestValidateStatus(t *testing.T) {
+	now := time.Now()
+	for desc, test := range map[string]struct {
+		status types.Status
+		errors []string
+	}{
+		"valid status": {
+			status: types.Status{
+				Source:     "foo",
+				Events:     []types.Event{{Severity: types.Info, Reason: "FooEvent"}},
+				Conditions: []types.Condition{{Type: "FooProblem", Status: types.Unknown, Reason: "Foo"}},
+			},
+		},
+		"status without events and conditions": {
+			status: types.Status{Source: "foo"},
+		},
+		"missing source": {
+			status: types.Status{},
+			errors: []string{"source is not specified"},
+		},
+		"invalid events": {
+			status: types.Status{
+				Source: "foo",
+				Events: []types.Event{{Severity: types.Info, Reason: "FooEvent"}, {Severity: "fatal"}},
+			},
+			errors: []string{
+				`events[1]: severity "fatal" is not one of ["info" "warn" "error" "critical"]`,
+				"events[1]: reason is not specified",
+			},
+		},
+		"invalid conditions": {
+			status: types.Status{
+				Source: "foo",
+				Conditions: []types.Condition{
+					{Type: "FooProblem", Status: types.True, Reason: "Foo"},
+					{Type: "FooProblem", Status: types.False, Reason: "NoFoo"},
+					{Status: types.True},
+				},
+			},
+			errors: []string{
+				`conditions[1]: condition "FooProblem" is duplicated`,
+				"conditions[2]: type is not specified",
+				"conditions[2]: reason is not specified",
+			},
+		},
+	} {
+		err := validateStatus(&test.status, now)
+		if len(test.errors) == 0 {
+			assert.NoError(t, err, desc)
+			continue
+		}
+		var messages []string
+		if agg, ok := err.(utilerrors.Aggregate); assert.True(t, ok, desc) {
+			for _, err := range agg.Errors() {
+				messages = append(messages, err.Error())
+			}
+		}
+		assert.Equal(t, test.errors, messages, desc)
+	}
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"k8s.io/node-problem-detector/cmd/options"
+	"k8s.io/node-problem-detector/pkg/types"
+	"k8s.io/node-problem-detector/pkg/util"
+	"k8s.io/node-problem-detector/pkg/version"
+)
+
+// Server is the node problem detector server, which serves the local API regardless of which
+// exporters are enabled.
+type Server struct {
+	addr     string
+	mux      *http.ServeMux
+	recorder *StatusRecorder
+	// heartbeats returns the latest heartbeats of the problem daemons.
+	heartbeats func() map[string]types.Heartbeat
+	// stalenessThreshold is the age after which a heartbeat is stale, 0 if heartbeats never
+	// become stale.
+	stalenessThreshold time.Duration
+	clock              clock.Clock
+}
+
+// monitorHealth is the health of a problem daemon.
+type monitorHealth struct {
+	types.Heartbeat
+	// Stale is true if the problem daemon hasn't reported a heartbeat for longer than the
+	// staleness threshold.
+	Stale bool `json:"stale"`
+}
+
+// health is the health of node problem detector.
+type health struct {
+	// Healthy is false if any problem daemon is stale.
+	Healthy bool `json:"healthy"`
+	// Monitors are the health of the problem daemons keyed by their config paths.
+	Monitors map[string]monitorHealth `json:"monitors"`
+}
+
+// NewServer creates the node problem detector server. The conditions and the events are served
+// from recorder, the loaded configurations from configPaths, and the health from heartbeats.
+func NewServer(npdo *options.NodeProblemDetectorOptions, recorder *StatusRecorder,
+	configPaths func() types.ProblemDaemonConfigPathMap, heartbeats func() map[string]types.Heartbeat) *Server {
+	s := &Server{
+		addr:               net.JoinHostPort(npdo.ServerAddress, strconv.Itoa(npdo.ServerPort)),
+		mux:                http.NewServeMux(),
+		recorder:           recorder,
+		heartbeats:         heartbeats,
+		stalenessThreshold: npdo.MonitorStalenessThreshold,
+		clock:              clock.RealClock{},
+	}
+	mux := s.mux
+
+	// Add healthz http request handler, which fails if any problem daemon is stale.
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		h := s.health()
+		code := http.StatusOK
+		if !h.Healthy {
+			code = http.StatusServiceUnavailable
+		}
+		util.ReturnHTTPJsonWithStatus(w, code, h)
+	})
+
+	// Add the handlers to serve the current conditions of the node, and of each source.
+	mux.HandleFunc("/conditions", func(w http.ResponseWriter, r *http.Request) {
+		util.ReturnHTTPJson(w, recorder.Conditions())
+	})
+	mux.HandleFunc("/conditions/sources", func(w http.ResponseWriter, r *http.Request) {
+		util.ReturnHTTPJson(w, recorder.SourceConditions())
+	})
+
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		util.ReturnHTTPJson(w, recorder.Events())
+	})
+
+	// Add the handler to stream the statuses exported by the problem detector.
+	mux.HandleFunc("/stream", s.handleStream)
+
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		util.ReturnHTTPJson(w, map[string]string{"version": version.Version()})
+	})
+
+	mux.HandleFunc("/configs", func(w http.ResponseWriter, r *http.Request) {
+		util.ReturnHTTPJson(w, configPaths())
+	})
+
+	if npdo.EnablePprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+
+	return s
+}
+
+// health checks the heartbeats of the problem daemons.
+func (s *Server) health() health {
+	h := health{
+		Healthy:  true,
+		Monitors: make(map[string]monitorHealth),
+	}
+	now := s.clock.Now()
+	for configPath, heartbeat := range s.heartbeats() {
+		stale := s.stalenessThreshold > 0 && now.Sub(heartbeat.Timestamp) > s.stalenessThreshold
+		if stale {
+			h.Healthy = false
+		}
+		h.Monitors[configPath] = monitorHealth{Heartbeat: heartbeat, Stale: stale}
+	}
+	return h
+}
+
+// Start starts serving in the background, panics if the server fails.
+func (s *Server) Start() {
+	go func() {
+		if err := http.ListenAndServe(s.addr, s.mux); err != nil {
+			glog.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+	glog.Infof("Node problem detector server started at %s", s.addr)
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"k8s.io/node-problem-detector/cmd/options"
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+func TestServer(t *testing.T) {
+	recorder := NewStatusRecorder(10, 10)
+	recorder.ExportProblems(&types.Status{
+		Source:     "foo",
+		Events:     []types.Event{{Severity: types.Warn, Reason: "FooEvent"}},
+		Conditions: []types.Condition{{Type: "FooProblem", Status: types.True, Reason: "Foo"}},
+	})
+	configPaths := func() types.ProblemDaemonConfigPathMap {
+		return types.ProblemDaemonConfigPathMap{"foo-monitor": &[]string{"foo.json"}}
+	}
+	heartbeats := func() map[string]types.Heartbeat { return nil }
+
+	for desc, test := range map[string]struct {
+		enablePprof bool
+		path        string
+		code        int
+		expected    string
+	}{
+		"conditions": {
+			path:     "/conditions",
+			code:     http.StatusOK,
+			expected: `[{"type":"FooProblem","status":"True","transition":"0001-01-01T00:00:00Z","reason":"Foo","message":""}]`,
+		},
+		"source conditions": {
+			path:     "/conditions/sources",
+			code:     http.StatusOK,
+			expected: `{"foo":[{"type":"FooProblem","status":"True","transition":"0001-01-01T00:00:00Z","reason":"Foo","message":""}]}`,
+		},
+		"events": {
+			path:     "/events",
+			code:     http.StatusOK,
+			expected: `[{"source":"foo","severity":"warn","timestamp":"0001-01-01T00:00:00Z","reason":"FooEvent","message":""}]`,
+		},
+		"version": {
+			path:     "/version",
+			code:     http.StatusOK,
+			expected: `{"version":"UNKNOWN"}`,
+		},
+		"configs": {
+			path:     "/configs",
+			code:     http.StatusOK,
+			expected: `{"foo-monitor":["foo.json"]}`,
+		},
+		"pprof disabled": {
+			path: "/debug/pprof/",
+			code: http.StatusNotFound,
+		},
+		"pprof enabled": {
+			enablePprof: true,
+			path:        "/debug/pprof/",
+			code:        http.StatusOK,
+		},
+	} {
+		npdo := &options.NodeProblemDetectorOptions{EnablePprof: test.enablePprof}
+		s := NewServer(npdo, recorder, configPaths, heartbeats)
+		w := httptest.NewRecorder()
+		s.mux.ServeHTTP(w, httptest.NewRequest("GET", test.path, nil))
+		assert.Equal(t, test.code, w.Code, desc)
+		if test.expected == "" {
+			continue
+		}
+		assert.JSONEq(t, test.expected, w.Body.String(), desc)
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	heartbeats := map[string]types.Heartbeat{
+		"foo.json": {Timestamp: now.Add(-time.Minute), Message: "Processed log line"},
+		"bar.json": {Timestamp: now.Add(-time.Hour), Message: "Received plugin result"},
+	}
+	for desc, test := range map[string]struct {
+		stalenessThreshold time.Duration
+		code               int
+		expected           string
+	}{
+		"staleness check disabled": {
+			code: http.StatusOK,
+			expected: `{"healthy":true,"monitors":{
+				"foo.json":{"timestamp":"2018-12-31T23:59:00Z","message":"Processed log line","stale":false},
+				"bar.json":{"timestamp":"2018-12-31T23:00:00Z","message":"Received plugin result","stale":false}}}`,
+		},
+		"no stale monitor": {
+			stalenessThreshold: 2 * time.Hour,
+			code:               http.StatusOK,
+			expected: `{"healthy":true,"monitors":{
+				"foo.json":{"timestamp":"2018-12-31T23:59:00Z","message":"Processed log line","stale":false},
+				"bar.json":{"timestamp":"2018-12-31T23:00:00Z","message":"Received plugin result","stale":false}}}`,
+		},
+		"stale monitor": {
+			stalenessThreshold: 10 * time.Minute,
+			code:               http.StatusServiceUnavailable,
+			expected: `{"healthy":false,"monitors":{
+				"foo.json":{"timestamp":"2018-12-31T23:59:00Z","message":"Processed log line","stale":false},
+				"bar.json":{"timestamp":"2018-12-31T23:00:00Z","message":"Received plugin result","stale":true}}}`,
+		},
+	} {
+		npdo := &options.NodeProblemDetectorOptions{MonitorStalenessThreshold: test.stalenessThreshold}
+		s := NewServer(npdo, NewStatusRecorder(10, 10), nil, func() map[string]types.Heartbeat { return heartbeats })
+		s.clock = clock.NewFakeClock(now)
+		w := httptest.NewRecorder()
+		s.mux.ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+		assert.Equal(t, test.code, w.Code, desc)
+		assert.JSONEq(t, test.expected, w.Body.String(), desc)
+	}
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"sort"
+	"sync"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+// RecordedEvent is an event recorded together with the source of the status it is reported in.
+type RecordedEvent struct {
+	// Source is the name of the problem daemon which reported the event.
+	Source string `json:"source"`
+	types.Event
+}
+
+// SequencedStatus is a recorded status with its sequence number.
+type SequencedStatus struct {
+	// Seq is the sequence number of the status, which starts from 1 when node problem detector
+	// starts.
+	Seq uint64
+	*types.Status
+}
+
+// StatusRecorder is an exporter which records the current conditions, the recent events and the
+// recent statuses, so that they can be served by the node problem detector server.
+type StatusRecorder struct {
+	sync.RWMutex
+	// conditions are the current conditions keyed by condition type. The last reported
+	// condition of each type wins.
+	conditions map[string]types.Condition
+	// sourceConditions are the latest conditions reported by each source.
+	sourceConditions map[string][]types.Condition
+	// events are the recent events, from oldest to newest.
+	events    []RecordedEvent
+	maxEvents int
+	// statuses are the recent statuses, from oldest to newest.
+	statuses    []SequencedStatus
+	maxStatuses int
+	// seq is the sequence number of the last recorded status.
+	seq uint64
+	// recorded is closed and replaced when a status is recorded.
+	recorded chan struct{}
+}
+
+// NewStatusRecorder creates a status recorder, which keeps at most maxEvents recent events and
+// maxStatuses recent statuses.
+func NewStatusRecorder(maxEvents, maxStatuses int) *StatusRecorder {
+	return &StatusRecorder{
+		conditions:       make(map[string]types.Condition),
+		sourceConditions: make(map[string][]types.Condition),
+		maxEvents:        maxEvents,
+		maxStatuses:      maxStatuses,
+		recorded:         make(chan struct{}),
+	}
+}
+
+// ExportProblems records the conditions and the events in the status.
+func (r *StatusRecorder) ExportProblems(status *types.Status) {
+	r.Lock()
+	defer r.Unlock()
+	if status.Conditions != nil {
+		r.sourceConditions[status.Source] = append([]types.Condition(nil), status.Conditions...)
+		for _, condition := range status.Conditions {
+			r.conditions[condition.Type] = condition
+		}
+	}
+	for _, event := range status.Events {
+		r.events = append(r.events, RecordedEvent{Source: status.Source, Event: event})
+	}
+	if len(r.events) > r.maxEvents {
+		r.events = r.events[len(r.events)-r.maxEvents:]
+	}
+	r.seq++
+	r.statuses = append(r.statuses, SequencedStatus{Seq: r.seq, Status: status})
+	if len(r.statuses) > r.maxStatuses {
+		r.statuses = r.statuses[len(r.statuses)-r.maxStatuses:]
+	}
+	close(r.recorded)
+	r.recorded = make(chan struct{})
+}
+
+// Conditions returns the current conditions of the node, sorted by condition type.
+func (r *StatusRecorder) Conditions() []types.Condition {
+	r.RLock()
+	defer r.RUnlock()
+	conditions := make([]types.Condition, 0, len(r.conditions))
+	for _, condition := range r.conditions {
+		conditions = append(conditions, condition)
+	}
+	sort.Slice(conditions, func(i, j int) bool { return conditions[i].Type < conditions[j].Type })
+	return conditions
+}
+
+// SourceConditions returns the latest conditions reported by each source.
+func (r *StatusRecorder) SourceConditions() map[string][]types.Condition {
+	r.RLock()
+	defer r.RUnlock()
+	sourceConditions := make(map[string][]types.Condition, len(r.sourceConditions))
+	for source, conditions := range r.sourceConditions {
+		sourceConditions[source] = append([]types.Condition(nil), conditions...)
+	}
+	return sourceConditions
+}
+
+// Events returns the recent events, from oldest to newest.
+func (r *StatusRecorder) Events() []RecordedEvent {
+	r.RLock()
+	defer r.RUnlock()
+	return append([]RecordedEvent{}, r.events...)
+}
+
+// Seq returns the sequence number of the last recorded status, 0 if no status is recorded.
+func (r *StatusRecorder) Seq() uint64 {
+	r.RLock()
+	defer r.RUnlock()
+	return r.seq
+}
+
+// StatusesSince returns the recent statuses recorded after the sequence number, from oldest to
+// newest, and a channel which is closed once a new status is recorded. The statuses which are
+// no longer kept are skipped. All recent statuses are returned if seq is larger than the sequence
+// number of the last recorded status, e.g. when node problem detector is restarted.
+func (r *StatusRecorder) StatusesSince(seq uint64) ([]SequencedStatus, <-chan struct{}) {
+	r.RLock()
+	defer r.RUnlock()
+	if seq > r.seq {
+		seq = 0
+	}
+	i := sort.Search(len(r.statuses), func(i int) bool { return r.statuses[i].Seq > seq })
+	return append([]SequencedStatus{}, r.statuses[i:]...), r.recorded
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+func TestStatusRecorder(t *testing.T) {
+	r := NewStatusRecorder(2, 2)
+	fooCondition := types.Condition{Type: "FooProblem", Status: types.True, Reason: "Foo"}
+	barCondition := types.Condition{Type: "BarProblem", Status: types.False, Reason: "NoBar"}
+	r.ExportProblems(&types.Status{
+		Source:     "foo",
+		Events:     []types.Event{{Reason: "event1"}, {Reason: "event2"}},
+		Conditions: []types.Condition{fooCondition},
+	})
+	r.ExportProblems(&types.Status{
+		Source:     "bar",
+		Events:     []types.Event{{Reason: "event3"}},
+		Conditions: []types.Condition{barCondition},
+	})
+	// A status without conditions doesn't clear the conditions of the source.
+	r.ExportProblems(&types.Status{Source: "foo"})
+
+	assert.Equal(t, []types.Condition{barCondition, fooCondition}, r.Conditions())
+	assert.Equal(t, map[string][]types.Condition{
+		"foo": {fooCondition},
+		"bar": {barCondition},
+	}, r.SourceConditions())
+	assert.Equal(t, []RecordedEvent{
+		{Source: "foo", Event: types.Event{Reason: "event2"}},
+		{Source: "bar", Event: types.Event{Reason: "event3"}},
+	}, r.Events(), "only the most recent events should be kept")
+}
+
+func TestStatusesSince(t *testing.T) {
+	r := NewStatusRecorder(10, 2)
+	statuses, recorded := r.StatusesSince(0)
+	assert.Empty(t, statuses)
+	for _, source := range []string{"foo", "bar", "baz"} {
+		r.ExportProblems(&types.Status{Source: source})
+	}
+	select {
+	case <-recorded:
+	default:
+		t.Error("recorded channel should be closed once a status is recorded")
+	}
+	assert.Equal(t, uint64(3), r.Seq())
+
+	sources := func(statuses []SequencedStatus) map[uint64]string {
+		m := make(map[uint64]string)
+		for _, status := range statuses {
+			m[status.Seq] = status.Source
+		}
+		return m
+	}
+	statuses, _ = r.StatusesSince(0)
+	assert.Equal(t, map[uint64]string{2: "bar", 3: "baz"}, sources(statuses), "only the most recent statuses should be kept")
+	statuses, _ = r.StatusesSince(2)
+	assert.Equal(t, map[uint64]string{3: "baz"}, sources(statuses))
+	statuses, _ = r.StatusesSince(3)
+	assert.Empty(t, statuses)
+	statuses, _ = r.StatusesSince(10)
+	assert.Equal(t, map[uint64]string{2: "bar", 3: "baz"}, sources(statuses), "all statuses should be returned after restart")
+}
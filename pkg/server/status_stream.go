@@ -0,0 +1,144 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+// statusFilter filters the streamed statuses. An empty set matches everything.
+type statusFilter struct {
+	sources        map[string]bool
+	severities     map[string]bool
+	conditionTypes map[string]bool
+}
+
+// newStatusFilter parses the filter from the query parameters "source", "severity" and
+// "condition", each of which accepts comma separated values and may be repeated.
+func newStatusFilter(query url.Values) statusFilter {
+	parse := func(key string) map[string]bool {
+		set := make(map[string]bool)
+		for _, values := range query[key] {
+			for _, value := range strings.Split(values, ",") {
+				if value != "" {
+					set[value] = true
+				}
+			}
+		}
+		return set
+	}
+	return statusFilter{
+		sources:        parse("source"),
+		severities:     parse("severity"),
+		conditionTypes: parse("condition"),
+	}
+}
+
+// filter returns the status with only the matched events and conditions, or nil if nothing in
+// the status is matched.
+func (f statusFilter) filter(status *types.Status) *types.Status {
+	if len(f.sources) != 0 && !f.sources[status.Source] {
+		return nil
+	}
+	if len(f.severities) == 0 && len(f.conditionTypes) == 0 {
+		return status
+	}
+	filtered := &types.Status{
+		Source:     status.Source,
+		Events:     status.Events,
+		Conditions: status.Conditions,
+	}
+	if len(f.severities) != 0 {
+		filtered.Events = nil
+		for _, event := range status.Events {
+			if f.severities[string(event.Severity)] {
+				filtered.Events = append(filtered.Events, event)
+			}
+		}
+	}
+	if len(f.conditionTypes) != 0 {
+		filtered.Conditions = nil
+		for _, condition := range status.Conditions {
+			if f.conditionTypes[condition.Type] {
+				filtered.Conditions = append(filtered.Conditions, condition)
+			}
+		}
+	}
+	if len(filtered.Events) == 0 && len(filtered.Conditions) == 0 {
+		return nil
+	}
+	return filtered
+}
+
+// handleStream streams the recorded statuses as Server-Sent Events, with the sequence numbers
+// as the event ids. The stream starts after the sequence number in the Last-Event-ID header or
+// the "since" query parameter, or with the next recorded status if neither is specified.
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	seq := s.recorder.Seq()
+	since := r.Header.Get("Last-Event-ID")
+	if since == "" {
+		since = r.URL.Query().Get("since")
+	}
+	if since != "" {
+		var err error
+		if seq, err = strconv.ParseUint(since, 10, 64); err != nil {
+			http.Error(w, fmt.Sprintf("invalid sequence number %q: %v", since, err), http.StatusBadRequest)
+			return
+		}
+	}
+	filter := newStatusFilter(r.URL.Query())
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		statuses, recorded := s.recorder.StatusesSince(seq)
+		for _, status := range statuses {
+			seq = status.Seq
+			filtered := filter.filter(status.Status)
+			if filtered == nil {
+				continue
+			}
+			data, err := json.Marshal(filtered)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", status.Seq, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+		select {
+		case <-recorded:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
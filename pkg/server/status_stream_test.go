@@ -0,0 +1,132 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/node-problem-detector/cmd/options"
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+func TestStatusFilter(t *testing.T) {
+	status := &types.Status{
+		Source: "foo",
+		Events: []types.Event{
+			{Severity: types.Info, Reason: "InfoEvent"},
+			{Severity: types.Warn, Reason: "WarnEvent"},
+		},
+		Conditions: []types.Condition{
+			{Type: "FooProblem", Status: types.True},
+			{Type: "BarProblem", Status: types.False},
+		},
+	}
+	for desc, test := range map[string]struct {
+		query    string
+		expected *types.Status
+	}{
+		"no filter": {
+			query:    "",
+			expected: status,
+		},
+		"source matched": {
+			query:    "source=bar,foo",
+			expected: status,
+		},
+		"source not matched": {
+			query: "source=bar",
+		},
+		"severity": {
+			query: "severity=warn",
+			expected: &types.Status{
+				Source:     "foo",
+				Events:     []types.Event{{Severity: types.Warn, Reason: "WarnEvent"}},
+				Conditions: status.Conditions,
+			},
+		},
+		"condition type": {
+			query: "condition=BarProblem&condition=BazProblem",
+			expected: &types.Status{
+				Source:     "foo",
+				Events:     status.Events,
+				Conditions: []types.Condition{{Type: "BarProblem", Status: types.False}},
+			},
+		},
+		"nothing matched": {
+			query: "severity=error&condition=BazProblem",
+		},
+	} {
+		query, err := url.ParseQuery(test.query)
+		assert.NoError(t, err, desc)
+		assert.Equal(t, test.expected, newStatusFilter(query).filter(status), desc)
+	}
+}
+
+func TestStream(t *testing.T) {
+	recorder := NewStatusRecorder(10, 10)
+	recorder.ExportProblems(&types.Status{Source: "foo"})
+	recorder.ExportProblems(&types.Status{Source: "bar", Events: []types.Event{{Severity: types.Warn, Reason: "Bar"}}})
+	s := NewServer(&options.NodeProblemDetectorOptions{}, recorder, nil, nil)
+	ts := httptest.NewServer(s.mux)
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL+"/stream?source=bar,baz", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	// Resume after the first status.
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to request stream: %v", err)
+	}
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() []string {
+		var lines []string
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("Failed to read stream: %v", err)
+			}
+			if line == "\n" {
+				return lines
+			}
+			lines = append(lines, line)
+		}
+	}
+	assert.Equal(t, []string{
+		"id: 2\n",
+		`data: {"source":"bar","events":[{"severity":"warn","timestamp":"0001-01-01T00:00:00Z","reason":"Bar","message":""}],"conditions":null}` + "\n",
+	}, readEvent())
+
+	// The statuses recorded later are streamed, and filtered.
+	recorder.ExportProblems(&types.Status{Source: "foo"})
+	recorder.ExportProblems(&types.Status{Source: "baz"})
+	assert.Equal(t, []string{
+		"id: 4\n",
+		`data: {"source":"baz","events":null,"conditions":null}` + "\n",
+	}, readEvent())
+}
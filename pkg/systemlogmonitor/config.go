@@ -17,7 +17,12 @@ limitations under the License.
 package systemlogmonitor
 
 import (
+	"fmt"
 	"regexp"
+	"strconv"
+	"time"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 
 	watchertypes "k8s.io/node-problem-detector/pkg/systemlogmonitor/logwatchers/types"
 	systemlogtypes "k8s.io/node-problem-detector/pkg/systemlogmonitor/types"
@@ -26,6 +31,7 @@ import (
 
 var (
 	defaultBufferSize             = 10
+	defaultBufferMaxBytes         = 256 * 1024
 	defaultLookback               = "0"
 	defaultEnableMetricsReporting = true
 )
@@ -34,16 +40,31 @@ var (
 type MonitorConfig struct {
 	// WatcherConfig is the configuration of log watcher.
 	watchertypes.WatcherConfig
-	// BufferSize is the size (in lines) of the log buffer.
+	// BufferSize is the size (in lines) of the log buffer. It's ignored if BufferWindow is set.
 	BufferSize int `json:"bufferSize"`
+	// BufferWindowString is the string of BufferWindow, e.g. "5s".
+	BufferWindowString string `json:"bufferWindow,omitempty"`
+	// BufferWindow is the age of the logs kept in the log buffer relative to the newest log. The
+	// log buffer keeps logs by age instead of line count if it's set.
+	BufferWindow time.Duration `json:"-"`
+	// BufferMaxBytes is the max size of the messages kept in the log buffer if BufferWindow is set.
+	BufferMaxBytes int `json:"bufferMaxBytes,omitempty"`
 	// Source is the source name of the log monitor
 	Source string `json:"source"`
 	// DefaultConditions are the default states of all the conditions log monitor should handle.
 	DefaultConditions []types.Condition `json:"conditions"`
 	// Rules are the rules log monitor will follow to parse the log file.
 	Rules []systemlogtypes.Rule `json:"rules"`
+	// ExcludePatterns are the regular expressions which veto a match of any rule when any of the
+	// matched log lines also matches any of them.
+	ExcludePatterns []string `json:"excludePatterns,omitempty"`
 	// EnableMetricsReporting describes whether to report problems as metrics or not.
 	EnableMetricsReporting *bool `json:"metricsReporting,omitempty"`
+	// ConditionTTLStrings are the TTL strings of the conditions, keyed by condition type.
+	ConditionTTLStrings map[string]string `json:"conditionTTLs,omitempty"`
+	// ConditionTTLs are the durations after which the conditions are reset to their defaults if
+	// no permanent rule matches them again, keyed by condition type.
+	ConditionTTLs map[string]time.Duration `json:"-"`
 }
 
 // ApplyConfiguration applies default configurations.
@@ -51,21 +72,160 @@ func (mc *MonitorConfig) ApplyDefaultConfiguration() {
 	if mc.BufferSize == 0 {
 		mc.BufferSize = defaultBufferSize
 	}
+	if mc.BufferMaxBytes == 0 {
+		mc.BufferMaxBytes = defaultBufferMaxBytes
+	}
 	if mc.EnableMetricsReporting == nil {
 		mc.EnableMetricsReporting = &defaultEnableMetricsReporting
 	}
 	if mc.WatcherConfig.Lookback == "" {
 		mc.WatcherConfig.Lookback = defaultLookback
 	}
+	for i := range mc.Rules {
+		if mc.Rules[i].Severity == "" {
+			mc.Rules[i].Severity = types.Warn
+		}
+	}
 }
 
-// ValidateRules verifies whether the regular expressions in the rules are valid.
-func (mc MonitorConfig) ValidateRules() error {
-	for _, rule := range mc.Rules {
-		_, err := regexp.Compile(rule.Pattern)
+// ParseDurations parses the buffer window string, the condition TTL strings and the window
+// strings of the rules. All errors found are returned.
+func (mc *MonitorConfig) ParseDurations() error {
+	var errs []error
+	if mc.BufferWindowString != "" {
+		window, err := time.ParseDuration(mc.BufferWindowString)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("bufferWindow: invalid window %q: %v", mc.BufferWindowString, err))
+		} else if window <= 0 {
+			errs = append(errs, fmt.Errorf("bufferWindow: window %v is not positive", window))
+		} else {
+			mc.BufferWindow = window
+		}
+	}
+	for i := range mc.Rules {
+		rule := &mc.Rules[i]
+		if rule.WindowString != "" {
+			window, err := time.ParseDuration(rule.WindowString)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("rules[%d]: invalid window %q: %v", i, rule.WindowString, err))
+			} else {
+				rule.Window = window
+			}
+		}
+		if rule.SpanWindowString != "" {
+			window, err := time.ParseDuration(rule.SpanWindowString)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("rules[%d]: invalid span window %q: %v", i, rule.SpanWindowString, err))
+			} else if window <= 0 {
+				errs = append(errs, fmt.Errorf("rules[%d]: span window %v is not positive", i, window))
+			} else {
+				rule.SpanWindow = window
+			}
+		}
+	}
+	mc.ConditionTTLs = make(map[string]time.Duration)
+	for conditionType, ttlString := range mc.ConditionTTLStrings {
+		ttl, err := time.ParseDuration(ttlString)
 		if err != nil {
-			return err
+			errs = append(errs, fmt.Errorf("conditionTTLs: invalid TTL %q of condition %q: %v", ttlString, conditionType, err))
+			continue
+		}
+		if ttl <= 0 {
+			errs = append(errs, fmt.Errorf("conditionTTLs: TTL %v of condition %q is not positive", ttl, conditionType))
+			continue
+		}
+		mc.ConditionTTLs[conditionType] = ttl
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// ValidateRules verifies whether the patterns, the exclude patterns, the severities, the
+// thresholds and the comparisons of the rules, and the global exclude patterns are valid, and
+// whether the permanent rules, the recovery rules and the condition TTLs have preset default
+// conditions. All errors found are returned.
+func (mc MonitorConfig) ValidateRules() error {
+	var errs []error
+	for i, rule := range mc.Rules {
+		if reg, err := regexp.Compile(rule.Pattern); err != nil {
+			errs = append(errs, fmt.Errorf("rules[%d]: invalid pattern %q: %v", i, rule.Pattern, err))
+		} else {
+			for j, comparison := range rule.Comparisons {
+				if err := validateComparison(reg, comparison); err != nil {
+					errs = append(errs, fmt.Errorf("rules[%d]: comparisons[%d]: %v", i, j, err))
+				}
+			}
+		}
+		for _, expr := range rule.ExcludePatterns {
+			if _, err := regexp.Compile(expr); err != nil {
+				errs = append(errs, fmt.Errorf("rules[%d]: invalid exclude pattern %q: %v", i, expr, err))
+			}
+		}
+		if rule.Severity != "" && !types.IsValidSeverity(rule.Severity) {
+			errs = append(errs, fmt.Errorf("rules[%d]: severity %q is not one of %q", i, rule.Severity, types.Severities))
+		}
+		if rule.Type == types.Perm && !mc.hasDefaultCondition(rule.Condition) {
+			errs = append(errs, fmt.Errorf("rules[%d]: permanent problem %q does not have preset default condition", i, rule.Condition))
+		}
+		if rule.Type == systemlogtypes.Recovery && !mc.hasDefaultCondition(rule.Condition) {
+			errs = append(errs, fmt.Errorf("rules[%d]: recovered condition %q does not have preset default condition", i, rule.Condition))
 		}
+		if rule.Count < 0 {
+			errs = append(errs, fmt.Errorf("rules[%d]: count %d is negative", i, rule.Count))
+		}
+		if rule.Count > 0 && rule.Window <= 0 {
+			errs = append(errs, fmt.Errorf("rules[%d]: count is set without a positive window", i))
+		}
+		if rule.Count == 0 && rule.WindowString != "" {
+			errs = append(errs, fmt.Errorf("rules[%d]: window is set without count", i))
+		}
+	}
+	for i, expr := range mc.ExcludePatterns {
+		if _, err := regexp.Compile(expr); err != nil {
+			errs = append(errs, fmt.Errorf("excludePatterns[%d]: invalid pattern %q: %v", i, expr, err))
+		}
+	}
+	if mc.BufferMaxBytes < 0 {
+		errs = append(errs, fmt.Errorf("bufferMaxBytes: %d is negative", mc.BufferMaxBytes))
+	}
+	for conditionType := range mc.ConditionTTLStrings {
+		if !mc.hasDefaultCondition(conditionType) {
+			errs = append(errs, fmt.Errorf("conditionTTLs: condition %q does not have preset default condition", conditionType))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// validateComparison verifies whether the capture group of the comparison is in the regular
+// expression, and whether the comparison has any bound.
+func validateComparison(reg *regexp.Regexp, comparison systemlogtypes.Comparison) error {
+	if !hasGroup(reg, comparison.Group) {
+		return fmt.Errorf("capture group %q is not in the pattern", comparison.Group)
+	}
+	if comparison.GreaterThan == nil && comparison.GreaterThanOrEqual == nil &&
+		comparison.LessThan == nil && comparison.LessThanOrEqual == nil {
+		return fmt.Errorf("no bound of capture group %q is set", comparison.Group)
 	}
 	return nil
 }
+
+// hasGroup returns true if the regular expression has the capture group of the name or the number.
+func hasGroup(reg *regexp.Regexp, group string) bool {
+	if n, err := strconv.Atoi(group); err == nil {
+		return n > 0 && n <= reg.NumSubexp()
+	}
+	for _, name := range reg.SubexpNames() {
+		if name != "" && name == group {
+			return true
+		}
+	}
+	return false
+}
+
+func (mc MonitorConfig) hasDefaultCondition(conditionType string) bool {
+	for _, condition := range mc.DefaultConditions {
+		if condition.Type == conditionType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package systemlogmonitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+
+	systemlogtypes "k8s.io/node-problem-detector/pkg/systemlogmonitor/types"
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+func TestValidateRules(t *testing.T) {
+	defaultConditions := []types.Condition{{Type: "KernelDeadlock"}}
+	threshold := 600.0
+	for desc, test := range map[string]struct {
+		rules  []systemlogtypes.Rule
+		errors []string
+	}{
+		"valid rules": {
+			rules: []systemlogtypes.Rule{
+				{Type: types.Temp, Reason: "OOMKilling", Pattern: "Kill process .*"},
+				{Type: types.Temp, Severity: types.Critical, Reason: "KernelOops", Pattern: "BUG: unable to handle kernel NULL pointer dereference at .*"},
+				{Type: types.Perm, Condition: "KernelDeadlock", Reason: "DockerHung", Pattern: "task docker:\\w+ blocked.*"},
+				{Type: systemlogtypes.Recovery, Condition: "KernelDeadlock", Pattern: "task docker:\\w+ unblocked.*"},
+				{Type: types.Temp, Reason: "NetworkFlapping", Pattern: "Link is Down", Count: 3, WindowString: "10m", Window: 10 * time.Minute},
+				{Type: types.Temp, Reason: "TaskHung", Pattern: "task \\S+:\\w+ blocked for more than (?P<seconds>\\d+) seconds\\.",
+					Comparisons: []systemlogtypes.Comparison{{Group: "seconds", GreaterThan: &threshold}, {Group: "1", GreaterThan: &threshold}}},
+			},
+		},
+		"all invalid rules should be reported with their positions": {
+			rules: []systemlogtypes.Rule{
+				{Type: types.Temp, Reason: "OOMKilling", Pattern: "Kill process .*"},
+				{Type: types.Temp, Reason: "Invalid", Pattern: "(unclosed"},
+				{Type: types.Perm, Condition: "ReadonlyFilesystem", Reason: "FilesystemIsReadOnly", Pattern: "Remounting filesystem read-only"},
+				{Type: types.Temp, Severity: "fatal", Reason: "KernelOops", Pattern: "BUG: .*"},
+				{Type: systemlogtypes.Recovery, Condition: "ReadonlyFilesystem", Pattern: "Remounting filesystem read-write"},
+				{Type: types.Temp, Reason: "NetworkFlapping", Pattern: "Link is Down", Count: -1},
+				{Type: types.Temp, Reason: "NetworkFlapping", Pattern: "Link is Down", Count: 3},
+				{Type: types.Temp, Reason: "NetworkFlapping", Pattern: "Link is Down", WindowString: "10m", Window: 10 * time.Minute},
+				{Type: types.Temp, Reason: "TaskHung", Pattern: "task \\S+:\\w+ blocked.*", ExcludePatterns: []string{"task backup-\\w+:", "(unclosed"}},
+				{Type: types.Temp, Reason: "TaskHung", Pattern: "task \\S+:\\w+ blocked for more than (?P<seconds>\\d+) seconds\\.",
+					Comparisons: []systemlogtypes.Comparison{{Group: "second", GreaterThan: &threshold}, {Group: "seconds"}}},
+			},
+			errors: []string{
+				"rules[1]: invalid pattern \"(unclosed\": error parsing regexp: missing closing ): `(unclosed`",
+				"rules[2]: permanent problem \"ReadonlyFilesystem\" does not have preset default condition",
+				"rules[3]: severity \"fatal\" is not one of [\"info\" \"warn\" \"error\" \"critical\"]",
+				"rules[4]: recovered condition \"ReadonlyFilesystem\" does not have preset default condition",
+				"rules[5]: count -1 is negative",
+				"rules[6]: count is set without a positive window",
+				"rules[7]: window is set without count",
+				"rules[8]: invalid exclude pattern \"(unclosed\": error parsing regexp: missing closing ): `(unclosed`",
+				"rules[9]: comparisons[0]: capture group \"second\" is not in the pattern",
+				"rules[9]: comparisons[1]: no bound of capture group \"seconds\" is set",
+			},
+		},
+	} {
+		config := MonitorConfig{DefaultConditions: defaultConditions, Rules: test.rules}
+		err := config.ValidateRules()
+		if len(test.errors) == 0 {
+			assert.NoError(t, err, desc)
+			continue
+		}
+		var errs []string
+		for _, e := range err.(utilerrors.Aggregate).Errors() {
+			errs = append(errs, e.Error())
+		}
+		assert.Equal(t, test.errors, errs, desc)
+	}
+}
+
+func TestConditionTTLs(t *testing.T) {
+	config := MonitorConfig{
+		DefaultConditions:   []types.Condition{{Type: "ReadonlyFilesystem"}},
+		ConditionTTLStrings: map[string]string{"ReadonlyFilesystem": "1h"},
+	}
+	assert.NoError(t, (&config).ParseDurations())
+	assert.Equal(t, map[string]time.Duration{"ReadonlyFilesystem": time.Hour}, config.ConditionTTLs)
+	assert.NoError(t, config.ValidateRules())
+
+	config.ConditionTTLStrings = map[string]string{"ReadonlyFilesystem": "-1h"}
+	assert.EqualError(t, (&config).ParseDurations(), `conditionTTLs: TTL -1h0m0s of condition "ReadonlyFilesystem" is not positive`)
+
+	config.ConditionTTLStrings = map[string]string{"KernelDeadlock": "1h"}
+	assert.NoError(t, (&config).ParseDurations())
+	assert.EqualError(t, config.ValidateRules(), `conditionTTLs: condition "KernelDeadlock" does not have preset default condition`)
+}
+
+func TestRuleWindows(t *testing.T) {
+	config := MonitorConfig{
+		Rules: []systemlogtypes.Rule{
+			{Type: types.Temp, Reason: "OOMKilling", Pattern: "Kill process .*"},
+			{Type: types.Temp, Reason: "NetworkFlapping", Pattern: "Link is Down", Count: 3, WindowString: "10m"},
+		},
+	}
+	assert.NoError(t, (&config).ParseDurations())
+	assert.Equal(t, time.Duration(0), config.Rules[0].Window)
+	assert.Equal(t, 10*time.Minute, config.Rules[1].Window)
+
+	config.Rules[1].WindowString = "ten minutes"
+	assert.EqualError(t, (&config).ParseDurations(), `rules[1]: invalid window "ten minutes": time: invalid duration "ten minutes"`)
+}
+
+func TestSpanWindows(t *testing.T) {
+	config := MonitorConfig{
+		BufferWindowString: "10s",
+		Rules: []systemlogtypes.Rule{
+			{Type: types.Temp, Reason: "OOMKilling", Pattern: "Kill process .*\nKilled process .*", SpanWindowString: "5s"},
+		},
+	}
+	assert.NoError(t, (&config).ParseDurations())
+	assert.Equal(t, 10*time.Second, config.BufferWindow)
+	assert.Equal(t, 5*time.Second, config.Rules[0].SpanWindow)
+
+	config.BufferWindowString = "0s"
+	config.Rules[0].SpanWindowString = "five seconds"
+	assert.EqualError(t, (&config).ParseDurations(), `[bufferWindow: window 0s is not positive, `+
+		`rules[0]: invalid span window "five seconds": time: invalid duration "five seconds"]`)
+
+	config.BufferMaxBytes = -1
+	assert.EqualError(t, config.ValidateRules(), "bufferMaxBytes: -1 is negative")
+}
+
+func TestExcludePatterns(t *testing.T) {
+	config := MonitorConfig{ExcludePatterns: []string{`\(backup-.+\)`}}
+	assert.NoError(t, config.ValidateRules())
+
+	config.ExcludePatterns = append(config.ExcludePatterns, "(unclosed")
+	assert.EqualError(t, config.ValidateRules(), "excludePatterns[1]: invalid pattern \"(unclosed\": error parsing regexp: missing closing ): `(unclosed`")
+}
@@ -17,8 +17,10 @@ limitations under the License.
 package systemlogmonitor
 
 import (
+	"bytes"
 	"regexp"
 	"strings"
+	"time"
 
 	"k8s.io/node-problem-detector/pkg/systemlogmonitor/types"
 )
@@ -29,16 +31,48 @@ type LogBuffer interface {
 	Push(*types.Log)
 	// Match with regular expression in the log buffer.
 	Match(string) []*types.Log
+	// Find is the same as Match, except that it returns the capture groups in the regular
+	// expression together with the matched logs, or nil if there is no match.
+	Find(string) *LogMatch
+	// FindPattern is the same as Find, except that the pattern is precompiled.
+	FindPattern(*Pattern) *LogMatch
 	// String returns a concatenated string of the buffered logs.
 	String() string
 }
 
+// LogMatch is the match of a regular expression in the log buffer.
+type LogMatch struct {
+	// Logs are the matched logs.
+	Logs []*types.Log
+	// Labels are the values of the named capture groups, keyed by name.
+	Labels map[string]string
+	reg    *regexp.Regexp
+	text   string
+	loc    []int
+}
+
+// Expand returns the template with the references to the capture groups, e.g. $1 or ${name},
+// replaced by the values of the capture groups. See regexp.Regexp.Expand for the syntax.
+func (m *LogMatch) Expand(template string) string {
+	return string(m.reg.ExpandString(nil, template, m.text, m.loc))
+}
+
 type logBuffer struct {
-	// buffer is a simple ring buffer.
-	buffer  []*types.Log
-	msg     []string
-	max     int
-	current int
+	// logs are the buffered logs from the oldest to the newest. A buffer limited by line count is
+	// padded with nil logs at the front until it's full.
+	logs []*types.Log
+	// text is the concatenated messages of the buffered logs from text[start]. It's updated
+	// incrementally when a log is pushed, so that it's shared by all the patterns matched
+	// against the log. The messages of the evicted logs before start are dropped once they take
+	// more space than the buffered ones.
+	text  []byte
+	start int
+	// maxLines is the max number of the buffered logs, or 0 if unlimited.
+	maxLines int
+	// window is the max age of the buffered logs relative to the newest one, or 0 if unlimited.
+	window time.Duration
+	// maxBytes is the max size of text, or 0 if unlimited. The newest log is always buffered.
+	maxBytes int
 }
 
 // NewLogBuffer creates log buffer with max line number limit. Because we only match logs
@@ -47,53 +81,172 @@ type logBuffer struct {
 // lines of patterns we support.
 func NewLogBuffer(maxLines int) *logBuffer {
 	return &logBuffer{
-		buffer: make([]*types.Log, maxLines, maxLines),
-		msg:    make([]string, maxLines, maxLines),
-		max:    maxLines,
+		logs:     make([]*types.Log, maxLines),
+		text:     []byte(strings.Repeat("\n", maxLines-1)),
+		maxLines: maxLines,
+	}
+}
+
+// NewTimeWindowLogBuffer creates log buffer which keeps the logs within the time window before
+// the newest log, so that the lines of a pattern are still matched when they are interleaved
+// with many other logs. The size of the buffered messages is limited by maxBytes.
+func NewTimeWindowLogBuffer(window time.Duration, maxBytes int) *logBuffer {
+	return &logBuffer{
+		window:   window,
+		maxBytes: maxBytes,
 	}
 }
 
 func (b *logBuffer) Push(log *types.Log) {
-	b.buffer[b.current%b.max] = log
-	b.msg[b.current%b.max] = log.Message
-	b.current++
+	if len(b.logs) != 0 {
+		b.text = append(b.text, '\n')
+	}
+	b.text = append(b.text, log.Message...)
+	b.logs = append(b.logs, log)
+	for len(b.logs) > 1 && b.overflow() {
+		// Drop the oldest log and its trailing '\n'.
+		b.start += messageLen(b.logs[0]) + 1
+		b.logs[0] = nil
+		b.logs = b.logs[1:]
+	}
+	if b.start > len(b.text)-b.start {
+		n := copy(b.text, b.text[b.start:])
+		b.text = b.text[:n]
+		b.start = 0
+	}
+}
+
+// overflow returns true if the oldest log should be evicted.
+func (b *logBuffer) overflow() bool {
+	if b.maxLines > 0 && len(b.logs) > b.maxLines {
+		return true
+	}
+	if b.maxBytes > 0 && len(b.text)-b.start > b.maxBytes {
+		return true
+	}
+	newest := b.logs[len(b.logs)-1]
+	return b.window > 0 && newest.Timestamp.Sub(b.logs[0].Timestamp) > b.window
 }
 
-// TODO(random-liu): Cache regexp if garbage collection becomes a problem someday.
 func (b *logBuffer) Match(expr string) []*types.Log {
-	// The expression should be checked outside, and it must match to the end.
-	reg := regexp.MustCompile(expr + `\z`)
-	log := b.String()
-	loc := reg.FindStringIndex(log)
+	match := b.Find(expr)
+	if match == nil {
+		return nil
+	}
+	return match.Logs
+}
+
+// Find compiles the expression on every call, use FindPattern with a precompiled pattern instead
+// when the expression is matched repeatedly.
+func (b *logBuffer) Find(expr string) *LogMatch {
+	// The expression should be checked outside.
+	pattern, err := CompilePattern(expr)
+	if err != nil {
+		panic(err)
+	}
+	return b.FindPattern(pattern)
+}
+
+func (b *logBuffer) FindPattern(pattern *Pattern) *LogMatch {
+	text := b.text[b.start:]
+	// offset is where the pattern is matched from. The match must end at the end of the text, so
+	// a pattern which doesn't match line breaks is only matched against the last line.
+	lastLine := bytes.LastIndexByte(text, '\n') + 1
+	if pattern.lastLineLiteral != "" && !bytes.Contains(text[lastLine:], []byte(pattern.lastLineLiteral)) {
+		return nil
+	}
+	offset := 0
+	if pattern.singleLine {
+		offset = lastLine
+	} else if pattern.window > 0 {
+		offset = b.windowOffset(pattern.window)
+	}
+	log := text[offset:]
+	// Skip the regular expression if the log can't match.
+	if pattern.literal != "" && !bytes.Contains(log, []byte(pattern.literal)) {
+		return nil
+	}
+	reg := pattern.reg
+	loc := reg.FindSubmatchIndex(log)
 	if loc == nil {
 		// No match
 		return nil
 	}
-	// reverse index
-	s := len(log) - loc[0] - 1
-	total := 0
+	var labels map[string]string
+	for i, name := range reg.SubexpNames() {
+		// Skip the whole match, unnamed groups and named groups which don't participate.
+		if name == "" || loc[2*i] < 0 {
+			continue
+		}
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[name] = string(log[loc[2*i]:loc[2*i+1]])
+	}
+	// Collect the logs from the last one back to the one where the match starts.
 	matched := []*types.Log{}
-	for i := b.tail(); i >= b.current && b.buffer[i%b.max] != nil; i-- {
-		matched = append(matched, b.buffer[i%b.max])
-		total += len(b.msg[i%b.max]) + 1 // Add '\n'
-		if total > s {
+	end := len(text)
+	for i := len(b.logs) - 1; i >= 0 && b.logs[i] != nil; i-- {
+		matched = append(matched, b.logs[i])
+		lineStart := end - messageLen(b.logs[i])
+		// A match starting with the '\n' before a line starts from the line.
+		if lineStart-1 <= offset+loc[0] {
 			break
 		}
+		end = lineStart - 1 // Skip '\n'
 	}
 	for i := 0; i < len(matched)/2; i++ {
 		matched[i], matched[len(matched)-i-1] = matched[len(matched)-i-1], matched[i]
 	}
-	return matched
+	// Only keep the matched text, because the buffer is reused.
+	matchLoc := make([]int, len(loc))
+	for i := range loc {
+		matchLoc[i] = loc[i]
+		if loc[i] >= 0 {
+			matchLoc[i] -= loc[0]
+		}
+	}
+	return &LogMatch{
+		Logs:   matched,
+		Labels: labels,
+		reg:    reg,
+		text:   string(log[loc[0]:loc[1]]),
+		loc:    matchLoc,
+	}
+}
+
+// windowOffset returns the offset in the text of the oldest log within the window before the
+// newest log.
+func (b *logBuffer) windowOffset(window time.Duration) int {
+	text := b.text[b.start:]
+	if len(b.logs) == 0 || b.logs[len(b.logs)-1] == nil {
+		return 0
+	}
+	newest := b.logs[len(b.logs)-1]
+	offset := len(text)
+	for i := len(b.logs) - 1; i >= 0; i-- {
+		log := b.logs[i]
+		if log == nil || newest.Timestamp.Sub(log.Timestamp) > window {
+			break
+		}
+		offset -= messageLen(log)
+		if i != len(b.logs)-1 {
+			offset-- // Skip '\n'
+		}
+	}
+	return offset
 }
 
 func (b *logBuffer) String() string {
-	logs := append(b.msg[b.current%b.max:], b.msg[:b.current%b.max]...)
-	return concatLogs(logs)
+	return string(b.text[b.start:])
 }
 
-// tail returns current tail index.
-func (b *logBuffer) tail() int {
-	return b.current + b.max - 1
+// messageLen returns the length of the message of the log, which is 0 for the padding logs.
+func messageLen(log *types.Log) int {
+	if log == nil {
+		return 0
+	}
+	return len(log.Message)
 }
 
 // concatLogs concatenates multiple lines of logs into one string.
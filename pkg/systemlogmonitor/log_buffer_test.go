@@ -19,6 +19,9 @@ package systemlogmonitor
 import (
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 
 	"k8s.io/node-problem-detector/pkg/systemlogmonitor/types"
 )
@@ -34,6 +37,16 @@ func TestPush(t *testing.T) {
 			logs:     []string{"a", "b"},
 			expected: "b",
 		},
+		{
+			max:      3,
+			logs:     []string{"a"},
+			expected: "\n\na",
+		},
+		{
+			max:      3,
+			logs:     []string{"", "bb", "c", "ddd"},
+			expected: "bb\nc\nddd",
+		},
 		{
 			max:      2,
 			logs:     []string{"a", "b"},
@@ -108,3 +121,72 @@ func TestMatch(t *testing.T) {
 		}
 	}
 }
+
+func TestFind(t *testing.T) {
+	b := NewLogBuffer(2)
+	b.Push(&types.Log{Message: "Out of memory: Kill process 1234 (foo) score 1 or sacrifice child"})
+	b.Push(&types.Log{Message: "Killed process 1234 (foo) total-vm:1kB"})
+
+	match := b.Find(`Kill process (?P<pid>\d+) \((?P<process>.+)\).*\nKilled process \d+ \(.+\)(?P<unmatched> rss)?.*`)
+	assert.Len(t, match.Logs, 2)
+	assert.Equal(t, map[string]string{"pid": "1234", "process": "foo"}, match.Labels,
+		"named groups which don't participate in the match should be skipped")
+	assert.Equal(t, "OOM killed foo (pid 1234)", match.Expand("OOM killed ${process} (pid $pid)"))
+	assert.Equal(t, "OOM killed foo (pid 1234)", match.Expand("OOM killed ${2} (pid $1)"))
+
+	match = b.Find(`Killed process (\d+).*`)
+	assert.Len(t, match.Logs, 1)
+	assert.Nil(t, match.Labels, "unnamed groups should not be labels")
+	assert.Equal(t, "pid 1234", match.Expand("pid $1"))
+
+	assert.Nil(t, b.Find(`no match (?P<pid>\d+)`))
+}
+
+func TestTimeWindowLogBuffer(t *testing.T) {
+	start := time.Unix(1000, 0)
+	b := NewTimeWindowLogBuffer(5*time.Second, 10)
+	assert.Equal(t, "", b.String())
+	b.Push(&types.Log{Timestamp: start, Message: "a1"})
+	b.Push(&types.Log{Timestamp: start.Add(time.Second), Message: "b2"})
+	b.Push(&types.Log{Timestamp: start.Add(5 * time.Second), Message: "c3"})
+	assert.Equal(t, "a1\nb2\nc3", b.String())
+
+	// The logs older than the window are evicted.
+	b.Push(&types.Log{Timestamp: start.Add(6 * time.Second), Message: "d4"})
+	assert.Equal(t, "b2\nc3\nd4", b.String())
+	assert.Equal(t, []*types.Log{
+		{Timestamp: start.Add(time.Second), Message: "b2"},
+		{Timestamp: start.Add(5 * time.Second), Message: "c3"},
+		{Timestamp: start.Add(6 * time.Second), Message: "d4"},
+	}, b.Match(`b2\nc3\nd4`))
+
+	// The oldest logs are evicted when the buffer is too large.
+	b.Push(&types.Log{Timestamp: start.Add(6 * time.Second), Message: "e5"})
+	assert.Equal(t, "c3\nd4\ne5", b.String())
+	b.Push(&types.Log{Timestamp: start.Add(6 * time.Second), Message: "a very long log"})
+	assert.Equal(t, "a very long log", b.String(), "the newest log should always be kept")
+	assert.Len(t, b.Match(`a very long log`), 1)
+}
+
+func TestFindSpanPattern(t *testing.T) {
+	start := time.Unix(1000, 0)
+	pattern, err := CompileSpanPattern(`Kill process (?P<pid>\d+) \((.+)\).*\nKilled process \d+ \(.+\).*`, 5*time.Second)
+	assert.NoError(t, err)
+
+	b := NewTimeWindowLogBuffer(time.Minute, 1024)
+	b.Push(&types.Log{Timestamp: start, Message: "Out of memory: Kill process 1234 (foo) score 1 or sacrifice child"})
+	b.Push(&types.Log{Timestamp: start.Add(time.Second), Message: "eth0: link becomes ready"})
+	b.Push(&types.Log{Timestamp: start.Add(2 * time.Second), Message: "eth1: link becomes ready"})
+	assert.Nil(t, b.FindPattern(pattern))
+	b.Push(&types.Log{Timestamp: start.Add(3 * time.Second), Message: "Killed process 1234 (foo) total-vm:1kB"})
+	match := b.FindPattern(pattern)
+	assert.Len(t, match.Logs, 4, "the logs in between should be matched")
+	assert.Equal(t, map[string]string{"pid": "1234"}, match.Labels)
+	assert.Equal(t, "OOM killed foo (pid 1234)", match.Expand("OOM killed $2 (pid ${pid})"))
+	assert.Nil(t, b.Find(`Kill process (?P<pid>\d+) \((.+)\).*\nKilled process \d+ \(.+\).*`),
+		"the lines of a pattern should be adjacent by default")
+
+	// The lines are not matched if they are not logged within the window.
+	b.Push(&types.Log{Timestamp: start.Add(6 * time.Second), Message: "Killed process 1234 (foo) total-vm:1kB"})
+	assert.Nil(t, b.FindPattern(pattern))
+}
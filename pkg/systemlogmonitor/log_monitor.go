@@ -17,12 +17,14 @@ limitations under the License.
 package systemlogmonitor
 
 import (
-	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"time"
 
 	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/util/clock"
 
+	"k8s.io/node-problem-detector/pkg/checkpoint"
 	"k8s.io/node-problem-detector/pkg/problemdaemon"
 	"k8s.io/node-problem-detector/pkg/problemmetrics"
 	"k8s.io/node-problem-detector/pkg/systemlogmonitor/logwatchers"
@@ -36,63 +38,130 @@ import (
 
 const SystemLogMonitorName = "system-log-monitor"
 
+// conditionExpiryCheckInterval is the interval at which the conditions are checked against
+// their TTLs and the thresholds of the rules when no log line is processed.
+var conditionExpiryCheckInterval = 10 * time.Second
+
 func init() {
 	problemdaemon.Register(
 		SystemLogMonitorName,
 		types.ProblemDaemonHandler{
-			CreateProblemDaemonOrDie: NewLogMonitorOrDie,
-			CmdOptionDescription:     "Set to config file paths."})
+			CreateProblemDaemon:  NewLogMonitor,
+			ValidateConfig:       ValidateConfig,
+			CmdOptionDescription: "Set to config file paths."})
 }
 
 type logMonitor struct {
 	configPath string
 	watcher    watchertypes.LogWatcher
 	buffer     LogBuffer
+	// matcher matches the log buffer against the precompiled patterns of the rules.
+	matcher    *Matcher
 	config     MonitorConfig
 	conditions []types.Condition
-	logCh      <-chan *logtypes.Log
-	output     chan *types.Status
-	tomb       *tomb.Tomb
+	// restoredConditions are the conditions to start with instead of the default conditions.
+	restoredConditions []types.Condition
+	logCh              <-chan *logtypes.Log
+	output             chan *types.Status
+	tomb               *tomb.Tomb
+	clock              clock.Clock
+	// heartbeat is updated whenever a log line is processed.
+	heartbeat util.HeartbeatTracker
+	// lastMatched is the last time the conditions are matched by permanent rules, keyed by
+	// condition type. It's used to reset the conditions after their TTLs.
+	lastMatched map[string]time.Time
+	// ruleCounters are the counters of the rules with count thresholds, keyed by rule index.
+	ruleCounters map[int]*ruleCounter
 }
 
-// NewLogMonitorOrDie create a new LogMonitor, panic if error occurs.
-func NewLogMonitorOrDie(configPath string) types.Monitor {
+// NewLogMonitor creates a new LogMonitor, returns error if the configuration file can't be loaded.
+func NewLogMonitor(configPath string) (types.Monitor, error) {
 	l := &logMonitor{
 		configPath: configPath,
 		tomb:       tomb.NewTomb(),
+		clock:      clock.RealClock{},
 	}
 
-	f, err := ioutil.ReadFile(configPath)
-	if err != nil {
-		glog.Fatalf("Failed to read configuration file %q: %v", configPath, err)
-	}
-	err = json.Unmarshal(f, &l.config)
-	if err != nil {
-		glog.Fatalf("Failed to unmarshal configuration file %q: %v", configPath, err)
-	}
-	// Apply default configurations
-	(&l.config).ApplyDefaultConfiguration()
-	err = l.config.ValidateRules()
+	var err error
+	l.config, err = loadConfig(configPath)
 	if err != nil {
-		glog.Fatalf("Failed to validate %s matching rules %+v: %v", l.configPath, l.config.Rules, err)
+		return nil, fmt.Errorf("failed to load configuration file %q: %v", configPath, err)
 	}
 	glog.Infof("Finish parsing log monitor config file %s: %+v", l.configPath, l.config)
 
+	// Checkpoint the position of the log watcher under the configuration file path, like the
+	// conditions, because several log monitors may report the same source.
+	l.config.WatcherConfig.CheckpointKey = configPath
 	l.watcher = logwatchers.GetLogWatcherOrDie(l.config.WatcherConfig)
-	l.buffer = NewLogBuffer(l.config.BufferSize)
+	l.buffer = newLogBuffer(l.config)
+	l.matcher, err = NewMatcher(l.config.Rules, l.config.ExcludePatterns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile rules in configuration file %q: %v", configPath, err)
+	}
 	// A 1000 size channel should be big enough.
 	l.output = make(chan *types.Status, 1000)
 
 	if *l.config.EnableMetricsReporting {
 		initializeProblemMetricsOrDie(l.config.Rules)
 	}
-	return l
+	return l, nil
+}
+
+// ValidateConfig validates the log monitor configuration file without creating the log monitor.
+func ValidateConfig(configPath string) error {
+	_, err := loadConfig(configPath)
+	return err
+}
+
+// loadConfig reads the log monitor configuration file, applies the default configurations and
+// validates it.
+func loadConfig(configPath string) (MonitorConfig, error) {
+	config, err := readConfig(configPath)
+	if err != nil {
+		return config, err
+	}
+	if !logwatchers.IsRegistered(config.Plugin) {
+		return config, fmt.Errorf("log watcher plugin %q is not supported", config.Plugin)
+	}
+	return config, nil
+}
+
+// readConfig is the same as loadConfig, except that the log watcher plugin is not validated.
+func readConfig(configPath string) (MonitorConfig, error) {
+	var config MonitorConfig
+	f, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return config, err
+	}
+	if err := util.UnmarshalConfig(configPath, f, &config); err != nil {
+		return config, fmt.Errorf("failed to unmarshal: %v", err)
+	}
+	// Apply default configurations
+	(&config).ApplyDefaultConfiguration()
+	if err := (&config).ParseDurations(); err != nil {
+		return config, err
+	}
+	return config, config.ValidateRules()
+}
+
+// newLogBuffer creates the log buffer, which keeps logs by age if the buffer window is set, or
+// else by line count.
+func newLogBuffer(config MonitorConfig) LogBuffer {
+	if config.BufferWindow > 0 {
+		return NewTimeWindowLogBuffer(config.BufferWindow, config.BufferMaxBytes)
+	}
+	return NewLogBuffer(config.BufferSize)
 }
 
 // initializeProblemMetricsOrDie creates problem metrics for all problems and set the value to 0,
 // panic if error occurs.
 func initializeProblemMetricsOrDie(rules []systemlogtypes.Rule) {
 	for _, rule := range rules {
+		if rule.Type == systemlogtypes.Recovery || rule.ReasonTemplate != "" {
+			// Recovery rules don't report problems, and the reasons of the rules with reason
+			// templates are only known when the problems happen.
+			continue
+		}
 		if rule.Type == types.Perm {
 			err := problemmetrics.GlobalProblemMetricsManager.SetProblemGauge(rule.Condition, rule.Reason, false)
 			if err != nil {
@@ -100,7 +169,12 @@ func initializeProblemMetricsOrDie(rules []systemlogtypes.Rule) {
 					rule.Condition, rule.Reason, err)
 			}
 		}
-		err := problemmetrics.GlobalProblemMetricsManager.IncrementProblemCounter(rule.Reason, 0)
+		// Permanent problems are counted by the condition change events, which are info events.
+		severity := rule.Severity
+		if rule.Type == types.Perm {
+			severity = types.Info
+		}
+		err := problemmetrics.GlobalProblemMetricsManager.IncrementProblemCounter(rule.Reason, severity, 0)
 		if err != nil {
 			glog.Fatalf("Failed to initialize problem counter metrics for %q: %v", rule.Reason, err)
 		}
@@ -114,10 +188,29 @@ func (l *logMonitor) Start() (<-chan *types.Status, error) {
 	if err != nil {
 		return nil, err
 	}
+	l.heartbeat.Beat(l.clock.Now(), "Log monitor started")
 	go l.monitorLoop()
 	return l.output, nil
 }
 
+// Heartbeat returns the time when the last log line is processed, or the latest heartbeat of the
+// log watcher if it's later, so that a log monitor watching a quiet log is not stale as long as
+// its log watcher keeps reading the log.
+func (l *logMonitor) Heartbeat() types.Heartbeat {
+	heartbeat := l.heartbeat.Heartbeat()
+	if reporter, ok := l.watcher.(types.HeartbeatReporter); ok {
+		if watcherHeartbeat := reporter.Heartbeat(); watcherHeartbeat.Timestamp.After(heartbeat.Timestamp) {
+			return watcherHeartbeat
+		}
+	}
+	return heartbeat
+}
+
+// RestoreConditions sets the conditions log monitor starts with.
+func (l *logMonitor) RestoreConditions(conditions []types.Condition) {
+	l.restoredConditions = conditions
+}
+
 func (l *logMonitor) Stop() {
 	glog.Infof("Stop log monitor %s", l.configPath)
 	l.tomb.Stop()
@@ -125,12 +218,35 @@ func (l *logMonitor) Stop() {
 
 // monitorLoop is the main loop of log monitor.
 func (l *logMonitor) monitorLoop() {
-	defer l.tomb.Done()
+	defer func() {
+		l.flushPosition()
+		l.checkpointConditions()
+		close(l.output)
+		l.tomb.Done()
+	}()
 	l.initializeStatus()
+	var expiryCheck <-chan time.Time
+	if l.needsExpiryCheck() {
+		expiryCheck = l.clock.After(conditionExpiryCheckInterval)
+	}
+	// Keep the condition checkpoint fresh while the log is quiet.
+	conditionCheckpoint := l.clock.After(checkpoint.ConditionCheckpointRefreshInterval)
 	for {
 		select {
-		case log := <-l.logCh:
+		case log, ok := <-l.logCh:
+			if !ok {
+				// The log watcher exits unexpectedly, exit and let the log monitor be restarted.
+				glog.Errorf("Log watcher of log monitor %s exited unexpectedly", l.configPath)
+				return
+			}
 			l.parseLog(log)
+			l.checkpointPosition(log)
+		case <-expiryCheck:
+			l.expireConditions(l.clock.Now())
+			expiryCheck = l.clock.After(conditionExpiryCheckInterval)
+		case <-conditionCheckpoint:
+			l.checkpointConditions()
+			conditionCheckpoint = l.clock.After(checkpoint.ConditionCheckpointRefreshInterval)
 		case <-l.tomb.Stopping():
 			l.watcher.Stop()
 			glog.Infof("Log monitor stopped: %s", l.configPath)
@@ -143,33 +259,103 @@ func (l *logMonitor) monitorLoop() {
 func (l *logMonitor) parseLog(log *logtypes.Log) {
 	// Once there is new log, log monitor will push it into the log buffer and try
 	// to match each rule. If any rule is matched, log monitor will report a status.
+	l.heartbeat.Beat(l.clock.Now(), fmt.Sprintf("Processed log line logged at %v", log.Timestamp))
+	// Expire the conditions before matching the log line, so that the condition matched again
+	// by the log line is not reset.
+	l.expireConditions(log.Timestamp)
 	l.buffer.Push(log)
-	for _, rule := range l.config.Rules {
-		matched := l.buffer.Match(rule.Pattern)
-		if len(matched) == 0 {
+	matches := l.matcher.Match(l.buffer)
+	for i, rule := range l.config.Rules {
+		match := matches[i]
+		if match == nil {
+			continue
+		}
+		if rule.Count > 0 && !l.reachThreshold(i, rule, log.Timestamp) {
 			continue
 		}
-		status := l.generateStatus(matched, rule)
+		status := l.generateStatus(match, rule)
+		if rule.Count > 0 && rule.Type == types.Perm && rule.ClearBelowThreshold {
+			l.ruleCounters[i].triggeredReason = conditionReason(status.Conditions, rule.Condition)
+		}
 		glog.Infof("New status generated: %+v", status)
+		l.checkpointConditions()
 		l.output <- status
 	}
 }
 
-// generateStatus generates status from the logs.
-func (l *logMonitor) generateStatus(logs []*logtypes.Log, rule systemlogtypes.Rule) *types.Status {
+// reachThreshold counts the match of the rule at timestamp, and returns true if the rule is
+// matched count times within the window. The matches of a temporary rule are forgotten once the
+// threshold is reached, so that the next event is only reported after another count matches.
+func (l *logMonitor) reachThreshold(index int, rule systemlogtypes.Rule, timestamp time.Time) bool {
+	if l.ruleCounters == nil {
+		l.ruleCounters = make(map[int]*ruleCounter)
+	}
+	counter, ok := l.ruleCounters[index]
+	if !ok {
+		counter = newRuleCounter(rule.Count, rule.Window)
+		l.ruleCounters[index] = counter
+	}
+	if !counter.add(timestamp) {
+		return false
+	}
+	if rule.Type == types.Temp {
+		counter.reset()
+	}
+	return true
+}
+
+// needsExpiryCheck returns true if the conditions should be checked periodically, i.e. when any
+// condition has a TTL or is cleared below the threshold of a rule.
+func (l *logMonitor) needsExpiryCheck() bool {
+	if len(l.config.ConditionTTLs) != 0 {
+		return true
+	}
+	for _, rule := range l.config.Rules {
+		if rule.Count > 0 && rule.Type == types.Perm && rule.ClearBelowThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+// generateStatus generates status from the logs matched by the rule.
+func (l *logMonitor) generateStatus(match *LogMatch, rule systemlogtypes.Rule) *types.Status {
 	// We use the timestamp of the first log line as the timestamp of the status.
-	timestamp := logs[0].Timestamp
-	message := generateMessage(logs)
+	timestamp := match.Logs[0].Timestamp
+	message := generateMessage(match.Logs)
+	if rule.MessageTemplate != "" {
+		message = match.Expand(rule.MessageTemplate)
+	}
+	reason := rule.Reason
+	if rule.ReasonTemplate != "" {
+		reason = match.Expand(rule.ReasonTemplate)
+	}
+	labels := match.Labels
 	var events []types.Event
+	// recoveryEvents are reported but not counted as problems.
+	var recoveryEvents []types.Event
 	var changedConditions []*types.Condition
 	if rule.Type == types.Temp {
 		// For temporary error only generate event
 		events = append(events, types.Event{
-			Severity:  types.Warn,
+			Severity:  rule.Severity,
 			Timestamp: timestamp,
-			Reason:    rule.Reason,
+			Reason:    reason,
 			Message:   message,
+			Labels:    labels,
 		})
+	} else if rule.Type == systemlogtypes.Recovery {
+		// For recovery resets the condition if the problem is still reported
+		for i := range l.conditions {
+			condition := &l.conditions[i]
+			if condition.Type == rule.Condition {
+				if condition.Status == types.True {
+					recoveryEvents = append(recoveryEvents, l.resetCondition(condition, timestamp))
+					changedConditions = append(changedConditions, condition)
+				}
+				break
+			}
+		}
 	} else {
 		// For permanent error changes the condition
 		for i := range l.conditions {
@@ -178,19 +364,23 @@ func (l *logMonitor) generateStatus(logs []*logtypes.Log, rule systemlogtypes.Ru
 				// Update transition timestamp and message when the condition
 				// changes. Condition is considered to be changed only when
 				// status or reason changes.
-				if condition.Status == types.False || condition.Reason != rule.Reason {
+				if condition.Status == types.False || condition.Reason != reason {
 					condition.Transition = timestamp
 					condition.Message = message
-					events = append(events, util.GenerateConditionChangeEvent(
+					condition.Labels = labels
+					event := util.GenerateConditionChangeEvent(
 						condition.Type,
 						types.True,
-						rule.Reason,
+						reason,
 						timestamp,
-					))
+					)
+					event.Labels = labels
+					events = append(events, event)
 				}
 				condition.Status = types.True
-				condition.Reason = rule.Reason
+				condition.Reason = reason
 				changedConditions = append(changedConditions, condition)
+				l.setLastMatched(condition.Type, timestamp)
 				break
 			}
 		}
@@ -198,51 +388,208 @@ func (l *logMonitor) generateStatus(logs []*logtypes.Log, rule systemlogtypes.Ru
 
 	if *l.config.EnableMetricsReporting {
 		for _, event := range events {
-			err := problemmetrics.GlobalProblemMetricsManager.IncrementProblemCounter(event.Reason, 1)
+			err := problemmetrics.GlobalProblemMetricsManager.IncrementProblemCounter(event.Reason, event.Severity, 1)
 			if err != nil {
 				glog.Errorf("Failed to update problem counter metrics for %q: %v", event.Reason, err)
 			}
 		}
-		for _, condition := range changedConditions {
-			err := problemmetrics.GlobalProblemMetricsManager.SetProblemGauge(
-				condition.Type, condition.Reason, condition.Status == types.True)
-			if err != nil {
-				glog.Errorf("Failed to update problem gauge metrics for problem %q, reason %q: %v",
-					condition.Type, condition.Reason, err)
-			}
-		}
+		setProblemGauges(changedConditions)
 	}
 
+	// Copy the conditions, because they are updated in place while the status is exported.
 	return &types.Status{
 		Source: l.config.Source,
 		// TODO(random-liu): Aggregate events and conditions and then do periodically report.
+		Events:     append(events, recoveryEvents...),
+		Conditions: append([]types.Condition(nil), l.conditions...),
+	}
+}
+
+// expireConditions resets the conditions which are not matched by any permanent rule within
+// their TTLs, and the conditions set by the rules whose matches fall below the thresholds, and
+// reports the status if any condition is reset.
+func (l *logMonitor) expireConditions(now time.Time) {
+	var events []types.Event
+	var expiredConditions []*types.Condition
+	for i, counter := range l.ruleCounters {
+		rule := l.config.Rules[i]
+		if counter.triggeredReason == "" || !counter.belowThreshold(now) {
+			continue
+		}
+		reason := counter.triggeredReason
+		counter.triggeredReason = ""
+		for j := range l.conditions {
+			condition := &l.conditions[j]
+			// Only reset the condition if it's still set by the rule.
+			if condition.Type == rule.Condition && condition.Status == types.True && condition.Reason == reason {
+				glog.Infof("Rule %q is matched less than %d times in %v, reset condition %s",
+					rule.Pattern, rule.Count, rule.Window, condition.Type)
+				events = append(events, l.resetCondition(condition, now))
+				expiredConditions = append(expiredConditions, condition)
+				break
+			}
+		}
+	}
+	for i := range l.conditions {
+		condition := &l.conditions[i]
+		ttl, ok := l.config.ConditionTTLs[condition.Type]
+		if !ok || condition.Status != types.True {
+			continue
+		}
+		lastMatched, ok := l.lastMatched[condition.Type]
+		if !ok || now.Sub(lastMatched) < ttl {
+			continue
+		}
+		glog.Infof("Condition %s is not matched since %v, reset it after TTL %v", condition.Type, lastMatched, ttl)
+		events = append(events, l.resetCondition(condition, now))
+		expiredConditions = append(expiredConditions, condition)
+	}
+	if len(expiredConditions) == 0 {
+		return
+	}
+	if *l.config.EnableMetricsReporting {
+		setProblemGauges(expiredConditions)
+	}
+	status := &types.Status{
+		Source:     l.config.Source,
 		Events:     events,
-		Conditions: l.conditions,
+		Conditions: append([]types.Condition(nil), l.conditions...),
+	}
+	glog.Infof("New status generated: %+v", status)
+	l.checkpointConditions()
+	l.output <- status
+}
+
+// resetCondition resets the condition to its default reason and message, and returns the
+// condition change event.
+func (l *logMonitor) resetCondition(condition *types.Condition, timestamp time.Time) types.Event {
+	condition.Status = types.False
+	condition.Transition = timestamp
+	condition.Reason = ""
+	condition.Message = ""
+	condition.Labels = nil
+	for _, defaultCondition := range l.config.DefaultConditions {
+		if defaultCondition.Type == condition.Type {
+			condition.Reason = defaultCondition.Reason
+			condition.Message = defaultCondition.Message
+			condition.Labels = defaultCondition.Labels
+			break
+		}
+	}
+	delete(l.lastMatched, condition.Type)
+	return util.GenerateConditionChangeEvent(condition.Type, types.False, condition.Reason, timestamp)
+}
+
+// setLastMatched records the time when the condition is matched by a permanent rule.
+func (l *logMonitor) setLastMatched(conditionType string, timestamp time.Time) {
+	if l.lastMatched == nil {
+		l.lastMatched = make(map[string]time.Time)
+	}
+	l.lastMatched[conditionType] = timestamp
+}
+
+// setProblemGauges updates the problem gauges of the conditions.
+func setProblemGauges(conditions []*types.Condition) {
+	for _, condition := range conditions {
+		err := problemmetrics.GlobalProblemMetricsManager.SetProblemGauge(
+			condition.Type, condition.Reason, condition.Status == types.True)
+		if err != nil {
+			glog.Errorf("Failed to update problem gauge metrics for problem %q, reason %q: %v",
+				condition.Type, condition.Reason, err)
+		}
 	}
 }
 
 // initializeStatus initializes the internal condition and also reports it to the node problem detector.
 func (l *logMonitor) initializeStatus() {
 	// Initialize the default node conditions
-	l.conditions = initialConditions(l.config.DefaultConditions)
+	restored := l.restoredConditions
+	if restored == nil {
+		// Not restarted by the problem detector, restore the conditions from the checkpoint.
+		restored = checkpoint.GlobalConditionCheckpointManager.Load(l.configPath)
+	}
+	l.conditions = initialConditions(l.config.DefaultConditions, restored, l.clock.Now())
 	glog.Infof("Initialize condition generated: %+v", l.conditions)
+	// The restored problems are considered matched when log monitor starts, so that they are
+	// not reset before their TTLs pass.
+	for _, condition := range l.conditions {
+		if condition.Status == types.True {
+			l.setLastMatched(condition.Type, l.clock.Now())
+		}
+	}
+	if *l.config.EnableMetricsReporting {
+		for _, condition := range l.conditions {
+			err := problemmetrics.GlobalProblemMetricsManager.SetProblemGauge(
+				condition.Type, condition.Reason, condition.Status == types.True)
+			if err != nil {
+				glog.Errorf("Failed to update problem gauge metrics for problem %q, reason %q: %v",
+					condition.Type, condition.Reason, err)
+			}
+		}
+	}
+	l.checkpointConditions()
 	// Update the initial status
 	l.output <- &types.Status{
 		Source:     l.config.Source,
-		Conditions: l.conditions,
+		Conditions: append([]types.Condition(nil), l.conditions...),
 	}
 }
 
-func initialConditions(defaults []types.Condition) []types.Condition {
+// checkpointConditions checkpoints the current conditions, so that they can be restored after
+// node problem detector restarts.
+func (l *logMonitor) checkpointConditions() {
+	if err := checkpoint.GlobalConditionCheckpointManager.Save(l.configPath, l.conditions); err != nil {
+		glog.Errorf("Failed to checkpoint conditions of %q: %v", l.configPath, err)
+	}
+}
+
+// checkpointPosition checkpoints the position of the log watcher after the processed log, so that
+// the logs not processed yet are read again after node problem detector restarts.
+func (l *logMonitor) checkpointPosition(log *logtypes.Log) {
+	if log.Position == nil {
+		return
+	}
+	if err := checkpoint.GlobalWatcherCheckpointManager.Save(l.config.WatcherConfig.CheckpointKey, log.Position); err != nil {
+		glog.Errorf("Failed to checkpoint log watcher position of %q: %v", l.configPath, err)
+	}
+}
+
+// flushPosition writes the position of the log watcher after the last processed log.
+func (l *logMonitor) flushPosition() {
+	if err := checkpoint.GlobalWatcherCheckpointManager.Flush(l.config.WatcherConfig.CheckpointKey); err != nil {
+		glog.Errorf("Failed to checkpoint log watcher position of %q: %v", l.configPath, err)
+	}
+}
+
+// initialConditions generates the initial conditions from the default conditions, which transit
+// at now. Conditions of the same type in restored are used instead of the defaults.
+func initialConditions(defaults []types.Condition, restored []types.Condition, now time.Time) []types.Condition {
 	conditions := make([]types.Condition, len(defaults))
 	copy(conditions, defaults)
 	for i := range conditions {
 		conditions[i].Status = types.False
-		conditions[i].Transition = time.Now()
+		conditions[i].Transition = now
+		for _, condition := range restored {
+			if condition.Type == conditions[i].Type {
+				conditions[i] = condition
+				break
+			}
+		}
 	}
 	return conditions
 }
 
+// conditionReason returns the reason of the condition of conditionType, or empty if it's not
+// found.
+func conditionReason(conditions []types.Condition, conditionType string) string {
+	for _, condition := range conditions {
+		if condition.Type == conditionType {
+			return condition.Reason
+		}
+	}
+	return ""
+}
+
 func generateMessage(logs []*logtypes.Log) string {
 	messages := []string{}
 	for _, log := range logs {
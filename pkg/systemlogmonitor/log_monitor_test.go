@@ -17,18 +17,25 @@ limitations under the License.
 package systemlogmonitor
 
 import (
+	"io/ioutil"
+	"os"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
 
+	"k8s.io/node-problem-detector/pkg/checkpoint"
 	"k8s.io/node-problem-detector/pkg/problemdaemon"
 	"k8s.io/node-problem-detector/pkg/problemmetrics"
+	watchertest "k8s.io/node-problem-detector/pkg/systemlogmonitor/logwatchers/testing"
+	watchertypes "k8s.io/node-problem-detector/pkg/systemlogmonitor/logwatchers/types"
 	logtypes "k8s.io/node-problem-detector/pkg/systemlogmonitor/types"
 	"k8s.io/node-problem-detector/pkg/types"
 	"k8s.io/node-problem-detector/pkg/util"
 	"k8s.io/node-problem-detector/pkg/util/metrics"
+	"k8s.io/node-problem-detector/pkg/util/tomb"
 )
 
 const (
@@ -69,6 +76,7 @@ func TestGenerateStatusForConditions(t *testing.T) {
 	}
 	for c, test := range []struct {
 		rule     logtypes.Rule
+		labels   map[string]string
 		expected types.Status
 	}{
 		// Do not need Pattern because we don't do pattern match in this test
@@ -120,8 +128,9 @@ func TestGenerateStatusForConditions(t *testing.T) {
 		},
 		{
 			rule: logtypes.Rule{
-				Type:   types.Temp,
-				Reason: "test reason",
+				Type:     types.Temp,
+				Severity: types.Warn,
+				Reason:   "test reason",
 			},
 			expected: types.Status{
 				Source: testSource,
@@ -134,23 +143,119 @@ func TestGenerateStatusForConditions(t *testing.T) {
 				Conditions: initConditions,
 			},
 		},
+		// Should report the labels captured with the problem.
+		{
+			rule: logtypes.Rule{
+				Type:      types.Perm,
+				Condition: testConditionB,
+				Reason:    "test reason",
+			},
+			labels: map[string]string{"device": "sda1"},
+			expected: types.Status{
+				Source: testSource,
+				Events: []types.Event{{
+					Severity:  types.Info,
+					Timestamp: time.Unix(1000, 1000),
+					Reason:    "test reason",
+					Message:   "Node condition TestConditionB is now: True, reason: test reason",
+					Labels:    map[string]string{"device": "sda1"},
+				}},
+				Conditions: []types.Condition{
+					initConditions[0],
+					{
+						Type:       testConditionB,
+						Status:     types.True,
+						Transition: time.Unix(1000, 1000),
+						Reason:     "test reason",
+						Message:    "test message 1\ntest message 2",
+						Labels:     map[string]string{"device": "sda1"},
+					},
+				},
+			},
+		},
+		// Should reset the condition to its default when a recovery rule is matched.
+		{
+			rule: logtypes.Rule{
+				Type:      logtypes.Recovery,
+				Condition: testConditionA,
+			},
+			expected: types.Status{
+				Source: testSource,
+				Events: []types.Event{util.GenerateConditionChangeEvent(
+					testConditionA,
+					types.False,
+					"default reason",
+					time.Unix(1000, 1000),
+				)},
+				Conditions: []types.Condition{
+					{
+						Type:       testConditionA,
+						Status:     types.False,
+						Transition: time.Unix(1000, 1000),
+						Reason:     "default reason",
+						Message:    "default message",
+					},
+					initConditions[1],
+				},
+			},
+		},
+		// Should not update the condition which is already reset when a recovery rule is matched.
+		{
+			rule: logtypes.Rule{
+				Type:      logtypes.Recovery,
+				Condition: testConditionB,
+			},
+			expected: types.Status{
+				Source:     testSource,
+				Conditions: initConditions,
+			},
+		},
 	} {
 		l := &logMonitor{
 			config: MonitorConfig{
 				Source: testSource,
+				DefaultConditions: []types.Condition{
+					{Type: testConditionA, Reason: "default reason", Message: "default message"},
+					{Type: testConditionB},
+				},
 			},
 			// Copy the init conditions to make sure it's not changed
 			// during the test.
 			conditions: append([]types.Condition{}, initConditions...),
 		}
 		(&l.config).ApplyDefaultConfiguration()
-		got := l.generateStatus(logs, test.rule)
+		got := l.generateStatus(&LogMatch{Logs: logs, Labels: test.labels}, test.rule)
 		if !reflect.DeepEqual(&test.expected, got) {
 			t.Errorf("case %d: expected status %+v, got %+v", c+1, test.expected, got)
 		}
 	}
 }
 
+func TestGenerateStatusWithTemplates(t *testing.T) {
+	buffer := NewLogBuffer(2)
+	buffer.Push(&logtypes.Log{Timestamp: time.Unix(1000, 1000), Message: "Kill process 1234 (java) score 1 or sacrifice child"})
+	buffer.Push(&logtypes.Log{Timestamp: time.Unix(1000, 2000), Message: "Killed process 1234 (java) total-vm:1kB"})
+	rule := logtypes.Rule{
+		Type:            types.Temp,
+		Severity:        types.Warn,
+		Reason:          "OOMKilling",
+		ReasonTemplate:  "OOMKilling${1}",
+		MessageTemplate: "OOM killed ${process} (pid ${pid})",
+		Pattern:         `Kill process (?P<pid>\d+) \((?P<process>.+)\).*\nKilled process \d+ \(.+\).*`,
+	}
+	l := &logMonitor{config: MonitorConfig{Source: testSource}}
+	(&l.config).ApplyDefaultConfiguration()
+
+	status := l.generateStatus(buffer.Find(rule.Pattern), rule)
+	assert.Equal(t, []types.Event{{
+		Severity:  types.Warn,
+		Timestamp: time.Unix(1000, 1000),
+		Reason:    "OOMKilling1234",
+		Message:   "OOM killed java (pid 1234)",
+		Labels:    map[string]string{"pid": "1234", "process": "java"},
+	}}, status.Events)
+}
+
 func TestGenerateStatusForMetrics(t *testing.T) {
 	testCases := []struct {
 		name            string
@@ -169,14 +274,15 @@ func TestGenerateStatusForMetrics(t *testing.T) {
 			conditions: []types.Condition{},
 			triggeredRules: []logtypes.Rule{
 				{
-					Type:   types.Temp,
-					Reason: "problem reason foo",
+					Type:     types.Temp,
+					Severity: types.Warn,
+					Reason:   "problem reason foo",
 				},
 			},
 			expectedMetrics: []metrics.Int64MetricRepresentation{
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason foo"},
+					Labels: map[string]string{"reason": "problem reason foo", "severity": "warn"},
 					Value:  1,
 				},
 			},
@@ -186,18 +292,20 @@ func TestGenerateStatusForMetrics(t *testing.T) {
 			conditions: []types.Condition{},
 			triggeredRules: []logtypes.Rule{
 				{
-					Type:   types.Temp,
-					Reason: "problem reason foo",
+					Type:     types.Temp,
+					Severity: types.Warn,
+					Reason:   "problem reason foo",
 				},
 				{
-					Type:   types.Temp,
-					Reason: "problem reason foo",
+					Type:     types.Temp,
+					Severity: types.Warn,
+					Reason:   "problem reason foo",
 				},
 			},
 			expectedMetrics: []metrics.Int64MetricRepresentation{
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason foo"},
+					Labels: map[string]string{"reason": "problem reason foo", "severity": "warn"},
 					Value:  2,
 				},
 			},
@@ -207,23 +315,25 @@ func TestGenerateStatusForMetrics(t *testing.T) {
 			conditions: []types.Condition{},
 			triggeredRules: []logtypes.Rule{
 				{
-					Type:   types.Temp,
-					Reason: "problem reason foo",
+					Type:     types.Temp,
+					Severity: types.Warn,
+					Reason:   "problem reason foo",
 				},
 				{
-					Type:   types.Temp,
-					Reason: "problem reason bar",
+					Type:     types.Temp,
+					Severity: types.Warn,
+					Reason:   "problem reason bar",
 				},
 			},
 			expectedMetrics: []metrics.Int64MetricRepresentation{
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason foo"},
+					Labels: map[string]string{"reason": "problem reason foo", "severity": "warn"},
 					Value:  1,
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason bar"},
+					Labels: map[string]string{"reason": "problem reason bar", "severity": "warn"},
 					Value:  1,
 				},
 			},
@@ -251,7 +361,7 @@ func TestGenerateStatusForMetrics(t *testing.T) {
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason foo"},
+					Labels: map[string]string{"reason": "problem reason foo", "severity": "info"},
 					Value:  1,
 				},
 			},
@@ -284,7 +394,7 @@ func TestGenerateStatusForMetrics(t *testing.T) {
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason foo"},
+					Labels: map[string]string{"reason": "problem reason foo", "severity": "info"},
 					Value:  1,
 				},
 			},
@@ -322,12 +432,12 @@ func TestGenerateStatusForMetrics(t *testing.T) {
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason foo"},
+					Labels: map[string]string{"reason": "problem reason foo", "severity": "info"},
 					Value:  1,
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason bar"},
+					Labels: map[string]string{"reason": "problem reason bar", "severity": "info"},
 					Value:  1,
 				},
 			},
@@ -369,12 +479,12 @@ func TestGenerateStatusForMetrics(t *testing.T) {
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason foo"},
+					Labels: map[string]string{"reason": "problem reason foo", "severity": "info"},
 					Value:  1,
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason bar"},
+					Labels: map[string]string{"reason": "problem reason bar", "severity": "info"},
 					Value:  1,
 				},
 			},
@@ -395,7 +505,7 @@ func TestGenerateStatusForMetrics(t *testing.T) {
 			problemmetrics.GlobalProblemMetricsManager = fakePMM
 
 			for _, rule := range test.triggeredRules {
-				l.generateStatus([]*logtypes.Log{{}}, rule)
+				l.generateStatus(&LogMatch{Logs: []*logtypes.Log{{}}}, rule)
 			}
 
 			gotMetrics := append(fakeProblemCounter.ListMetrics(), fakeProblemGauge.ListMetrics()...)
@@ -421,14 +531,15 @@ func TestInitializeProblemMetricsOrDie(t *testing.T) {
 			name: "one type of temporary problem",
 			rules: []logtypes.Rule{
 				{
-					Type:   types.Temp,
-					Reason: "problem reason foo",
+					Type:     types.Temp,
+					Severity: types.Warn,
+					Reason:   "problem reason foo",
 				},
 			},
 			expectedMetrics: []metrics.Int64MetricRepresentation{
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason foo"},
+					Labels: map[string]string{"reason": "problem reason foo", "severity": "warn"},
 					Value:  0,
 				},
 			},
@@ -450,7 +561,7 @@ func TestInitializeProblemMetricsOrDie(t *testing.T) {
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason foo"},
+					Labels: map[string]string{"reason": "problem reason foo", "severity": "info"},
 					Value:  0,
 				},
 			},
@@ -459,18 +570,20 @@ func TestInitializeProblemMetricsOrDie(t *testing.T) {
 			name: "duplicate temporary problem types",
 			rules: []logtypes.Rule{
 				{
-					Type:   types.Temp,
-					Reason: "problem reason foo",
+					Type:     types.Temp,
+					Severity: types.Warn,
+					Reason:   "problem reason foo",
 				},
 				{
-					Type:   types.Temp,
-					Reason: "problem reason foo",
+					Type:     types.Temp,
+					Severity: types.Warn,
+					Reason:   "problem reason foo",
 				},
 			},
 			expectedMetrics: []metrics.Int64MetricRepresentation{
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason foo"},
+					Labels: map[string]string{"reason": "problem reason foo", "severity": "warn"},
 					Value:  0,
 				},
 			},
@@ -479,23 +592,25 @@ func TestInitializeProblemMetricsOrDie(t *testing.T) {
 			name: "multiple temporary problem types",
 			rules: []logtypes.Rule{
 				{
-					Type:   types.Temp,
-					Reason: "problem reason foo",
+					Type:     types.Temp,
+					Severity: types.Warn,
+					Reason:   "problem reason foo",
 				},
 				{
-					Type:   types.Temp,
-					Reason: "problem reason bar",
+					Type:     types.Temp,
+					Severity: types.Warn,
+					Reason:   "problem reason bar",
 				},
 			},
 			expectedMetrics: []metrics.Int64MetricRepresentation{
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason foo"},
+					Labels: map[string]string{"reason": "problem reason foo", "severity": "warn"},
 					Value:  0,
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason bar"},
+					Labels: map[string]string{"reason": "problem reason bar", "severity": "warn"},
 					Value:  0,
 				},
 			},
@@ -527,12 +642,12 @@ func TestInitializeProblemMetricsOrDie(t *testing.T) {
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason foo"},
+					Labels: map[string]string{"reason": "problem reason foo", "severity": "info"},
 					Value:  0,
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason bar"},
+					Labels: map[string]string{"reason": "problem reason bar", "severity": "info"},
 					Value:  0,
 				},
 			},
@@ -564,12 +679,12 @@ func TestInitializeProblemMetricsOrDie(t *testing.T) {
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason foo"},
+					Labels: map[string]string{"reason": "problem reason foo", "severity": "info"},
 					Value:  0,
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason bar"},
+					Labels: map[string]string{"reason": "problem reason bar", "severity": "info"},
 					Value:  0,
 				},
 			},
@@ -596,7 +711,7 @@ func TestInitializeProblemMetricsOrDie(t *testing.T) {
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason foo"},
+					Labels: map[string]string{"reason": "problem reason foo", "severity": "info"},
 					Value:  0,
 				},
 			},
@@ -605,8 +720,9 @@ func TestInitializeProblemMetricsOrDie(t *testing.T) {
 			name: "mixture of temporary and permanent problem types",
 			rules: []logtypes.Rule{
 				{
-					Type:   types.Temp,
-					Reason: "problem reason foo",
+					Type:     types.Temp,
+					Severity: types.Warn,
+					Reason:   "problem reason foo",
 				},
 				{
 					Type:      types.Perm,
@@ -629,12 +745,14 @@ func TestInitializeProblemMetricsOrDie(t *testing.T) {
 					Reason:    "problem reason bar",
 				},
 				{
-					Type:   types.Temp,
-					Reason: "problem reason foo",
+					Type:     types.Temp,
+					Severity: types.Warn,
+					Reason:   "problem reason foo",
 				},
 				{
-					Type:   types.Temp,
-					Reason: "problem reason bar",
+					Type:     types.Temp,
+					Severity: types.Error,
+					Reason:   "problem reason bar",
 				},
 			},
 			expectedMetrics: []metrics.Int64MetricRepresentation{
@@ -660,17 +778,27 @@ func TestInitializeProblemMetricsOrDie(t *testing.T) {
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason hello"},
+					Labels: map[string]string{"reason": "problem reason hello", "severity": "info"},
+					Value:  0,
+				},
+				{
+					Name:   "problem_counter",
+					Labels: map[string]string{"reason": "problem reason foo", "severity": "info"},
+					Value:  0,
+				},
+				{
+					Name:   "problem_counter",
+					Labels: map[string]string{"reason": "problem reason foo", "severity": "warn"},
 					Value:  0,
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason foo"},
+					Labels: map[string]string{"reason": "problem reason bar", "severity": "info"},
 					Value:  0,
 				},
 				{
 					Name:   "problem_counter",
-					Labels: map[string]string{"reason": "problem reason bar"},
+					Labels: map[string]string{"reason": "problem reason bar", "severity": "error"},
 					Value:  0,
 				},
 			},
@@ -698,3 +826,270 @@ func TestInitializeProblemMetricsOrDie(t *testing.T) {
 		})
 	}
 }
+
+func TestInitialConditions(t *testing.T) {
+	defaults := []types.Condition{
+		{Type: testConditionA, Reason: "DefaultReasonA"},
+		{Type: testConditionB, Reason: "DefaultReasonB"},
+	}
+	restored := []types.Condition{
+		{Type: testConditionA, Status: types.True, Transition: time.Unix(500, 500), Reason: "RestoredReason"},
+		{Type: "RemovedCondition", Status: types.True},
+	}
+
+	now := time.Now()
+	conditions := initialConditions(defaults, restored, now)
+	assert.Equal(t, restored[0], conditions[0], "restored condition should be used")
+	assert.Equal(t, types.False, conditions[1].Status)
+	assert.Equal(t, now, conditions[1].Transition)
+	assert.Equal(t, "DefaultReasonB", conditions[1].Reason)
+	assert.Len(t, conditions, 2, "conditions which are no longer handled should not be restored")
+}
+
+func TestExitOnLogWatcherExit(t *testing.T) {
+	watcher := watchertest.NewFakeLogWatcher(1)
+	enableMetricsReporting := false
+	l := &logMonitor{
+		config:  MonitorConfig{EnableMetricsReporting: &enableMetricsReporting},
+		watcher: watcher,
+		buffer:  NewLogBuffer(1),
+		output:  make(chan *types.Status, 10),
+		tomb:    tomb.NewTomb(),
+		clock:   clock.RealClock{},
+	}
+	(&l.config).ApplyDefaultConfiguration()
+
+	ch, err := l.Start()
+	assert.NoError(t, err)
+	// Simulate log watcher exit by closing the log channel.
+	watcher.Stop()
+
+	statuses := 0
+	for range ch {
+		statuses++
+	}
+	assert.Equal(t, 1, statuses, "only the initial status should be reported before exit")
+}
+
+func TestConditionTTL(t *testing.T) {
+	start := time.Unix(1000, 0)
+	fakeClock := clock.NewFakeClock(start)
+	watcher := watchertest.NewFakeLogWatcher(0)
+	enableMetricsReporting := false
+	l := &logMonitor{
+		config: MonitorConfig{
+			Source: testSource,
+			DefaultConditions: []types.Condition{
+				{Type: testConditionA, Reason: "default reason", Message: "default message"},
+			},
+			Rules: []logtypes.Rule{
+				{Type: types.Perm, Condition: testConditionA, Reason: "problem reason", Pattern: "problem"},
+			},
+			EnableMetricsReporting: &enableMetricsReporting,
+			ConditionTTLs:          map[string]time.Duration{testConditionA: time.Hour},
+		},
+		watcher: watcher,
+		buffer:  NewLogBuffer(1),
+		output:  make(chan *types.Status, 10),
+		tomb:    tomb.NewTomb(),
+		clock:   fakeClock,
+	}
+	(&l.config).ApplyDefaultConfiguration()
+	matcher, err := NewMatcher(l.config.Rules, nil)
+	assert.NoError(t, err)
+	l.matcher = matcher
+
+	ch, err := l.Start()
+	assert.NoError(t, err)
+	defer l.Stop()
+	<-ch
+
+	watcher.InjectLog(&logtypes.Log{Timestamp: start, Message: "problem"})
+	status := <-ch
+	assert.Equal(t, types.True, status.Conditions[0].Status)
+
+	// The condition should not be reset before the TTL passes.
+	fakeClock.Step(time.Hour - time.Second)
+	select {
+	case status := <-ch:
+		t.Fatalf("unexpected status before the TTL passes: %+v", status)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	fakeClock.Step(conditionExpiryCheckInterval)
+	status = <-ch
+	assert.Equal(t, []types.Event{util.GenerateConditionChangeEvent(testConditionA, types.False, "default reason", fakeClock.Now())}, status.Events)
+	assert.Equal(t, []types.Condition{
+		{
+			Type:       testConditionA,
+			Status:     types.False,
+			Transition: fakeClock.Now(),
+			Reason:     "default reason",
+			Message:    "default message",
+		},
+	}, status.Conditions)
+}
+
+// heartbeatWatcher is a fake log watcher which reports a heartbeat.
+type heartbeatWatcher struct {
+	*watchertest.FakeLogWatcher
+	util.HeartbeatTracker
+}
+
+func TestWatcherHeartbeat(t *testing.T) {
+	start := time.Unix(1000, 0)
+	fakeClock := clock.NewFakeClock(start)
+	watcher := &heartbeatWatcher{FakeLogWatcher: watchertest.NewFakeLogWatcher(0)}
+	enableMetricsReporting := false
+	l := &logMonitor{
+		config: MonitorConfig{
+			Source:                 testSource,
+			EnableMetricsReporting: &enableMetricsReporting,
+		},
+		watcher: watcher,
+		buffer:  NewLogBuffer(1),
+		output:  make(chan *types.Status, 10),
+		tomb:    tomb.NewTomb(),
+		clock:   fakeClock,
+	}
+	(&l.config).ApplyDefaultConfiguration()
+	matcher, err := NewMatcher(l.config.Rules, nil)
+	assert.NoError(t, err)
+	l.matcher = matcher
+
+	ch, err := l.Start()
+	assert.NoError(t, err)
+	defer l.Stop()
+	<-ch
+	started := types.Heartbeat{Timestamp: start, Message: "Log monitor started"}
+	assert.Equal(t, started, l.Heartbeat())
+
+	// A wedged log watcher doesn't report heartbeats, so the log monitor becomes stale.
+	fakeClock.Step(time.Hour)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, started, l.Heartbeat(), "log monitor should not beat by itself")
+
+	// A log watcher waiting for a quiet log keeps the log monitor fresh.
+	watcher.Beat(fakeClock.Now(), "Waiting for log lines")
+	assert.Equal(t, types.Heartbeat{Timestamp: fakeClock.Now(), Message: "Waiting for log lines"}, l.Heartbeat())
+}
+
+func TestCountThreshold(t *testing.T) {
+	start := time.Unix(1000, 0)
+	fakeClock := clock.NewFakeClock(start)
+	watcher := watchertest.NewFakeLogWatcher(0)
+	enableMetricsReporting := false
+	l := &logMonitor{
+		config: MonitorConfig{
+			Source: testSource,
+			DefaultConditions: []types.Condition{
+				{Type: testConditionA, Reason: "default reason", Message: "default message"},
+			},
+			Rules: []logtypes.Rule{
+				{Type: types.Temp, Reason: "temporary reason", Pattern: "temporary problem", Count: 2, Window: time.Minute},
+				{Type: types.Perm, Condition: testConditionA, Reason: "permanent reason", Pattern: "permanent problem",
+					Count: 2, Window: time.Minute, ClearBelowThreshold: true},
+			},
+			EnableMetricsReporting: &enableMetricsReporting,
+		},
+		watcher: watcher,
+		buffer:  NewLogBuffer(1),
+		output:  make(chan *types.Status, 10),
+		tomb:    tomb.NewTomb(),
+		clock:   fakeClock,
+	}
+	(&l.config).ApplyDefaultConfiguration()
+	matcher, err := NewMatcher(l.config.Rules, nil)
+	assert.NoError(t, err)
+	l.matcher = matcher
+
+	ch, err := l.Start()
+	assert.NoError(t, err)
+	defer l.Stop()
+	<-ch
+
+	expectNoStatus := func(desc string) {
+		select {
+		case status := <-ch:
+			t.Fatalf("unexpected status %s: %+v", desc, status)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	// The temporary problem is reported once per count matches within the window.
+	watcher.InjectLog(&logtypes.Log{Timestamp: start, Message: "temporary problem"})
+	expectNoStatus("before the threshold is reached")
+	watcher.InjectLog(&logtypes.Log{Timestamp: start.Add(time.Second), Message: "temporary problem"})
+	status := <-ch
+	assert.Equal(t, "temporary reason", status.Events[0].Reason)
+	watcher.InjectLog(&logtypes.Log{Timestamp: start.Add(2 * time.Second), Message: "temporary problem"})
+	expectNoStatus("before the threshold is reached again")
+
+	// The matches out of the window are not counted.
+	watcher.InjectLog(&logtypes.Log{Timestamp: start, Message: "permanent problem"})
+	watcher.InjectLog(&logtypes.Log{Timestamp: start.Add(time.Minute), Message: "permanent problem"})
+	expectNoStatus("when the matches are out of the window")
+	watcher.InjectLog(&logtypes.Log{Timestamp: start.Add(time.Minute + time.Second), Message: "permanent problem"})
+	status = <-ch
+	assert.Equal(t, types.True, status.Conditions[0].Status)
+	assert.Equal(t, "permanent reason", status.Conditions[0].Reason)
+
+	// The condition is cleared once the matches fall below the threshold.
+	fakeClock.SetTime(start.Add(2 * time.Minute))
+	status = <-ch
+	assert.Equal(t, []types.Event{util.GenerateConditionChangeEvent(testConditionA, types.False, "default reason", fakeClock.Now())}, status.Events)
+	assert.Equal(t, types.False, status.Conditions[0].Status)
+}
+
+func TestCheckpointPosition(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	fakeClock := clock.NewFakeClock(time.Unix(1000, 0))
+	originalManager := checkpoint.GlobalWatcherCheckpointManager
+	defer func() {
+		checkpoint.GlobalWatcherCheckpointManager = originalManager
+	}()
+	checkpoint.GlobalWatcherCheckpointManager = checkpoint.NewWatcherCheckpointManager(dir, "boot", fakeClock)
+
+	type testPosition struct {
+		Offset int `json:"offset"`
+	}
+	loadPosition := func() testPosition {
+		var position testPosition
+		m := checkpoint.NewWatcherCheckpointManager(dir, "boot", fakeClock)
+		assert.True(t, m.Load("kernel-monitor.json", &position))
+		return position
+	}
+
+	// The fake log watcher is unbuffered, so that a log is injected only after the previous one
+	// is processed.
+	watcher := watchertest.NewFakeLogWatcher(0)
+	enableMetricsReporting := false
+	l := &logMonitor{
+		config: MonitorConfig{
+			WatcherConfig:          watchertypes.WatcherConfig{CheckpointKey: "kernel-monitor.json"},
+			EnableMetricsReporting: &enableMetricsReporting,
+		},
+		watcher: watcher,
+		buffer:  NewLogBuffer(1),
+		output:  make(chan *types.Status, 10),
+		tomb:    tomb.NewTomb(),
+		clock:   fakeClock,
+	}
+	(&l.config).ApplyDefaultConfiguration()
+	matcher, err := NewMatcher(nil, nil)
+	assert.NoError(t, err)
+	l.matcher = matcher
+
+	_, err = l.Start()
+	assert.NoError(t, err)
+	watcher.InjectLog(&logtypes.Log{Timestamp: time.Unix(1000, 0), Message: "1", Position: testPosition{Offset: 1}})
+	watcher.InjectLog(&logtypes.Log{Timestamp: time.Unix(1001, 0), Message: "2", Position: testPosition{Offset: 2}})
+	// The position of the second log is not written within the checkpoint interval.
+	assert.Equal(t, testPosition{Offset: 1}, loadPosition())
+
+	// The position after the last processed log is written when the log monitor stops.
+	l.Stop()
+	assert.Equal(t, testPosition{Offset: 2}, loadPosition())
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logparsers parses captured logs into the internal log type, so that they can be
+// replayed through log monitors. The messages are parsed the same way as the log watchers
+// deliver them to log monitors.
+package logparsers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	logtypes "k8s.io/node-problem-detector/pkg/systemlogmonitor/types"
+)
+
+// Format is the format of a captured log.
+type Format string
+
+const (
+	// Kmsg is the kernel log, either read from /dev/kmsg, or printed by dmesg. The timestamps are
+	// relative to the boot time.
+	Kmsg Format = "kmsg"
+	// Syslog is the syslog written by syslog daemons, with either the traditional timestamp,
+	// e.g. "Jan  2 15:04:05", or the RFC3339 timestamp.
+	Syslog Format = "syslog"
+	// Journald is the journal exported by `journalctl -o json`.
+	Journald Format = "journald"
+)
+
+// Formats are all supported log formats.
+var Formats = []Format{Kmsg, Syslog, Journald}
+
+// Options are the options to parse logs with.
+type Options struct {
+	// BootTime is the boot time, which kmsg timestamps are relative to.
+	BootTime time.Time
+	// Year is the year of syslog timestamps without year.
+	Year int
+	// SyslogIdentifier is the SYSLOG_IDENTIFIER of the journal entries to parse, the other
+	// entries are skipped like the journald log watcher does. All entries are parsed if it's
+	// empty.
+	SyslogIdentifier string
+}
+
+var (
+	// rawKmsgRegexp matches /dev/kmsg records, e.g. "6,1234,5678901,-;message".
+	rawKmsgRegexp = regexp.MustCompile(`^\d+,\d+,(\d+),[^;]*;(.*)$`)
+	// dmesgRegexp matches dmesg lines, e.g. "[ 5678.901234] message".
+	dmesgRegexp = regexp.MustCompile(`^\[\s*(\d+)\.(\d+)\]\s?(.*)$`)
+	// syslogRegexp matches traditional syslog lines, e.g. "Jan  2 15:04:05 host kernel: message".
+	syslogRegexp = regexp.MustCompile(`^([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}) \S+ [^:]+: (.*)$`)
+	// rfc3339SyslogRegexp matches syslog lines with RFC3339 timestamps, e.g.
+	// "2006-01-02T15:04:05.999999+07:00 host kernel: message".
+	rfc3339SyslogRegexp = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\S+) \S+ [^:]+: (.*)$`)
+	// kernelTimestampRegexp matches the kernel timestamp prefixed to kernel messages in syslog.
+	kernelTimestampRegexp = regexp.MustCompile(`^\[\s*\d+\.\d+\]\s?`)
+)
+
+// syslogTimestampFormat is the format of traditional syslog timestamps.
+const syslogTimestampFormat = "Jan _2 15:04:05"
+
+// Parse parses the captured log in the format. Empty lines are skipped, and an error is returned
+// with the line number for any line which can't be parsed.
+func Parse(r io.Reader, format Format, options Options) ([]*logtypes.Log, error) {
+	var parse func(string, Options) (*logtypes.Log, error)
+	switch format {
+	case Kmsg:
+		parse = parseKmsg
+	case Syslog:
+		parse = parseSyslog
+	case Journald:
+		parse = parseJournald
+	default:
+		return nil, fmt.Errorf("log format %q is not one of %v", format, Formats)
+	}
+
+	var logs []*logtypes.Log
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		log, err := parse(line, options)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		if log == nil || log.Message == "" {
+			continue
+		}
+		logs = append(logs, log)
+	}
+	return logs, scanner.Err()
+}
+
+func parseKmsg(line string, options Options) (*logtypes.Log, error) {
+	if matches := rawKmsgRegexp.FindStringSubmatch(line); matches != nil {
+		usec, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &logtypes.Log{
+			Timestamp: options.BootTime.Add(time.Duration(usec) * time.Microsecond),
+			Message:   strings.TrimSpace(matches[2]),
+		}, nil
+	}
+	if strings.HasPrefix(line, " ") && strings.Contains(line, "=") {
+		// Skip the dictionary of the previous /dev/kmsg record, e.g. " SUBSYSTEM=pci".
+		return nil, nil
+	}
+	if matches := dmesgRegexp.FindStringSubmatch(line); matches != nil {
+		sec, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		// The fraction is padded to microseconds by dmesg.
+		fraction, err := time.ParseDuration("0." + matches[2] + "s")
+		if err != nil {
+			return nil, err
+		}
+		return &logtypes.Log{
+			Timestamp: options.BootTime.Add(time.Duration(sec)*time.Second + fraction),
+			Message:   strings.TrimSpace(matches[3]),
+		}, nil
+	}
+	return nil, fmt.Errorf("unrecognized kmsg line %q", line)
+}
+
+func parseSyslog(line string, options Options) (*logtypes.Log, error) {
+	var timestamp time.Time
+	var message string
+	if matches := syslogRegexp.FindStringSubmatch(line); matches != nil {
+		t, err := time.ParseInLocation(syslogTimestampFormat, matches[1], time.Local)
+		if err != nil {
+			return nil, err
+		}
+		// Set the year instead of adding it to year 0, so that the offset of the local time zone
+		// is the one in that year.
+		timestamp = time.Date(options.Year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local)
+		message = matches[2]
+	} else if matches := rfc3339SyslogRegexp.FindStringSubmatch(line); matches != nil {
+		t, err := time.Parse(time.RFC3339Nano, matches[1])
+		if err != nil {
+			return nil, err
+		}
+		timestamp = t
+		message = matches[2]
+	} else {
+		return nil, fmt.Errorf("unrecognized syslog line %q", line)
+	}
+	// Strip the kernel timestamp, so that kernel messages are the same as read from /dev/kmsg.
+	message = kernelTimestampRegexp.ReplaceAllString(message, "")
+	return &logtypes.Log{
+		Timestamp: timestamp,
+		Message:   strings.TrimSpace(message),
+	}, nil
+}
+
+// journalEntry is an entry exported by `journalctl -o json`.
+type journalEntry struct {
+	RealtimeTimestamp string `json:"__REALTIME_TIMESTAMP"`
+	SyslogIdentifier  string `json:"SYSLOG_IDENTIFIER"`
+	// Message is either a string, or an array of bytes if it is not valid UTF-8.
+	Message interface{} `json:"MESSAGE"`
+}
+
+func parseJournald(line string, options Options) (*logtypes.Log, error) {
+	var entry journalEntry
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		return nil, err
+	}
+	if options.SyslogIdentifier != "" && entry.SyslogIdentifier != options.SyslogIdentifier {
+		return nil, nil
+	}
+	usec, err := strconv.ParseInt(entry.RealtimeTimestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid __REALTIME_TIMESTAMP %q: %v", entry.RealtimeTimestamp, err)
+	}
+	var message string
+	switch m := entry.Message.(type) {
+	case nil:
+	case string:
+		message = m
+	case []interface{}:
+		b := make([]byte, 0, len(m))
+		for _, c := range m {
+			n, ok := c.(float64)
+			if !ok {
+				return nil, fmt.Errorf("invalid MESSAGE %v", entry.Message)
+			}
+			b = append(b, byte(n))
+		}
+		message = string(b)
+	default:
+		return nil, fmt.Errorf("invalid MESSAGE %v", entry.Message)
+	}
+	return &logtypes.Log{
+		Timestamp: time.Unix(0, usec*int64(time.Microsecond)),
+		Message:   strings.TrimSpace(message),
+	}, nil
+}
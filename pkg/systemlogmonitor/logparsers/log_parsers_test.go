@@ -0,0 +1,138 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package logparsers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	logtypes "k8s.io/node-problem-detector/pkg/systemlogmonitor/types"
+)
+
+func TestParse(t *testing.T) {
+	bootTime := time.Date(2019, time.July, 1, 0, 0, 0, 0, time.UTC)
+	options := Options{BootTime: bootTime, Year: 2019}
+	for desc, test := range map[string]struct {
+		format   Format
+		options  *Options
+		log      string
+		expected []*logtypes.Log
+		isError  bool
+	}{
+		"raw kmsg": {
+			format: Kmsg,
+			log: `6,1001,5000000,-;INFO: task docker:20744 blocked for more than 120 seconds.
+ SUBSYSTEM=cpu
+
+3,1002,5500000,-;Kernel panic - not syncing: fatal error`,
+			expected: []*logtypes.Log{
+				{Timestamp: bootTime.Add(5 * time.Second), Message: "INFO: task docker:20744 blocked for more than 120 seconds."},
+				{Timestamp: bootTime.Add(5500 * time.Millisecond), Message: "Kernel panic - not syncing: fatal error"},
+			},
+		},
+		"dmesg": {
+			format: Kmsg,
+			log: `[    5.000000] INFO: task docker:20744 blocked for more than 120 seconds.
+[12345.500000] Kernel panic - not syncing: fatal error`,
+			expected: []*logtypes.Log{
+				{Timestamp: bootTime.Add(5 * time.Second), Message: "INFO: task docker:20744 blocked for more than 120 seconds."},
+				{Timestamp: bootTime.Add(12345500 * time.Millisecond), Message: "Kernel panic - not syncing: fatal error"},
+			},
+		},
+		"invalid kmsg": {
+			format:  Kmsg,
+			log:     "not a kernel log",
+			isError: true,
+		},
+		"syslog": {
+			format: Syslog,
+			log: `Jul  1 12:00:01 node-1 kernel: [   5.000000] INFO: task docker:20744 blocked for more than 120 seconds.
+2019-07-01T12:00:02.5Z node-1 dockerd[1234]: panic: runtime error`,
+			expected: []*logtypes.Log{
+				{
+					Timestamp: time.Date(2019, time.July, 1, 12, 0, 1, 0, time.Local),
+					Message:   "INFO: task docker:20744 blocked for more than 120 seconds.",
+				},
+				{
+					Timestamp: time.Date(2019, time.July, 1, 12, 0, 2, 500000000, time.UTC),
+					Message:   "panic: runtime error",
+				},
+			},
+		},
+		"syslog on leap day": {
+			format:  Syslog,
+			options: &Options{Year: 2020},
+			log:     `Feb 29 12:00:01 node-1 kernel: Kernel panic - not syncing: fatal error`,
+			expected: []*logtypes.Log{
+				{Timestamp: time.Date(2020, time.February, 29, 12, 0, 1, 0, time.Local), Message: "Kernel panic - not syncing: fatal error"},
+			},
+		},
+		"invalid syslog": {
+			format:  Syslog,
+			log:     "Jul  1 12:00:01 node-1",
+			isError: true,
+		},
+		"journald": {
+			format: Journald,
+			log: `{"__REALTIME_TIMESTAMP": "1561939200000000", "MESSAGE": "Started Docker Application Container Engine. "}
+{"__REALTIME_TIMESTAMP": "1561939201500000", "MESSAGE": [104, 105]}`,
+			expected: []*logtypes.Log{
+				{Timestamp: time.Unix(1561939200, 0), Message: "Started Docker Application Container Engine."},
+				{Timestamp: time.Unix(1561939201, 500000000), Message: "hi"},
+			},
+		},
+		"journald with syslog identifier": {
+			format:  Journald,
+			options: &Options{SyslogIdentifier: "kernel"},
+			log: `{"__REALTIME_TIMESTAMP": "1561939200000000", "SYSLOG_IDENTIFIER": "dockerd", "MESSAGE": "panic: runtime error"}
+{"__REALTIME_TIMESTAMP": "1561939201000000", "SYSLOG_IDENTIFIER": "kernel", "MESSAGE": "Kernel panic - not syncing: fatal error"}`,
+			expected: []*logtypes.Log{
+				{Timestamp: time.Unix(1561939201, 0), Message: "Kernel panic - not syncing: fatal error"},
+			},
+		},
+		"invalid journald": {
+			format:  Journald,
+			log:     `{"MESSAGE": "no timestamp"}`,
+			isError: true,
+		},
+		"unknown format": {
+			format:  "foo",
+			isError: true,
+		},
+	} {
+		testOptions := options
+		if test.options != nil {
+			testOptions = *test.options
+		}
+		logs, err := Parse(strings.NewReader(test.log), test.format, testOptions)
+		if test.isError {
+			assert.Error(t, err, desc)
+			continue
+		}
+		assert.NoError(t, err, desc)
+		if assert.Len(t, logs, len(test.expected), desc) {
+			for i := range logs {
+				assert.True(t, test.expected[i].Timestamp.Equal(logs[i].Timestamp), "%s: expected timestamp %v, got %v",
+					desc, test.expected[i].Timestamp, logs[i].Timestamp)
+				assert.Equal(t, test.expected[i].Message, logs[i].Message, desc)
+			}
+		}
+	}
+}
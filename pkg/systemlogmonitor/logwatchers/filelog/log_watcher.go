@@ -23,14 +23,16 @@ import (
 	"io"
 	"os"
 	"strings"
+	"syscall"
 	"time"
 
 	utilclock "code.cloudfoundry.org/clock"
 	"github.com/golang/glog"
-	"github.com/google/cadvisor/utils/tail"
 
+	"k8s.io/node-problem-detector/pkg/checkpoint"
 	"k8s.io/node-problem-detector/pkg/systemlogmonitor/logwatchers/types"
 	logtypes "k8s.io/node-problem-detector/pkg/systemlogmonitor/types"
+	pkgtypes "k8s.io/node-problem-detector/pkg/types"
 	"k8s.io/node-problem-detector/pkg/util"
 	"k8s.io/node-problem-detector/pkg/util/tomb"
 )
@@ -38,12 +40,30 @@ import (
 type filelogWatcher struct {
 	cfg        types.WatcherConfig
 	reader     *bufio.Reader
-	closer     io.Closer
+	file       *os.File
 	translator *translator
 	logCh      chan *logtypes.Log
 	startTime  time.Time
-	tomb       *tomb.Tomb
-	clock      utilclock.Clock
+	// position is the position after the last line read from the log file. It starts from the
+	// checkpointed position if resumed is true.
+	position filelogPosition
+	resumed  bool
+	// lastRead are the last bytes read from the log file, which should still be right before the
+	// read position unless the log file is truncated.
+	lastRead []byte
+	tomb     *tomb.Tomb
+	clock    utilclock.Clock
+	// heartbeat is updated whenever the log file is read successfully, which is at least once per
+	// watchPollInterval while waiting for new lines.
+	heartbeat util.HeartbeatTracker
+}
+
+// filelogPosition is the checkpointed position of filelog watcher.
+type filelogPosition struct {
+	// Inode is the inode of the log file.
+	Inode uint64 `json:"inode"`
+	// Offset is the offset of the end of the last line read from the log file.
+	Offset int64 `json:"offset"`
 }
 
 // NewSyslogWatcherOrDie creates a new log watcher. The function panics
@@ -74,12 +94,34 @@ var _ types.WatcherCreateFunc = NewSyslogWatcherOrDie
 
 // Watch starts the filelog watcher.
 func (s *filelogWatcher) Watch() (<-chan *logtypes.Log, error) {
-	r, err := getLogReader(s.cfg.LogPath)
+	f, err := openLogFile(s.cfg.LogPath)
 	if err != nil {
 		return nil, err
 	}
-	s.reader = bufio.NewReader(r)
-	s.closer = r
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to stat the file %q: %v", s.cfg.LogPath, err)
+	}
+	s.position = filelogPosition{Inode: inode(info)}
+	// Resume after the last line processed before restart, instead of looking back, if the log
+	// file is not rotated or truncated since then.
+	var position filelogPosition
+	if checkpoint.GlobalWatcherCheckpointManager.Load(s.cfg.CheckpointKey, &position) {
+		if position.Inode == s.position.Inode && position.Offset <= info.Size() {
+			if _, err := f.Seek(position.Offset, io.SeekStart); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("failed to seek the file %q to %d: %v", s.cfg.LogPath, position.Offset, err)
+			}
+			s.position = position
+			s.resumed = true
+		} else {
+			glog.Warningf("Checkpointed position %+v is not found in the file %q (inode %d, size %d), which is rotated or truncated since then, "+
+				"looking back from start time %v instead, some logs may be lost", position, s.cfg.LogPath, s.position.Inode, info.Size(), s.startTime)
+		}
+	}
+	s.file = f
+	s.reader = bufio.NewReader(f)
 	glog.Info("Start watching filelog")
 	go s.watchLoop()
 	return s.logCh, nil
@@ -90,14 +132,23 @@ func (s *filelogWatcher) Stop() {
 	s.tomb.Stop()
 }
 
+// Heartbeat returns the time when the log file is last read successfully.
+func (s *filelogWatcher) Heartbeat() pkgtypes.Heartbeat {
+	return s.heartbeat.Heartbeat()
+}
+
 // watchPollInterval is the interval filelog log watcher will
 // poll for pod change after reading to the end.
 const watchPollInterval = 500 * time.Millisecond
 
+// lastReadSize is the number of the last bytes read from the log file, which are checked to
+// notice the log file being truncated and growing past the read position again.
+const lastReadSize = 64
+
 // watchLoop is the main watch loop of filelog watcher.
 func (s *filelogWatcher) watchLoop() {
 	defer func() {
-		s.closer.Close()
+		s.file.Close()
 		close(s.logCh)
 		s.tomb.Done()
 	}()
@@ -110,51 +161,145 @@ func (s *filelogWatcher) watchLoop() {
 		default:
 		}
 
+		if s.reader.Buffered() == 0 {
+			// Check the log file before each read from it, so that the new content of a
+			// truncated log file is not read from the previous read position.
+			if err := s.checkLogFile(&buffer, false); err != nil {
+				glog.Errorf("Exiting filelog watch with error: %v", err)
+				return
+			}
+		}
 		line, err := s.reader.ReadString('\n')
 		if err != nil && err != io.EOF {
 			glog.Errorf("Exiting filelog watch with error: %v", err)
 			return
 		}
+		s.heartbeat.Beat(s.clock.Now(), "Read log file")
 		buffer.WriteString(line)
+		s.recordRead(line)
 		if err == io.EOF {
+			if err := s.checkLogFile(&buffer, true); err != nil {
+				glog.Errorf("Exiting filelog watch with error: %v", err)
+				return
+			}
 			time.Sleep(watchPollInterval)
 			continue
 		}
 		line = buffer.String()
 		buffer.Reset()
-		log, err := s.translator.translate(strings.TrimSuffix(line, "\n"))
-		if err != nil {
-			glog.Warningf("Unable to parse line: %q, %v", line, err)
-			continue
+		s.position.Offset += int64(len(line))
+		s.parseLine(line)
+	}
+}
+
+// parseLine parses the line and sends the log.
+func (s *filelogWatcher) parseLine(line string) {
+	log, err := s.translator.translate(strings.TrimSuffix(line, "\n"))
+	if err != nil {
+		glog.Warningf("Unable to parse line: %q, %v", line, err)
+		return
+	}
+	// Discard messages before start time, unless resumed from the checkpoint.
+	if !s.resumed && log.Timestamp.Before(s.startTime) {
+		glog.V(5).Infof("Throwing away msg %q before start time: %v < %v", log.Message, log.Timestamp, s.startTime)
+		return
+	}
+	if s.cfg.CheckpointKey != "" {
+		log.Position = s.position
+	}
+	s.logCh <- log
+}
+
+// recordRead records the last bytes read from the log file.
+func (s *filelogWatcher) recordRead(data string) {
+	if len(data) >= lastReadSize {
+		s.lastRead = append(s.lastRead[:0], data[len(data)-lastReadSize:]...)
+		return
+	}
+	s.lastRead = append(s.lastRead, data...)
+	if n := len(s.lastRead) - lastReadSize; n > 0 {
+		s.lastRead = append(s.lastRead[:0], s.lastRead[n:]...)
+	}
+}
+
+// checkLogFile reads the log file from the beginning if it's truncated, and reopens the log file
+// if it's rotated and the current one is read to the end, i.e. eof is true. The incomplete last
+// line in the buffer is dropped in both cases.
+func (s *filelogWatcher) checkLogFile(buffer *bytes.Buffer, eof bool) error {
+	info, err := os.Stat(s.cfg.LogPath)
+	if err != nil {
+		// The log file may be missing during rotation, keep reading the current one.
+		glog.V(5).Infof("Failed to stat the file %q: %v", s.cfg.LogPath, err)
+		return nil
+	}
+	current, err := s.file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat the file %q: %v", s.cfg.LogPath, err)
+	}
+	if os.SameFile(info, current) {
+		if !s.truncated(current.Size(), s.position.Offset+int64(buffer.Len())) {
+			return nil
 		}
-		// Discard messages before start time.
-		if log.Timestamp.Before(s.startTime) {
-			glog.V(5).Infof("Throwing away msg %q before start time: %v < %v", log.Message, log.Timestamp, s.startTime)
-			continue
+		glog.Infof("Log file %q is truncated, read it from the beginning", s.cfg.LogPath)
+		if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to seek the file %q: %v", s.cfg.LogPath, err)
+		}
+	} else {
+		if !eof {
+			// Keep reading the rotated log file to the end.
+			return nil
 		}
-		s.logCh <- log
+		glog.Infof("Log file %q is rotated, read the new one", s.cfg.LogPath)
+		f, err := openLogFile(s.cfg.LogPath)
+		if err != nil {
+			return err
+		}
+		s.file.Close()
+		s.file = f
+	}
+	s.reader.Reset(s.file)
+	s.position = filelogPosition{Inode: inode(info)}
+	s.lastRead = s.lastRead[:0]
+	buffer.Reset()
+	return nil
+}
+
+// truncated returns true if the log file of the size is truncated since it's read to the read
+// position, either because it's shorter, or the last bytes read are no longer right before the
+// read position.
+func (s *filelogWatcher) truncated(size, readPosition int64) bool {
+	if size < readPosition {
+		return true
 	}
+	if len(s.lastRead) == 0 {
+		return false
+	}
+	data := make([]byte, len(s.lastRead))
+	if _, err := s.file.ReadAt(data, readPosition-int64(len(data))); err != nil {
+		glog.V(5).Infof("Failed to read the file %q: %v", s.cfg.LogPath, err)
+		return false
+	}
+	return !bytes.Equal(data, s.lastRead)
 }
 
-// getLogReader returns log reader for filelog log. Note that getLogReader doesn't look back
-// to the rolled out logs.
-func getLogReader(path string) (io.ReadCloser, error) {
+// openLogFile opens the filelog log. Log rotation and truncation are handled by the watch loop,
+// which checks the log file before each read from it. Note that the rolled out logs are not
+// looked back.
+func openLogFile(path string) (*os.File, error) {
 	if path == "" {
 		return nil, fmt.Errorf("unexpected empty log path")
 	}
-	// To handle log rotation, tail will not report error immediately if
-	// the file doesn't exist. So we check file existence first.
-	// This could go wrong during mid-rotation. It should recover after
-	// several restart when the log file is created again. The chance
-	// is slim but we should still fix this in the future.
-	// TODO(random-liu): Handle log missing during rotation.
-	_, err := os.Stat(path)
+	f, err := os.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to stat the file %q: %v", path, err)
+		return nil, fmt.Errorf("failed to open the file %q: %v", path, err)
 	}
-	tail, err := tail.NewTail(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to tail the file %q: %v", path, err)
+	return f, nil
+}
+
+// inode returns the inode of the file.
+func inode(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
 	}
-	return tail, nil
+	return 0
 }
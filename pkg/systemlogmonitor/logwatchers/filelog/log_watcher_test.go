@@ -19,15 +19,18 @@ package filelog
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"k8s.io/node-problem-detector/pkg/checkpoint"
 	"k8s.io/node-problem-detector/pkg/systemlogmonitor/logwatchers/types"
 	logtypes "k8s.io/node-problem-detector/pkg/systemlogmonitor/types"
 	"k8s.io/node-problem-detector/pkg/util"
 
 	"code.cloudfoundry.org/clock/fakeclock"
 	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
 )
 
 // getTestPluginConfig returns a plugin config for test. Use configuration for
@@ -179,3 +182,105 @@ Jan  2 03:04:05 kernel: [2.000000] 3
 		}
 	}
 }
+
+func TestWatchResumeAndRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_watcher_test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	originalManager := checkpoint.GlobalWatcherCheckpointManager
+	defer func() {
+		checkpoint.GlobalWatcherCheckpointManager = originalManager
+	}()
+	checkpoint.GlobalWatcherCheckpointManager = checkpoint.NewWatcherCheckpointManager(dir, "boot", clock.RealClock{})
+
+	now := time.Date(time.Now().Year(), time.January, 2, 3, 4, 5, 0, time.Local)
+	path := filepath.Join(dir, "kern.log")
+	line := "Jan  2 03:04:01 kernel: [0.000000] 1\n"
+	log := line + `Jan  2 03:04:02 kernel: [1.000000] 2
+Jan  2 03:04:03 kernel: [2.000000] 3
+`
+	assert.NoError(t, ioutil.WriteFile(path, []byte(log), 0644))
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	// Checkpoint the position after the first line.
+	assert.NoError(t, checkpoint.GlobalWatcherCheckpointManager.Save("kernel-monitor.json",
+		filelogPosition{Inode: inode(info), Offset: int64(len(line))}))
+
+	w := NewSyslogWatcherOrDie(types.WatcherConfig{
+		Plugin:        "filelog",
+		PluginConfig:  getTestPluginConfig(),
+		LogPath:       path,
+		CheckpointKey: "kernel-monitor.json",
+	})
+	// All the logs are before start time, only the ones after the checkpoint are sent.
+	w.(*filelogWatcher).startTime = now
+	logCh, err := w.Watch()
+	assert.NoError(t, err)
+	expectLogs := func(logs []logtypes.Log) {
+		for _, expected := range logs {
+			select {
+			case got := <-logCh:
+				assert.Equal(t, &expected, got)
+			case <-time.After(30 * time.Second):
+				t.Errorf("timeout waiting for log")
+			}
+		}
+	}
+	// The logs carry their positions, which are checkpointed once they are processed.
+	expectLogs([]logtypes.Log{
+		{Timestamp: now.Add(-3 * time.Second), Message: "2", Position: filelogPosition{Inode: inode(info), Offset: int64(2 * len(line))}},
+		{Timestamp: now.Add(-2 * time.Second), Message: "3", Position: filelogPosition{Inode: inode(info), Offset: int64(len(log))}},
+	})
+
+	// Rotate the log file, the new one should be read from the beginning.
+	assert.NoError(t, os.Rename(path, path+".1"))
+	rotated := "Jan  2 03:04:06 kernel: [5.000000] 4\n"
+	assert.NoError(t, ioutil.WriteFile(path, []byte(rotated), 0644))
+	info, err = os.Stat(path)
+	assert.NoError(t, err)
+	expectLogs([]logtypes.Log{
+		{Timestamp: now.Add(time.Second), Message: "4", Position: filelogPosition{Inode: inode(info), Offset: int64(len(rotated))}},
+	})
+	w.Stop()
+}
+
+func TestWatchTruncateAndGrow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "log_watcher_test")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Date(time.Now().Year(), time.January, 2, 3, 4, 5, 0, time.Local)
+	path := filepath.Join(dir, "kern.log")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("Jan  2 03:04:05 kernel: [0.000000] 1\n"), 0644))
+
+	w := NewSyslogWatcherOrDie(types.WatcherConfig{
+		Plugin:       "filelog",
+		PluginConfig: getTestPluginConfig(),
+		LogPath:      path,
+	})
+	w.(*filelogWatcher).startTime = now
+	logCh, err := w.Watch()
+	assert.NoError(t, err)
+	defer w.Stop()
+	expectLogs := func(logs []logtypes.Log) {
+		for _, expected := range logs {
+			select {
+			case got := <-logCh:
+				assert.Equal(t, &expected, got)
+			case <-time.After(30 * time.Second):
+				t.Errorf("timeout waiting for log")
+			}
+		}
+	}
+	expectLogs([]logtypes.Log{{Timestamp: now, Message: "1"}})
+
+	// Truncate the log file and grow it past the read position before it's read again, the new
+	// content should be read from the beginning.
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`Jan  2 03:04:06 kernel: [1.000000] 2
+Jan  2 03:04:07 kernel: [2.000000] 3
+`), 0644))
+	expectLogs([]logtypes.Log{
+		{Timestamp: now.Add(time.Second), Message: "2"},
+		{Timestamp: now.Add(2 * time.Second), Message: "3"},
+	})
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package journald
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+
+	"k8s.io/node-problem-detector/pkg/checkpoint"
+)
+
+// This file doesn't depend on go-systemd/sdjournal, so that it's built and tested without the
+// journald build tag.
+
+// journaldPosition is the checkpointed position of journald watcher.
+type journaldPosition struct {
+	// Cursor is the cursor of the journal entry.
+	Cursor string `json:"cursor"`
+}
+
+// entryFilter discards the journal entries which shouldn't be sent. When journald watcher
+// resumes from the checkpointed cursor, the journal is seeked to the cursor, and only the entry
+// at the cursor is discarded because it's already processed before restart. Otherwise the
+// entries before the start time are discarded.
+type entryFilter struct {
+	// cursor is the checkpointed cursor to resume after, or empty if there is none.
+	cursor         string
+	startTimestamp uint64
+	// checked is true once the first entry is checked against the checkpointed cursor.
+	checked bool
+}
+
+// newEntryFilter creates the entry filter of journald watcher, which resumes after the position
+// checkpointed under key if there is one in the current boot.
+func newEntryFilter(key string, startTime time.Time) *entryFilter {
+	filter := &entryFilter{startTimestamp: timeToJournalTimestamp(startTime)}
+	var position journaldPosition
+	if checkpoint.GlobalWatcherCheckpointManager.Load(key, &position) {
+		filter.cursor = position.Cursor
+	}
+	return filter
+}
+
+// lookBack discards the checkpointed cursor, so that the entries before the start time are
+// discarded instead. It's used when the journal can't be seeked to the checkpointed cursor.
+func (f *entryFilter) lookBack(err error) {
+	glog.Warningf("Failed to seek journal to checkpointed cursor %q, looking back from start time instead, some logs may be lost: %v", f.cursor, err)
+	f.cursor = ""
+}
+
+// discardReason returns why the journal entry at cursor logged at realtimeTimestamp should be
+// discarded, or empty if it should be sent.
+func (f *entryFilter) discardReason(cursor string, realtimeTimestamp uint64) string {
+	if f.cursor != "" {
+		if !f.checked {
+			f.checked = true
+			if cursor != f.cursor {
+				// The journal is seeked to the entry after the checkpointed cursor, because
+				// the entry at the cursor is removed, e.g. by journal vacuum.
+				glog.Warningf("Journal entry at checkpointed cursor %q is not found, resuming from %q instead, some logs may be lost", f.cursor, cursor)
+			}
+		}
+		if cursor == f.cursor {
+			return "at checkpoint cursor"
+		}
+		return ""
+	}
+	if realtimeTimestamp < f.startTimestamp {
+		return fmt.Sprintf("before start time: %v < %v", realtimeTimestamp, f.startTimestamp)
+	}
+	return ""
+}
+
+func timeToJournalTimestamp(t time.Time) uint64 {
+	return uint64(t.UnixNano() / 1000)
+}
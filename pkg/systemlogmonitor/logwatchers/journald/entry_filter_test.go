@@ -0,0 +1,75 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package journald
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"k8s.io/node-problem-detector/pkg/checkpoint"
+)
+
+func TestEntryFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	originalManager := checkpoint.GlobalWatcherCheckpointManager
+	defer func() {
+		checkpoint.GlobalWatcherCheckpointManager = originalManager
+	}()
+	checkpoint.GlobalWatcherCheckpointManager = checkpoint.NewWatcherCheckpointManager(dir, "boot", clock.RealClock{})
+
+	startTime := time.Unix(1000, 0)
+	startTimestamp := timeToJournalTimestamp(startTime)
+
+	// Without checkpoint, the entries before the start time are discarded.
+	f := newEntryFilter("docker-monitor.json", startTime)
+	assert.Equal(t, "", f.cursor)
+	assert.Equal(t, "before start time: 999999999 < 1000000000", f.discardReason("s=1;i=1", startTimestamp-1))
+	assert.Equal(t, "", f.discardReason("s=1;i=2", startTimestamp))
+
+	// With checkpoint, only the entry at the checkpointed cursor is discarded.
+	assert.NoError(t, checkpoint.GlobalWatcherCheckpointManager.Save("docker-monitor.json", journaldPosition{Cursor: "s=1;i=1"}))
+	f = newEntryFilter("docker-monitor.json", startTime)
+	assert.Equal(t, "s=1;i=1", f.cursor)
+	assert.Equal(t, "at checkpoint cursor", f.discardReason("s=1;i=1", startTimestamp-1))
+	assert.Equal(t, "", f.discardReason("s=1;i=2", startTimestamp-1))
+
+	// The entries after the checkpointed cursor are still sent if the entry at the cursor is not
+	// found, e.g. removed by journal vacuum.
+	f = newEntryFilter("docker-monitor.json", startTime)
+	assert.Equal(t, "", f.discardReason("s=1;i=3", startTimestamp-1))
+	assert.True(t, f.checked)
+
+	// The entries before the start time are discarded if the journal can't be seeked to the
+	// checkpointed cursor.
+	f = newEntryFilter("docker-monitor.json", startTime)
+	f.lookBack(fmt.Errorf("injected error"))
+	assert.Equal(t, "", f.cursor)
+	assert.Equal(t, "before start time: 999999999 < 1000000000", f.discardReason("s=1;i=3", startTimestamp-1))
+
+	// The checkpoint of another boot is ignored.
+	checkpoint.GlobalWatcherCheckpointManager = checkpoint.NewWatcherCheckpointManager(dir, "another-boot", clock.RealClock{})
+	f = newEntryFilter("docker-monitor.json", startTime)
+	assert.Equal(t, "", f.cursor)
+}
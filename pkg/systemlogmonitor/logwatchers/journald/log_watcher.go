@@ -29,6 +29,7 @@ import (
 
 	"k8s.io/node-problem-detector/pkg/systemlogmonitor/logwatchers/types"
 	logtypes "k8s.io/node-problem-detector/pkg/systemlogmonitor/types"
+	pkgtypes "k8s.io/node-problem-detector/pkg/types"
 	"k8s.io/node-problem-detector/pkg/util"
 	"k8s.io/node-problem-detector/pkg/util/tomb"
 )
@@ -43,8 +44,12 @@ type journaldWatcher struct {
 	journal   *sdjournal.Journal
 	cfg       types.WatcherConfig
 	startTime time.Time
+	filter    *entryFilter
 	logCh     chan *logtypes.Log
 	tomb      *tomb.Tomb
+	// heartbeat is updated whenever a journal entry is read, or the journal is waited for new
+	// entries, but not when reading the journal fails.
+	heartbeat util.HeartbeatTracker
 }
 
 // NewJournaldWatcher is the create function of journald watcher.
@@ -72,7 +77,9 @@ var _ types.WatcherCreateFunc = NewJournaldWatcher
 
 // Watch starts the journal watcher.
 func (j *journaldWatcher) Watch() (<-chan *logtypes.Log, error) {
-	journal, err := getJournal(j.cfg, j.startTime)
+	// Resume after the last journal entry processed before restart, instead of looking back.
+	j.filter = newEntryFilter(j.cfg.CheckpointKey, j.startTime)
+	journal, err := getJournal(j.cfg, j.startTime, j.filter)
 	if err != nil {
 		return nil, err
 	}
@@ -87,12 +94,16 @@ func (j *journaldWatcher) Stop() {
 	j.tomb.Stop()
 }
 
+// Heartbeat returns the time when the journal is last read successfully.
+func (j *journaldWatcher) Heartbeat() pkgtypes.Heartbeat {
+	return j.heartbeat.Heartbeat()
+}
+
 // waitLogTimeout is the timeout waiting for new log.
 const waitLogTimeout = 5 * time.Second
 
 // watchLoop is the main watch loop of journald watcher.
 func (j *journaldWatcher) watchLoop() {
-	startTimestamp := timeToJournalTimestamp(j.startTime)
 	defer func() {
 		if err := j.journal.Close(); err != nil {
 			glog.Errorf("Failed to close journal client: %v", err)
@@ -115,6 +126,7 @@ func (j *journaldWatcher) watchLoop() {
 		// If next reaches the end, wait for waitLogTimeout.
 		if n == 0 {
 			j.journal.Wait(waitLogTimeout)
+			j.heartbeat.Beat(time.Now(), "Waited for journal entries")
 			continue
 		}
 
@@ -123,14 +135,19 @@ func (j *journaldWatcher) watchLoop() {
 			glog.Errorf("failed to get journal entry: %v", err)
 			continue
 		}
+		j.heartbeat.Beat(time.Now(), "Read journal entry")
 
-		if entry.RealtimeTimestamp < startTimestamp {
-			glog.V(5).Infof("Throwing away journal entry %q before start time: %v < %v",
-				entry.Fields[sdjournal.SD_JOURNAL_FIELD_MESSAGE], entry.RealtimeTimestamp, startTimestamp)
+		if reason := j.filter.discardReason(entry.Cursor, entry.RealtimeTimestamp); reason != "" {
+			glog.V(5).Infof("Throwing away journal entry %q %s",
+				entry.Fields[sdjournal.SD_JOURNAL_FIELD_MESSAGE], reason)
 			continue
 		}
 
-		j.logCh <- translate(entry)
+		log := translate(entry)
+		if j.cfg.CheckpointKey != "" {
+			log.Position = journaldPosition{Cursor: entry.Cursor}
+		}
+		j.logCh <- log
 	}
 }
 
@@ -142,8 +159,9 @@ const (
 	configSourceKey = "source"
 )
 
-// getJournal returns a journal client.
-func getJournal(cfg types.WatcherConfig, startTime time.Time) (*sdjournal.Journal, error) {
+// getJournal returns a journal client. The journal client is seeked to the checkpointed cursor
+// of the filter if there is one, or else to the start time.
+func getJournal(cfg types.WatcherConfig, startTime time.Time, filter *entryFilter) (*sdjournal.Journal, error) {
 	// Get journal log path.
 	path := defaultJournalLogPath
 	if cfg.LogPath != "" {
@@ -159,15 +177,23 @@ func getJournal(cfg types.WatcherConfig, startTime time.Time) (*sdjournal.Journa
 	if err != nil {
 		return nil, fmt.Errorf("failed to create journal client from path %q: %v", path, err)
 	}
-	// Seek journal client based on startTime.
-	seekTime := startTime
-	now := time.Now()
-	if now.Before(seekTime) {
-		seekTime = now
+	if filter.cursor != "" {
+		// Seek journal client to the checkpointed cursor.
+		if err := journal.SeekCursor(filter.cursor); err != nil {
+			filter.lookBack(err)
+		}
 	}
-	err = journal.SeekRealtimeUsec(timeToJournalTimestamp(seekTime))
-	if err != nil {
-		return nil, fmt.Errorf("failed to seek journal at %v (now %v): %v", seekTime, now, err)
+	if filter.cursor == "" {
+		// Seek journal client based on startTime.
+		seekTime := startTime
+		now := time.Now()
+		if now.Before(seekTime) {
+			seekTime = now
+		}
+		err = journal.SeekRealtimeUsec(timeToJournalTimestamp(seekTime))
+		if err != nil {
+			return nil, fmt.Errorf("failed to seek journal at %v (now %v): %v", seekTime, now, err)
+		}
 	}
 	// Empty source is not allowed and treated as an error.
 	source := cfg.PluginConfig[configSourceKey]
@@ -194,7 +220,3 @@ func translate(entry *sdjournal.JournalEntry) *logtypes.Log {
 		Message:   message,
 	}
 }
-
-func timeToJournalTimestamp(t time.Time) uint64 {
-	return uint64(t.UnixNano() / 1000)
-}
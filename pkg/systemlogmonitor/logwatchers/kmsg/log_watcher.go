@@ -25,8 +25,10 @@ import (
 	"github.com/euank/go-kmsg-parser/kmsgparser"
 	"github.com/golang/glog"
 
+	"k8s.io/node-problem-detector/pkg/checkpoint"
 	"k8s.io/node-problem-detector/pkg/systemlogmonitor/logwatchers/types"
 	logtypes "k8s.io/node-problem-detector/pkg/systemlogmonitor/types"
+	pkgtypes "k8s.io/node-problem-detector/pkg/types"
 	"k8s.io/node-problem-detector/pkg/util"
 	"k8s.io/node-problem-detector/pkg/util/tomb"
 )
@@ -34,11 +36,23 @@ import (
 type kernelLogWatcher struct {
 	cfg       types.WatcherConfig
 	startTime time.Time
-	logCh     chan *logtypes.Log
-	tomb      *tomb.Tomb
+	// position is the checkpointed position to resume after if resumed is true.
+	position kmsgPosition
+	resumed  bool
+	logCh    chan *logtypes.Log
+	tomb     *tomb.Tomb
 
 	kmsgParser kmsgparser.Parser
 	clock      utilclock.Clock
+	// heartbeat is updated whenever a kernel message is received, and at least once per
+	// types.HeartbeatInterval while waiting for kernel messages.
+	heartbeat util.HeartbeatTracker
+}
+
+// kmsgPosition is the checkpointed position of kernel log watcher.
+type kmsgPosition struct {
+	// SequenceNumber is the sequence number of the kernel message.
+	SequenceNumber int `json:"sequenceNumber"`
 }
 
 // NewKmsgWatcher creates a watcher which will read messages from /dev/kmsg
@@ -73,6 +87,8 @@ func (k *kernelLogWatcher) Watch() (<-chan *logtypes.Log, error) {
 		}
 		k.kmsgParser = parser
 	}
+	// Resume after the last kernel message processed before restart, instead of looking back.
+	k.resumed = checkpoint.GlobalWatcherCheckpointManager.Load(k.cfg.CheckpointKey, &k.position)
 
 	go k.watchLoop()
 	return k.logCh, nil
@@ -84,14 +100,26 @@ func (k *kernelLogWatcher) Stop() {
 	k.tomb.Stop()
 }
 
+// Heartbeat returns the time when a kernel message is last received, or the kernel log watcher
+// last waited for kernel messages.
+func (k *kernelLogWatcher) Heartbeat() pkgtypes.Heartbeat {
+	return k.heartbeat.Heartbeat()
+}
+
 // watchLoop is the main watch loop of kernel log watcher.
 func (k *kernelLogWatcher) watchLoop() {
+	ticker := k.clock.NewTicker(types.HeartbeatInterval)
 	defer func() {
+		ticker.Stop()
 		close(k.logCh)
 		k.tomb.Done()
 	}()
 	kmsgs := k.kmsgParser.Parse()
 
+	k.heartbeat.Beat(k.clock.Now(), "Started watching kernel messages")
+	// The first kernel message is the oldest one in the kernel ring buffer, which should be at or
+	// before the checkpoint unless the kernel messages after the checkpoint are overwritten.
+	checkGap := k.resumed
 	for {
 		select {
 		case <-k.tomb.Stopping():
@@ -100,22 +128,46 @@ func (k *kernelLogWatcher) watchLoop() {
 				glog.Errorf("Failed to close kmsg parser: %v", err)
 			}
 			return
-		case msg := <-kmsgs:
+		case <-ticker.C():
+			k.heartbeat.Beat(k.clock.Now(), "Waiting for kernel messages")
+		case msg, ok := <-kmsgs:
+			if !ok {
+				glog.Errorf("Kmsg parser stopped unexpectedly")
+				return
+			}
+			k.heartbeat.Beat(k.clock.Now(), "Received kernel message")
 			glog.V(5).Infof("got kernel message: %+v", msg)
+			if checkGap {
+				checkGap = false
+				if msg.SequenceNumber > k.position.SequenceNumber+1 {
+					glog.Warningf("Kernel messages %d to %d after checkpoint are not found, which are overwritten in the kernel ring buffer, "+
+						"resuming from the oldest kernel message %d instead", k.position.SequenceNumber+1, msg.SequenceNumber-1, msg.SequenceNumber)
+				}
+			}
 			if msg.Message == "" {
 				continue
 			}
 
-			// Discard messages before start time.
-			if msg.Timestamp.Before(k.startTime) {
+			if k.resumed {
+				// Discard messages already processed before restart.
+				if msg.SequenceNumber <= k.position.SequenceNumber {
+					glog.V(5).Infof("Throwing away msg %q before checkpoint: %d <= %d", msg.Message, msg.SequenceNumber, k.position.SequenceNumber)
+					continue
+				}
+			} else if msg.Timestamp.Before(k.startTime) {
+				// Discard messages before start time.
 				glog.V(5).Infof("Throwing away msg %q before start time: %v < %v", msg.Message, msg.Timestamp, k.startTime)
 				continue
 			}
 
-			k.logCh <- &logtypes.Log{
+			log := &logtypes.Log{
 				Message:   strings.TrimSpace(msg.Message),
 				Timestamp: msg.Timestamp,
 			}
+			if k.cfg.CheckpointKey != "" {
+				log.Position = kmsgPosition{SequenceNumber: msg.SequenceNumber}
+			}
+			k.logCh <- log
 		}
 	}
 }
@@ -17,16 +17,21 @@ limitations under the License.
 package kmsg
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"code.cloudfoundry.org/clock/fakeclock"
 	"github.com/euank/go-kmsg-parser/kmsgparser"
 	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/clock"
 
 	"time"
 
+	"k8s.io/node-problem-detector/pkg/checkpoint"
 	"k8s.io/node-problem-detector/pkg/systemlogmonitor/logwatchers/types"
 	logtypes "k8s.io/node-problem-detector/pkg/systemlogmonitor/types"
+	pkgtypes "k8s.io/node-problem-detector/pkg/types"
 	"k8s.io/node-problem-detector/pkg/util"
 )
 
@@ -171,3 +176,60 @@ func TestWatch(t *testing.T) {
 		}
 	}
 }
+
+func TestWatchResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	originalManager := checkpoint.GlobalWatcherCheckpointManager
+	defer func() {
+		checkpoint.GlobalWatcherCheckpointManager = originalManager
+	}()
+	checkpoint.GlobalWatcherCheckpointManager = checkpoint.NewWatcherCheckpointManager(dir, "boot", clock.RealClock{})
+	assert.NoError(t, checkpoint.GlobalWatcherCheckpointManager.Save("kernel-monitor.json", kmsgPosition{SequenceNumber: 2}))
+
+	now := time.Date(time.Now().Year(), time.January, 2, 3, 4, 5, 0, time.Local)
+	w := NewKmsgWatcher(types.WatcherConfig{CheckpointKey: "kernel-monitor.json"})
+	// All the messages are before start time, only the ones after the checkpoint are sent.
+	w.(*kernelLogWatcher).startTime = now
+	w.(*kernelLogWatcher).kmsgParser = &mockKmsgParser{kmsgs: []kmsgparser.Message{
+		{SequenceNumber: 1, Message: "1", Timestamp: now.Add(-4 * time.Second)},
+		{SequenceNumber: 2, Message: "2", Timestamp: now.Add(-3 * time.Second)},
+		{SequenceNumber: 3, Message: "3", Timestamp: now.Add(-2 * time.Second)},
+		{SequenceNumber: 4, Message: "4", Timestamp: now.Add(-1 * time.Second)},
+	}}
+	logCh, err := w.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The logs carry their positions, which are checkpointed once they are processed.
+	for _, expected := range []logtypes.Log{
+		{Timestamp: now.Add(-2 * time.Second), Message: "3", Position: kmsgPosition{SequenceNumber: 3}},
+		{Timestamp: now.Add(-time.Second), Message: "4", Position: kmsgPosition{SequenceNumber: 4}},
+	} {
+		got := <-logCh
+		assert.Equal(t, &expected, got)
+	}
+	w.Stop()
+}
+
+func TestHeartbeat(t *testing.T) {
+	fakeClock := fakeclock.NewFakeClock(time.Now())
+	w := NewKmsgWatcher(types.WatcherConfig{})
+	w.(*kernelLogWatcher).clock = fakeClock
+	w.(*kernelLogWatcher).kmsgParser = &mockKmsgParser{}
+	if _, err := w.Watch(); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// The kernel log watcher keeps beating while waiting for kernel messages.
+	reporter := w.(pkgtypes.HeartbeatReporter)
+	for i := 0; reporter.Heartbeat().Message != "Waiting for kernel messages"; i++ {
+		if i == 100 {
+			t.Fatalf("no heartbeat while waiting for kernel messages, got %+v", reporter.Heartbeat())
+		}
+		fakeClock.Increment(types.HeartbeatInterval)
+		time.Sleep(10 * time.Millisecond)
+	}
+}
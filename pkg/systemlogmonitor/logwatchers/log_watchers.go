@@ -30,6 +30,12 @@ func registerLogWatcher(name string, create types.WatcherCreateFunc) {
 	createFuncs[name] = create
 }
 
+// IsRegistered returns true if the log watcher plugin is registered.
+func IsRegistered(plugin string) bool {
+	_, ok := createFuncs[plugin]
+	return ok
+}
+
 // GetLogWatcherOrDie get a log watcher based on the passed in configuration.
 // The function panics when encounters an error.
 func GetLogWatcherOrDie(config types.WatcherConfig) types.LogWatcher {
@@ -17,10 +17,18 @@ limitations under the License.
 package types
 
 import (
+	"time"
+
 	"k8s.io/node-problem-detector/pkg/systemlogmonitor/types"
 )
 
-// LogWatcher is the interface of a log watcher.
+// HeartbeatInterval is the max interval between two heartbeats of a log watcher which is
+// waiting for logs, see types.HeartbeatReporter.
+const HeartbeatInterval = time.Minute
+
+// LogWatcher is the interface of a log watcher. A log watcher may also implement
+// types.HeartbeatReporter of the problem daemons, to report that it's still reading the log when
+// there is no log, so that the log monitor is not stale while the log is quiet.
 type LogWatcher interface {
 	// Watch starts watching logs and returns logs via a channel.
 	Watch() (<-chan *types.Log, error)
@@ -44,6 +52,11 @@ type WatcherConfig struct {
 	// useful when the log watcher needs to wait for some time until the node
 	// becomes stable.
 	Delay string `json:"delay,omitempty"`
+	// CheckpointKey is the key the position of the log watcher is checkpointed under. The log
+	// watcher resumes from the checkpointed position instead of looking back if there is one in
+	// the current boot, and sets the position of each log it sends. The position is not
+	// checkpointed if it is empty.
+	CheckpointKey string `json:"-"`
 }
 
 // WatcherCreateFunc is the create function of a log watcher.
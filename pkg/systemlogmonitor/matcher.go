@@ -0,0 +1,342 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package systemlogmonitor
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+
+	systemlogtypes "k8s.io/node-problem-detector/pkg/systemlogmonitor/types"
+)
+
+// Pattern is a precompiled regular expression matched against the end of the log buffer.
+type Pattern struct {
+	reg *regexp.Regexp
+	// literal is a substring which appears in every match of the pattern. The log buffer is
+	// checked for it before the regular expression is run, because that is much cheaper. It's
+	// empty if the pattern has no such substring.
+	literal string
+	// lastLineLiteral is a substring which appears in the last line of every match of the pattern.
+	// It's checked before literal, because the last line is much shorter than the log buffer.
+	lastLineLiteral string
+	// singleLine is true if the pattern can only match the last line, i.e. it can't match line
+	// breaks.
+	singleLine bool
+	// window is the time window before the last log in which the lines of the pattern are
+	// matched, or 0 if the lines are matched in the whole log buffer.
+	window time.Duration
+}
+
+// CompilePattern compiles the regular expression into a pattern, which must match to the end
+// of the log buffer.
+func CompilePattern(expr string) (*Pattern, error) {
+	// Parse the expression with the same flags as regexp.Compile first, so that the errors are
+	// about the expression itself.
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	return compilePattern(re, expr)
+}
+
+// CompileSpanPattern is the same as CompilePattern, except that the lines of the pattern don't
+// have to be adjacent. Each line break `\n` in the expression matches any logs in between, as
+// long as all the lines are logged within the window before the last log.
+func CompileSpanPattern(expr string, window time.Duration) (*Pattern, error) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	re = spanLineBreaks(re)
+	pattern, err := compilePattern(re, re.String())
+	if err != nil {
+		return nil, err
+	}
+	pattern.window = window
+	return pattern, nil
+}
+
+func compilePattern(re *syntax.Regexp, expr string) (*Pattern, error) {
+	reg, err := regexp.Compile(expr + `\z`)
+	if err != nil {
+		return nil, err
+	}
+	return &Pattern{
+		reg:             reg,
+		literal:         requiredLiteral(re),
+		lastLineLiteral: lastLineLiteral(re),
+		singleLine:      !multiLine(re),
+	}, nil
+}
+
+// lastLineLiteral returns the longest case-sensitive literal string which appears in the last
+// line of every match of the multi-line regular expression it finds, or empty if there is none.
+func lastLineLiteral(re *syntax.Regexp) string {
+	if re.Op != syntax.OpConcat {
+		return ""
+	}
+	// The parts after the last one which may match line breaks are matched in the last line.
+	last := len(re.Sub) - 1
+	for last >= 0 && !multiLine(re.Sub[last]) {
+		last--
+	}
+	if last < 0 {
+		return ""
+	}
+	longest := ""
+	if sub := re.Sub[last]; sub.Op == syntax.OpLiteral && sub.Flags&syntax.FoldCase == 0 {
+		runes := sub.Rune
+		for i, r := range runes {
+			if r == '\n' {
+				longest = string(runes[i+1:])
+			}
+		}
+	}
+	for _, sub := range re.Sub[last+1:] {
+		if literal := requiredLiteral(sub); len(literal) > len(longest) {
+			longest = literal
+		}
+	}
+	return longest
+}
+
+// interleavedLines returns a regular expression matching a line break followed by any lines.
+func interleavedLines() *syntax.Regexp {
+	re, err := syntax.Parse(`\n(?:.*\n)*?`, syntax.Perl)
+	if err != nil {
+		panic(err)
+	}
+	return re
+}
+
+// spanLineBreaks replaces the line breaks in the literals of the regular expression with
+// interleavedLines, so that any lines may be logged in between.
+func spanLineBreaks(re *syntax.Regexp) *syntax.Regexp {
+	if re.Op == syntax.OpLiteral {
+		var parts []*syntax.Regexp
+		start := 0
+		for i, r := range re.Rune {
+			if r != '\n' {
+				continue
+			}
+			if i > start {
+				parts = append(parts, &syntax.Regexp{Op: syntax.OpLiteral, Flags: re.Flags, Rune: re.Rune[start:i]})
+			}
+			parts = append(parts, interleavedLines())
+			start = i + 1
+		}
+		if len(parts) == 0 {
+			return re
+		}
+		if start < len(re.Rune) {
+			parts = append(parts, &syntax.Regexp{Op: syntax.OpLiteral, Flags: re.Flags, Rune: re.Rune[start:]})
+		}
+		return &syntax.Regexp{Op: syntax.OpConcat, Sub: parts}
+	}
+	for i, sub := range re.Sub {
+		re.Sub[i] = spanLineBreaks(sub)
+	}
+	return re
+}
+
+// multiLine returns true if the regular expression may match line breaks, or is anchored at the
+// beginning of the text.
+func multiLine(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if r == '\n' {
+				return true
+			}
+		}
+	case syntax.OpCharClass:
+		for i := 0; i < len(re.Rune); i += 2 {
+			if re.Rune[i] <= '\n' && '\n' <= re.Rune[i+1] {
+				return true
+			}
+		}
+	case syntax.OpAnyChar, syntax.OpBeginText:
+		return true
+	}
+	for _, sub := range re.Sub {
+		if multiLine(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredLiteral returns the longest case-sensitive literal string which appears in every
+// match of the regular expression it finds, or empty if there is none.
+func requiredLiteral(re *syntax.Regexp) string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase == 0 {
+			return string(re.Rune)
+		}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiteral(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return requiredLiteral(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		longest := ""
+		for _, sub := range re.Sub {
+			if literal := requiredLiteral(sub); len(literal) > len(longest) {
+				longest = literal
+			}
+		}
+		return longest
+	}
+	return ""
+}
+
+// Matcher matches the log buffer against the patterns of all the rules. The patterns are
+// compiled once when the matcher is created.
+type Matcher struct {
+	patterns []*Pattern
+	// exclusions are the exclude patterns of the rules, in the order of the rules.
+	exclusions [][]*regexp.Regexp
+	// globalExclusions are the exclude patterns of all the rules.
+	globalExclusions []*regexp.Regexp
+	// comparisons are the comparisons of the rules, in the order of the rules.
+	comparisons [][]systemlogtypes.Comparison
+}
+
+// NewMatcher compiles the patterns and the exclude patterns of the rules, and the exclude
+// patterns of all the rules, returns error if any of them or any comparison is invalid.
+func NewMatcher(rules []systemlogtypes.Rule, excludePatterns []string) (*Matcher, error) {
+	m := &Matcher{}
+	for i, expr := range excludePatterns {
+		reg, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("excludePatterns[%d]: invalid pattern %q: %v", i, expr, err)
+		}
+		m.globalExclusions = append(m.globalExclusions, reg)
+	}
+	for i, rule := range rules {
+		var exclusions []*regexp.Regexp
+		for _, expr := range rule.ExcludePatterns {
+			reg, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("rules[%d]: invalid exclude pattern %q: %v", i, expr, err)
+			}
+			exclusions = append(exclusions, reg)
+		}
+		m.exclusions = append(m.exclusions, exclusions)
+
+		var pattern *Pattern
+		var err error
+		if rule.SpanWindow > 0 {
+			pattern, err = CompileSpanPattern(rule.Pattern, rule.SpanWindow)
+		} else {
+			pattern, err = CompilePattern(rule.Pattern)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d]: invalid pattern %q: %v", i, rule.Pattern, err)
+		}
+		for j, comparison := range rule.Comparisons {
+			if err := validateComparison(pattern.reg, comparison); err != nil {
+				return nil, fmt.Errorf("rules[%d]: comparisons[%d]: %v", i, j, err)
+			}
+		}
+		m.patterns = append(m.patterns, pattern)
+		m.comparisons = append(m.comparisons, rule.Comparisons)
+	}
+	return m, nil
+}
+
+// Match matches the log buffer against the patterns of all the rules in one pass, and returns
+// the matches in the order of the rules, nil for the rules which are not matched. A match is
+// vetoed if any of the matched logs matches any exclude pattern of the rule or of all the rules,
+// or if any comparison of the rule doesn't hold. The compared values are added to the labels of
+// the match, keyed by capture group.
+func (m *Matcher) Match(buffer LogBuffer) []*LogMatch {
+	matches := make([]*LogMatch, len(m.patterns))
+	for i, pattern := range m.patterns {
+		match := buffer.FindPattern(pattern)
+		if match == nil {
+			continue
+		}
+		if excluded(match.Logs, m.exclusions[i]) || excluded(match.Logs, m.globalExclusions) {
+			glog.V(3).Infof("Match of pattern %q is excluded: %+v", pattern.reg, match.Logs)
+			continue
+		}
+		if !compareValues(match, m.comparisons[i]) {
+			continue
+		}
+		matches[i] = match
+	}
+	return matches
+}
+
+// excluded returns true if any of the logs matches any of the exclusions.
+func excluded(logs []*systemlogtypes.Log, exclusions []*regexp.Regexp) bool {
+	for _, exclusion := range exclusions {
+		for _, log := range logs {
+			if exclusion.MatchString(log.Message) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compareValues returns true if all the comparisons hold for the values of the capture groups in
+// the match, and adds the values to the labels of the match, so that they are included in the
+// problems. The labels of the named capture groups are kept.
+func compareValues(match *LogMatch, comparisons []systemlogtypes.Comparison) bool {
+	for _, comparison := range comparisons {
+		text := match.Expand("${" + comparison.Group + "}")
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			glog.V(3).Infof("Value %q of capture group %q is not a number: %v", text, comparison.Group, err)
+			return false
+		}
+		if !compare(comparison, value) {
+			return false
+		}
+		if match.Labels == nil {
+			match.Labels = make(map[string]string)
+		}
+		match.Labels[comparison.Group] = text
+	}
+	return true
+}
+
+// compare returns true if the value is within the bounds of the comparison.
+func compare(comparison systemlogtypes.Comparison, value float64) bool {
+	if comparison.GreaterThan != nil && !(value > *comparison.GreaterThan) {
+		return false
+	}
+	if comparison.GreaterThanOrEqual != nil && !(value >= *comparison.GreaterThanOrEqual) {
+		return false
+	}
+	if comparison.LessThan != nil && !(value < *comparison.LessThan) {
+		return false
+	}
+	if comparison.LessThanOrEqual != nil && !(value <= *comparison.LessThanOrEqual) {
+		return false
+	}
+	return true
+}
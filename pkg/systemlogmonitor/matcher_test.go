@@ -0,0 +1,289 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package systemlogmonitor
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	systemlogtypes "k8s.io/node-problem-detector/pkg/systemlogmonitor/types"
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+func TestCompilePattern(t *testing.T) {
+	for expr, literal := range map[string]string{
+		`Kill process \d+ \(.+\) score \d+ or sacrifice child`:          " or sacrifice child",
+		`task (?P<process>\w+):\w+ blocked for more than \w+ seconds\.`: " blocked for more than ",
+		`(?i)kernel panic`:                          "",
+		`(?:Out of memory)+`:                        "Out of memory",
+		`(?:Out of memory)?`:                        "",
+		`(?:Out of memory){2,}`:                     "Out of memory",
+		`(?:Out of memory)*`:                        "",
+		`Remounting filesystem read-only|read-only`: "",
+		`.*`: "",
+		`((Buffer I/O error)) on dev \w+, logical .*`: "Buffer I/O error",
+	} {
+		pattern, err := CompilePattern(expr)
+		assert.NoError(t, err, expr)
+		assert.Equal(t, literal, pattern.literal, expr)
+	}
+
+	_, err := CompilePattern("(unclosed")
+	assert.Error(t, err)
+}
+
+func TestLastLineLiteral(t *testing.T) {
+	for expr, literal := range map[string]string{
+		`Kill process \d+ \(.+\) score \d+ or sacrifice child\nKilled process \d+ \(.+\) total-vm:\d+kB, anon-rss:\d+kB.*`: "Killed process ",
+		`Kill process .*\nKilled process .*`: "Killed process ",
+		`Kill process .*\n(.*)`:              "",
+		`Kill process .*`:                    "",
+		`Kill process .*\n|Killed process`:   "",
+	} {
+		pattern, err := CompilePattern(expr)
+		assert.NoError(t, err, expr)
+		assert.Equal(t, literal, pattern.lastLineLiteral, expr)
+	}
+}
+
+func TestSingleLinePattern(t *testing.T) {
+	for expr, singleLine := range map[string]bool{
+		`Kill process \d+ \(.+\).*`:          true,
+		`(?m)^Killed process \d+$`:           true,
+		`Kill process .*\nKilled process .*`: false,
+		`(?s)Kill process .*`:                false,
+		`Kill process\s+\d+`:                 false,
+		`[^a]`:                               false,
+		`^Kill process`:                      false,
+		`(Kill process|Killed process\n.*)`:  false,
+	} {
+		pattern, err := CompilePattern(expr)
+		assert.NoError(t, err, expr)
+		assert.Equal(t, singleLine, pattern.singleLine, expr)
+	}
+}
+
+func TestCompileSpanPattern(t *testing.T) {
+	pattern, err := CompileSpanPattern(`Kill process (\d+).*\nKilled (process) \d+.*`, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, `(?-s:Kill process ([0-9]+).*\n(?:.*\n)*?Killed (process) [0-9]+.*)\z`, pattern.reg.String())
+	assert.Equal(t, "Kill process ", pattern.literal)
+	assert.Equal(t, "process", pattern.lastLineLiteral)
+	assert.False(t, pattern.singleLine)
+	assert.Equal(t, time.Second, pattern.window)
+
+	_, err = CompileSpanPattern("(unclosed", time.Second)
+	assert.Error(t, err)
+}
+
+func TestMatcher(t *testing.T) {
+	rules := []systemlogtypes.Rule{
+		{Type: types.Temp, Reason: "OOMKilling", Pattern: `Kill process (?P<pid>\d+) \(.+\).*`},
+		{Type: types.Temp, Reason: "KernelOops", Pattern: `BUG: unable to handle kernel .*`},
+		{Type: types.Temp, Reason: "TaskHung", Pattern: `(?i)task \w+:\w+ blocked.*`},
+	}
+	m, err := NewMatcher(rules, nil)
+	assert.NoError(t, err)
+
+	b := NewLogBuffer(2)
+	b.Push(&systemlogtypes.Log{Message: "Out of memory: Kill process 1234 (foo) score 1 or sacrifice child"})
+	matches := m.Match(b)
+	assert.Len(t, matches, 3)
+	assert.Equal(t, "Out of memory: Kill process 1234 (foo) score 1 or sacrifice child", matches[0].Logs[0].Message)
+	assert.Equal(t, map[string]string{"pid": "1234"}, matches[0].Labels)
+	assert.Nil(t, matches[1])
+	assert.Nil(t, matches[2])
+
+	b.Push(&systemlogtypes.Log{Message: "TASK docker:1234 blocked for more than 120 seconds."})
+	matches = m.Match(b)
+	assert.Nil(t, matches[0], "only the patterns matching the last log should be matched")
+	assert.Nil(t, matches[1])
+	assert.Equal(t, "TASK docker:1234 blocked for more than 120 seconds.", matches[2].Logs[0].Message)
+
+	_, err = NewMatcher([]systemlogtypes.Rule{rules[0], {Type: types.Temp, Pattern: "(unclosed"}}, nil)
+	assert.EqualError(t, err, "rules[1]: invalid pattern \"(unclosed\": error parsing regexp: missing closing ): `(unclosed`")
+}
+
+// benchmarkRules returns 40 rules, which are the rules of the kernel monitor repeated.
+func benchmarkRules(b *testing.B) []systemlogtypes.Rule {
+	config, err := readConfig("../../config/kernel-monitor.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var rules []systemlogtypes.Rule
+	for len(rules) < 40 {
+		rules = append(rules, config.Rules...)
+	}
+	return rules[:40]
+}
+
+// benchmarkLogs returns kernel logs which don't match any rule, like most of the logs.
+func benchmarkLogs(n int) []*systemlogtypes.Log {
+	var logs []*systemlogtypes.Log
+	for i := 0; i < n; i++ {
+		logs = append(logs, &systemlogtypes.Log{
+			Message: fmt.Sprintf("IPv6: ADDRCONF(NETDEV_CHANGE): veth%08x: link becomes ready", i),
+		})
+	}
+	return logs
+}
+
+// BenchmarkMatchBaseline matches each log against the rules the way log monitor did before the
+// rules were precompiled, i.e. the expression of each rule is compiled and matched against the
+// concatenated log buffer for every log. It's the baseline of BenchmarkMatcher.
+func BenchmarkMatchBaseline(b *testing.B) {
+	rules := benchmarkRules(b)
+	logs := benchmarkLogs(b.N)
+	buffer := NewLogBuffer(10)
+	b.SetBytes(int64(len(logs[0].Message)))
+	b.ResetTimer()
+	for _, log := range logs {
+		buffer.Push(log)
+		for _, rule := range rules {
+			regexp.MustCompile(rule.Pattern + `\z`).FindStringIndex(buffer.String())
+		}
+	}
+}
+
+// BenchmarkLogBufferFind matches each log against the rules by expression, which compiles the
+// expressions for every log.
+func BenchmarkLogBufferFind(b *testing.B) {
+	rules := benchmarkRules(b)
+	logs := benchmarkLogs(b.N)
+	buffer := NewLogBuffer(10)
+	b.SetBytes(int64(len(logs[0].Message)))
+	b.ResetTimer()
+	for _, log := range logs {
+		buffer.Push(log)
+		for _, rule := range rules {
+			buffer.Find(rule.Pattern)
+		}
+	}
+}
+
+// BenchmarkMatcher matches each log against the precompiled rules.
+func BenchmarkMatcher(b *testing.B) {
+	rules := benchmarkRules(b)
+	logs := benchmarkLogs(b.N)
+	buffer := NewLogBuffer(10)
+	m, err := NewMatcher(rules, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(logs[0].Message)))
+	b.ResetTimer()
+	for _, log := range logs {
+		buffer.Push(log)
+		m.Match(buffer)
+	}
+}
+
+// BenchmarkMatcherTimeWindow matches each log against the precompiled rules in a full time
+// window log buffer.
+func BenchmarkMatcherTimeWindow(b *testing.B) {
+	rules := benchmarkRules(b)
+	logs := benchmarkLogs(b.N)
+	buffer := NewTimeWindowLogBuffer(5*time.Second, defaultBufferMaxBytes)
+	for _, log := range benchmarkLogs(defaultBufferMaxBytes / len(logs[0].Message)) {
+		buffer.Push(log)
+	}
+	m, err := NewMatcher(rules, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(logs[0].Message)))
+	b.ResetTimer()
+	for _, log := range logs {
+		buffer.Push(log)
+		m.Match(buffer)
+	}
+}
+
+func TestMatcherExclusions(t *testing.T) {
+	rules := []systemlogtypes.Rule{
+		{Type: types.Temp, Reason: "TaskHung", Pattern: `task \S+:\w+ blocked for more than \w+ seconds\.`,
+			ExcludePatterns: []string{`task backup-\w+:`}},
+		{Type: types.Temp, Reason: "OOMKilling", Pattern: `Kill process \d+ \(.+\).*\nKilled process .*`},
+	}
+	m, err := NewMatcher(rules, []string{`\(test-.+\)`})
+	assert.NoError(t, err)
+
+	b := NewLogBuffer(2)
+	b.Push(&systemlogtypes.Log{Message: "task backup-db:1234 blocked for more than 120 seconds."})
+	assert.Nil(t, m.Match(b)[0], "the match should be excluded by the rule")
+	b.Push(&systemlogtypes.Log{Message: "task docker:1234 blocked for more than 120 seconds."})
+	assert.NotNil(t, m.Match(b)[0])
+
+	b.Push(&systemlogtypes.Log{Message: "Out of memory: Kill process 1234 (test-foo) score 1 or sacrifice child"})
+	b.Push(&systemlogtypes.Log{Message: "Killed process 1234 total-vm:1kB"})
+	assert.Nil(t, m.Match(b)[1], "the match should be excluded by any matched line")
+	b.Push(&systemlogtypes.Log{Message: "Out of memory: Kill process 1234 (foo) score 1 or sacrifice child"})
+	b.Push(&systemlogtypes.Log{Message: "Killed process 1234 total-vm:1kB"})
+	assert.NotNil(t, m.Match(b)[1])
+
+	_, err = NewMatcher(rules, []string{"(unclosed"})
+	assert.EqualError(t, err, "excludePatterns[0]: invalid pattern \"(unclosed\": error parsing regexp: missing closing ): `(unclosed`")
+	rules[0].ExcludePatterns = []string{"(unclosed"}
+	_, err = NewMatcher(rules, nil)
+	assert.EqualError(t, err, "rules[0]: invalid exclude pattern \"(unclosed\": error parsing regexp: missing closing ): `(unclosed`")
+}
+
+func TestMatcherComparisons(t *testing.T) {
+	threshold, low, high := 600.0, 3.0, 10.0
+	rules := []systemlogtypes.Rule{
+		{Type: types.Temp, Reason: "TaskHung", Pattern: `task (?P<process>\S+):\w+ blocked for more than (?P<seconds>\d+) seconds\.`,
+			Comparisons: []systemlogtypes.Comparison{{Group: "seconds", GreaterThan: &threshold}}},
+		{Type: types.Temp, Reason: "UnregisterNetDevice", Pattern: `unregister_netdevice: waiting for \w+ to become free. Usage count = (-?\d+)`,
+			Comparisons: []systemlogtypes.Comparison{{Group: "1", GreaterThanOrEqual: &low, LessThanOrEqual: &high}}},
+	}
+	m, err := NewMatcher(rules, nil)
+	assert.NoError(t, err)
+
+	b := NewLogBuffer(1)
+	for _, test := range []struct {
+		log     string
+		rule    int
+		matched bool
+		labels  map[string]string
+	}{
+		{log: "task docker:1234 blocked for more than 120 seconds.", rule: 0},
+		{log: "task docker:1234 blocked for more than 600 seconds.", rule: 0},
+		{log: "task docker:1234 blocked for more than 601 seconds.", rule: 0, matched: true, labels: map[string]string{"process": "docker", "seconds": "601"}},
+		{log: "unregister_netdevice: waiting for lo to become free. Usage count = 2", rule: 1},
+		{log: "unregister_netdevice: waiting for lo to become free. Usage count = 3", rule: 1, matched: true, labels: map[string]string{"1": "3"}},
+		{log: "unregister_netdevice: waiting for lo to become free. Usage count = 10", rule: 1, matched: true, labels: map[string]string{"1": "10"}},
+		{log: "unregister_netdevice: waiting for lo to become free. Usage count = 11", rule: 1},
+	} {
+		b.Push(&systemlogtypes.Log{Message: test.log})
+		match := m.Match(b)[test.rule]
+		if !test.matched {
+			assert.Nil(t, match, test.log)
+			continue
+		}
+		if assert.NotNil(t, match, test.log) {
+			assert.Equal(t, test.labels, match.Labels, test.log)
+		}
+	}
+
+	rules[1].Comparisons[0].Group = "2"
+	_, err = NewMatcher(rules, nil)
+	assert.EqualError(t, err, `rules[1]: comparisons[0]: capture group "2" is not in the pattern`)
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package systemlogmonitor
+
+import (
+	"io"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/util/clock"
+
+	"k8s.io/node-problem-detector/pkg/systemlogmonitor/logparsers"
+	logtypes "k8s.io/node-problem-detector/pkg/systemlogmonitor/types"
+	"k8s.io/node-problem-detector/pkg/types"
+	"k8s.io/node-problem-detector/pkg/util"
+	"k8s.io/node-problem-detector/pkg/util/tomb"
+)
+
+// journaldPlugin is the log watcher plugin reading the journal, which only reads the entries of
+// the configured source.
+const journaldPlugin = "journald"
+
+// ReplayOptions are the options to replay a captured log with.
+type ReplayOptions struct {
+	// Format is the format of the captured log.
+	Format logparsers.Format
+	// ParseOptions are the options to parse the captured log with. The syslog identifier is set
+	// from the journald log watcher configuration.
+	ParseOptions logparsers.Options
+	// StartTime is the time node problem detector is assumed to start at. The logs before it are
+	// only replayed within the lookback and delay of the log watcher configuration, like the log
+	// watcher does. All logs are replayed if it's zero.
+	StartTime time.Time
+}
+
+// ReplayLogs runs a log monitor with the configuration file over the captured log, and returns
+// all statuses the log monitor reports. The logs are filtered like the log watcher configured,
+// but not read by it. The initial conditions transit at the timestamp of the first log. Problem
+// metrics are not reported.
+func ReplayLogs(configPath string, r io.Reader, options ReplayOptions) ([]*types.Status, error) {
+	// The configured log watcher is not used, so it doesn't need to be supported.
+	config, err := readConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	matcher, err := NewMatcher(config.Rules, config.ExcludePatterns)
+	if err != nil {
+		return nil, err
+	}
+	enableMetricsReporting := false
+	config.EnableMetricsReporting = &enableMetricsReporting
+
+	if options.Format == logparsers.Journald && config.WatcherConfig.Plugin == journaldPlugin {
+		options.ParseOptions.SyslogIdentifier = config.WatcherConfig.PluginConfig["source"]
+	}
+	logs, err := logparsers.Parse(r, options.Format, options.ParseOptions)
+	if err != nil {
+		return nil, err
+	}
+	var startTime time.Time
+	if !options.StartTime.IsZero() {
+		uptime := options.StartTime.Sub(options.ParseOptions.BootTime)
+		startTime, err = util.GetStartTime(options.StartTime, uptime, config.WatcherConfig.Lookback, config.WatcherConfig.Delay)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fakeClock := clock.NewFakeClock(time.Time{})
+	if len(logs) != 0 {
+		fakeClock.SetTime(logs[0].Timestamp)
+	}
+	watcher := newReplayWatcher(logs, startTime)
+	l := &logMonitor{
+		configPath: configPath,
+		watcher:    watcher,
+		buffer:     newLogBuffer(config),
+		matcher:    matcher,
+		config:     config,
+		output:     make(chan *types.Status, 1000),
+		tomb:       tomb.NewTomb(),
+		clock:      fakeClock,
+	}
+	ch, err := l.Start()
+	if err != nil {
+		return nil, err
+	}
+	var statuses []*types.Status
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for status := range ch {
+			statuses = append(statuses, status)
+		}
+	}()
+	// The log channel is unbuffered, so the last log is being processed once all the logs are
+	// sent, and Stop waits until it's processed.
+	<-watcher.done
+	l.Stop()
+	<-done
+	return statuses, nil
+}
+
+// replayWatcher is the log watcher sending the captured logs to the log monitor. Like the other
+// log watchers, it discards the logs before the start time.
+type replayWatcher struct {
+	logs      []*logtypes.Log
+	startTime time.Time
+	logCh     chan *logtypes.Log
+	// done is closed once all the logs are sent.
+	done chan struct{}
+}
+
+// newReplayWatcher creates the log watcher replaying the logs after startTime.
+func newReplayWatcher(logs []*logtypes.Log, startTime time.Time) *replayWatcher {
+	return &replayWatcher{
+		logs:      logs,
+		startTime: startTime,
+		logCh:     make(chan *logtypes.Log),
+		done:      make(chan struct{}),
+	}
+}
+
+func (r *replayWatcher) Watch() (<-chan *logtypes.Log, error) {
+	go func() {
+		defer close(r.done)
+		for _, log := range r.logs {
+			if log.Timestamp.Before(r.startTime) {
+				glog.V(5).Infof("Throwing away msg %q before start time: %v < %v", log.Message, log.Timestamp, r.startTime)
+				continue
+			}
+			r.logCh <- log
+		}
+	}()
+	return r.logCh, nil
+}
+
+// Stop does nothing, the log channel is not closed so that the log monitor is only stopped by
+// ReplayLogs.
+func (r *replayWatcher) Stop() {}
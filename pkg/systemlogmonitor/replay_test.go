@@ -0,0 +1,142 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package systemlogmonitor
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/node-problem-detector/pkg/systemlogmonitor/logparsers"
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+func TestReplayLogs(t *testing.T) {
+	f, err := ioutil.TempFile("", "kernel-monitor-*.yaml")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`
+plugin: kmsg
+source: kernel-monitor
+conditions:
+- type: KernelDeadlock
+  reason: KernelHasNoDeadlock
+  message: kernel has no deadlock
+rules:
+- type: temporary
+  reason: OOMKilling
+  pattern: Kill process \d+ \(.+\).*
+- type: permanent
+  condition: KernelDeadlock
+  reason: DockerHung
+  pattern: task docker:\w+ blocked for more than \w+ seconds\.
+`)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	start := time.Unix(1000, 0)
+	log := `6,1,0,-;Kill process 1234 (java) score 1000 or sacrifice child
+6,2,1000000,-;Something irrelevant
+6,3,2000000,-;task docker:20744 blocked for more than 120 seconds.`
+	statuses, err := ReplayLogs(f.Name(), strings.NewReader(log), ReplayOptions{
+		Format:       logparsers.Kmsg,
+		ParseOptions: logparsers.Options{BootTime: start},
+	})
+	assert.NoError(t, err)
+
+	defaultCondition := types.Condition{
+		Type:       "KernelDeadlock",
+		Status:     types.False,
+		Transition: start,
+		Reason:     "KernelHasNoDeadlock",
+		Message:    "kernel has no deadlock",
+	}
+	deadlockCondition := types.Condition{
+		Type:       "KernelDeadlock",
+		Status:     types.True,
+		Transition: start.Add(2 * time.Second),
+		Reason:     "DockerHung",
+		Message:    "task docker:20744 blocked for more than 120 seconds.",
+	}
+	assert.Equal(t, []*types.Status{
+		{
+			Source:     "kernel-monitor",
+			Conditions: []types.Condition{defaultCondition},
+		},
+		{
+			Source: "kernel-monitor",
+			Events: []types.Event{{
+				Severity:  types.Warn,
+				Timestamp: start,
+				Reason:    "OOMKilling",
+				Message:   "Kill process 1234 (java) score 1000 or sacrifice child",
+			}},
+			Conditions: []types.Condition{defaultCondition},
+		},
+		{
+			Source: "kernel-monitor",
+			Events: []types.Event{{
+				Severity:  types.Info,
+				Timestamp: start.Add(2 * time.Second),
+				Reason:    "DockerHung",
+				Message:   "Node condition KernelDeadlock is now: True, reason: DockerHung",
+			}},
+			Conditions: []types.Condition{deadlockCondition},
+		},
+	}, statuses)
+}
+
+func TestReplayLogsFiltered(t *testing.T) {
+	f, err := ioutil.TempFile("", "docker-monitor-*.yaml")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	_, err = f.WriteString(`
+plugin: journald
+pluginConfig:
+  source: dockerd
+lookback: 1m
+source: docker-monitor
+rules:
+- type: temporary
+  reason: CorruptDockerImage
+  pattern: 'Error trying v2 registry: failed to register layer: .*'
+`)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	// Only the entries of the configured source within the lookback are replayed.
+	start := time.Unix(1000, 0)
+	log := `{"__REALTIME_TIMESTAMP": "1000000000", "SYSLOG_IDENTIFIER": "dockerd", "MESSAGE": "Error trying v2 registry: failed to register layer: before lookback"}
+{"__REALTIME_TIMESTAMP": "1050000000", "SYSLOG_IDENTIFIER": "containerd", "MESSAGE": "Error trying v2 registry: failed to register layer: other source"}
+{"__REALTIME_TIMESTAMP": "1060000000", "SYSLOG_IDENTIFIER": "dockerd", "MESSAGE": "Error trying v2 registry: failed to register layer: within lookback"}`
+	statuses, err := ReplayLogs(f.Name(), strings.NewReader(log), ReplayOptions{
+		Format:    logparsers.Journald,
+		StartTime: start.Add(100 * time.Second),
+	})
+	assert.NoError(t, err)
+	var messages []string
+	for _, status := range statuses {
+		for _, event := range status.Events {
+			messages = append(messages, event.Message)
+		}
+	}
+	assert.Equal(t, []string{"Error trying v2 registry: failed to register layer: within lookback"}, messages)
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package systemlogmonitor
+
+import (
+	"time"
+)
+
+// ruleCounter counts the matches of a rule in a sliding time window.
+type ruleCounter struct {
+	count  int
+	window time.Duration
+	// timestamps are the timestamps of the matches within the window, from the oldest to the
+	// newest. At most count timestamps are kept, because the older ones don't matter.
+	timestamps []time.Time
+	// triggeredReason is the reason of the condition set when the threshold is reached, it's
+	// empty if the condition is not set by the rule.
+	triggeredReason string
+}
+
+func newRuleCounter(count int, window time.Duration) *ruleCounter {
+	return &ruleCounter{
+		count:  count,
+		window: window,
+	}
+}
+
+// add records a match at timestamp, and returns true if the threshold is reached.
+func (c *ruleCounter) add(timestamp time.Time) bool {
+	c.timestamps = append(c.timestamps, timestamp)
+	if len(c.timestamps) > c.count {
+		c.timestamps = c.timestamps[len(c.timestamps)-c.count:]
+	}
+	c.prune(timestamp)
+	return len(c.timestamps) >= c.count
+}
+
+// belowThreshold returns true if the matches within the window before now are fewer than count.
+func (c *ruleCounter) belowThreshold(now time.Time) bool {
+	c.prune(now)
+	return len(c.timestamps) < c.count
+}
+
+// reset forgets all the matches.
+func (c *ruleCounter) reset() {
+	c.timestamps = nil
+}
+
+// prune drops the matches which are out of the window before now.
+func (c *ruleCounter) prune(now time.Time) {
+	i := 0
+	for i < len(c.timestamps) && now.Sub(c.timestamps[i]) >= c.window {
+		i++
+	}
+	c.timestamps = c.timestamps[i:]
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package systemlogmonitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleCounter(t *testing.T) {
+	start := time.Unix(1000, 0)
+	c := newRuleCounter(3, 10*time.Minute)
+
+	assert.False(t, c.add(start))
+	assert.False(t, c.add(start.Add(5*time.Minute)))
+	// The first match is out of the window.
+	assert.False(t, c.add(start.Add(10*time.Minute)))
+	assert.True(t, c.add(start.Add(12*time.Minute)))
+	assert.Len(t, c.timestamps, 3)
+	assert.True(t, c.add(start.Add(13*time.Minute)))
+	assert.Len(t, c.timestamps, 3, "at most count matches should be kept")
+
+	assert.False(t, c.belowThreshold(start.Add(19*time.Minute)))
+	assert.True(t, c.belowThreshold(start.Add(20*time.Minute)))
+
+	c.reset()
+	assert.False(t, c.add(start.Add(21*time.Minute)))
+}
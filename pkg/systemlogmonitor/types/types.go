@@ -27,19 +27,77 @@ import (
 type Log struct {
 	Timestamp time.Time
 	Message   string
+	// Position is the position of the log watcher right after the log. It's checkpointed once
+	// the log is processed, so that the log watcher resumes after it when node problem detector
+	// restarts. It's nil if the log watcher doesn't checkpoint its position.
+	Position interface{}
 }
 
+// Recovery is the type of the rules which reset a permanent problem condition to its default
+// reason and message, e.g. when the filesystem is remounted read-write. Only log monitor
+// supports it.
+const Recovery types.Type = "recovery"
+
 // Rule describes how log monitor should analyze the log.
 type Rule struct {
-	// Type is the type of matched problem.
+	// Type is the type of matched problem, or Recovery.
 	Type types.Type `json:"type"`
-	// Condition is the type of the condition the problem triggered. Notice that
-	// the Condition field should be set only when the problem is permanent, or
-	// else the field will be ignored.
+	// Condition is the type of the condition the problem triggered, or the condition to reset
+	// for a recovery rule. Notice that the Condition field should be set only when the problem
+	// is permanent or the rule is a recovery rule, or else the field will be ignored.
 	Condition string `json:"condition"`
 	// Reason is the short reason of the problem.
 	Reason string `json:"reason"`
+	// Severity is the severity of the event generated for the problem, default to warn. Notice
+	// that the Severity field should be set only when the problem is temporary, or else the
+	// field will be ignored.
+	Severity types.Severity `json:"severity,omitempty"`
 	// Pattern is the regular expression to match the problem in log.
 	// Notice that the pattern must match to the end of the line.
 	Pattern string `json:"pattern"`
+	// ReasonTemplate is the template of the reason, which overrides Reason if it's set. It may
+	// reference the capture groups in Pattern, e.g. $1 or ${name}. Notice that the reason should
+	// still be short, e.g. a few different values, because it's used as a metric label.
+	ReasonTemplate string `json:"reasonTemplate,omitempty"`
+	// MessageTemplate is the template of the message, which is used instead of the matched log
+	// lines if it's set. It may reference the capture groups in Pattern, e.g. $1 or ${name}.
+	MessageTemplate string `json:"messageTemplate,omitempty"`
+	// Count is the number of times the pattern must be matched within Window to report the
+	// problem. The problem is reported whenever the pattern is matched if it's not set.
+	Count int `json:"count,omitempty"`
+	// WindowString is the string of Window, e.g. "10m".
+	WindowString string `json:"window,omitempty"`
+	// Window is the sliding time window in which the matches are counted.
+	Window time.Duration `json:"-"`
+	// ClearBelowThreshold indicates whether to reset the condition once the matches within
+	// Window fall back under Count. Notice that the ClearBelowThreshold field should be set only
+	// when the problem is permanent and Count is set, or else the field will be ignored.
+	ClearBelowThreshold bool `json:"clearBelowThreshold,omitempty"`
+	// SpanWindowString is the string of SpanWindow, e.g. "5s".
+	SpanWindowString string `json:"spanWindow,omitempty"`
+	// SpanWindow is the time window in which the lines of a multi-line pattern are logged. If it's
+	// set, the lines don't have to be adjacent, i.e. each line break in the pattern also matches
+	// any logs in between.
+	SpanWindow time.Duration `json:"-"`
+	// ExcludePatterns are the regular expressions which veto a match of Pattern when any of the
+	// matched log lines also matches any of them.
+	ExcludePatterns []string `json:"excludePatterns,omitempty"`
+	// Comparisons are the numeric comparisons on the values of the capture groups in Pattern. A
+	// match of Pattern only triggers the rule if all the comparisons hold.
+	Comparisons []Comparison `json:"comparisons,omitempty"`
+}
+
+// Comparison compares the numeric value of a capture group with the bounds. A range is specified
+// with both a lower bound and an upper bound.
+type Comparison struct {
+	// Group is the name or the number of the capture group.
+	Group string `json:"group"`
+	// GreaterThan is the exclusive lower bound of the value.
+	GreaterThan *float64 `json:"greaterThan,omitempty"`
+	// GreaterThanOrEqual is the inclusive lower bound of the value.
+	GreaterThanOrEqual *float64 `json:"greaterThanOrEqual,omitempty"`
+	// LessThan is the exclusive upper bound of the value.
+	LessThan *float64 `json:"lessThan,omitempty"`
+	// LessThanOrEqual is the inclusive upper bound of the value.
+	LessThanOrEqual *float64 `json:"lessThanOrEqual,omitempty"`
 }
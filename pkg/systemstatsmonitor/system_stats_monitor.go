@@ -17,7 +17,7 @@ limitations under the License.
 package systemstatsmonitor
 
 import (
-	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"time"
 
@@ -26,6 +26,7 @@ import (
 	"k8s.io/node-problem-detector/pkg/problemdaemon"
 	ssmtypes "k8s.io/node-problem-detector/pkg/systemstatsmonitor/types"
 	"k8s.io/node-problem-detector/pkg/types"
+	"k8s.io/node-problem-detector/pkg/util"
 	"k8s.io/node-problem-detector/pkg/util/tomb"
 )
 
@@ -33,8 +34,9 @@ const SystemStatsMonitorName = "system-stats-monitor"
 
 func init() {
 	problemdaemon.Register(SystemStatsMonitorName, types.ProblemDaemonHandler{
-		CreateProblemDaemonOrDie: NewSystemStatsMonitorOrDie,
-		CmdOptionDescription:     "Set to config file paths."})
+		CreateProblemDaemon:  NewSystemStatsMonitor,
+		ValidateConfig:       ValidateConfig,
+		CmdOptionDescription: "Set to config file paths."})
 }
 
 type systemStatsMonitor struct {
@@ -43,33 +45,22 @@ type systemStatsMonitor struct {
 	diskCollector *diskCollector
 	hostCollector *hostCollector
 	tomb          *tomb.Tomb
+	// heartbeat is updated whenever the system stats are collected.
+	heartbeat util.HeartbeatTracker
 }
 
-// NewSystemStatsMonitorOrDie creates a system stats monitor.
-func NewSystemStatsMonitorOrDie(configPath string) types.Monitor {
+// NewSystemStatsMonitor creates a system stats monitor, returns error if the configuration file
+// can't be loaded.
+func NewSystemStatsMonitor(configPath string) (types.Monitor, error) {
 	ssm := systemStatsMonitor{
 		configPath: configPath,
 		tomb:       tomb.NewTomb(),
 	}
 
-	// Apply configurations.
-	f, err := ioutil.ReadFile(configPath)
-	if err != nil {
-		glog.Fatalf("Failed to read configuration file %q: %v", configPath, err)
-	}
-	err = json.Unmarshal(f, &ssm.config)
-	if err != nil {
-		glog.Fatalf("Failed to unmarshal configuration file %q: %v", configPath, err)
-	}
-
-	err = ssm.config.ApplyConfiguration()
+	var err error
+	ssm.config, err = loadConfig(configPath)
 	if err != nil {
-		glog.Fatalf("Failed to apply configuration for %q: %v", configPath, err)
-	}
-
-	err = ssm.config.Validate()
-	if err != nil {
-		glog.Fatalf("Failed to validate %s configuration %+v: %v", ssm.configPath, ssm.config, err)
+		return nil, fmt.Errorf("failed to load configuration file %q: %v", configPath, err)
 	}
 
 	if len(ssm.config.DiskConfig.MetricsConfigs) > 0 {
@@ -78,15 +69,45 @@ func NewSystemStatsMonitorOrDie(configPath string) types.Monitor {
 	if len(ssm.config.HostConfig.MetricsConfigs) > 0 {
 		ssm.hostCollector = NewHostCollectorOrDie(&ssm.config.HostConfig)
 	}
-	return &ssm
+	return &ssm, nil
+}
+
+// ValidateConfig validates the system stats monitor configuration file without creating the
+// system stats monitor.
+func ValidateConfig(configPath string) error {
+	_, err := loadConfig(configPath)
+	return err
+}
+
+// loadConfig reads the system stats monitor configuration file, applies the configurations and
+// validates it.
+func loadConfig(configPath string) (ssmtypes.SystemStatsConfig, error) {
+	var config ssmtypes.SystemStatsConfig
+	f, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return config, err
+	}
+	if err := util.UnmarshalConfig(configPath, f, &config); err != nil {
+		return config, fmt.Errorf("failed to unmarshal: %v", err)
+	}
+	if err := config.ApplyConfiguration(); err != nil {
+		return config, fmt.Errorf("failed to apply configuration: %v", err)
+	}
+	return config, config.Validate()
 }
 
 func (ssm *systemStatsMonitor) Start() (<-chan *types.Status, error) {
 	glog.Infof("Start system stats monitor %s", ssm.configPath)
+	ssm.heartbeat.Beat(time.Now(), "System stats monitor started")
 	go ssm.monitorLoop()
 	return nil, nil
 }
 
+// Heartbeat returns the time when the system stats are last collected.
+func (ssm *systemStatsMonitor) Heartbeat() types.Heartbeat {
+	return ssm.heartbeat.Heartbeat()
+}
+
 func (ssm *systemStatsMonitor) monitorLoop() {
 	defer ssm.tomb.Done()
 
@@ -98,15 +119,13 @@ func (ssm *systemStatsMonitor) monitorLoop() {
 		glog.Infof("System stats monitor stopped: %s", ssm.configPath)
 		return
 	default:
-		ssm.diskCollector.collect()
-		ssm.hostCollector.collect()
+		ssm.collect()
 	}
 
 	for {
 		select {
 		case <-runTicker.C:
-			ssm.diskCollector.collect()
-			ssm.hostCollector.collect()
+			ssm.collect()
 		case <-ssm.tomb.Stopping():
 			glog.Infof("System stats monitor stopped: %s", ssm.configPath)
 			return
@@ -114,6 +133,13 @@ func (ssm *systemStatsMonitor) monitorLoop() {
 	}
 }
 
+// collect collects all system stats.
+func (ssm *systemStatsMonitor) collect() {
+	ssm.diskCollector.collect()
+	ssm.hostCollector.collect()
+	ssm.heartbeat.Beat(time.Now(), "Collected system stats")
+}
+
 func (ssm *systemStatsMonitor) Stop() {
 	glog.Infof("Stop system stats monitor %s", ssm.configPath)
 	ssm.tomb.Stop()
@@ -20,15 +20,14 @@ import (
 	"time"
 )
 
-// The following types are used internally in problem detector. In the future this could be the
-// interface between node problem detector and other problem daemons.
+// The following types are used internally in problem detector. Status is also the schema of the
+// push API, which is the interface between node problem detector and external problem daemons.
 // We added these types because:
 // 1) The kubernetes api packages are too heavy.
 // 2) We want to make the interface independent with kubernetes api change.
 
-// Severity is the severity of the problem event. Now we only have 2 severity levels: Info and Warn,
-// which are corresponding to the current kubernetes event types. We may want to add more severity
-// levels in the future.
+// Severity is the severity of the problem event. Kubernetes only has 2 event types, so Info is
+// translated to a normal event, and the other severity levels are translated to warning events.
 type Severity string
 
 const (
@@ -36,8 +35,27 @@ const (
 	Info Severity = "info"
 	// Warn is translated to a warning event.
 	Warn Severity = "warn"
+	// Error is translated to a warning event. It's for problems which affect the workloads, e.g.
+	// a process killed by the kernel.
+	Error Severity = "error"
+	// Critical is translated to a warning event. It's for problems which affect the whole node,
+	// e.g. a kernel panic.
+	Critical Severity = "critical"
 )
 
+// Severities are all the severity levels, from the least to the most severe.
+var Severities = []Severity{Info, Warn, Error, Critical}
+
+// IsValidSeverity returns true if the severity is one of Severities.
+func IsValidSeverity(severity Severity) bool {
+	for _, s := range Severities {
+		if severity == s {
+			return true
+		}
+	}
+	return false
+}
+
 // ConditionStatus is the status of the condition.
 type ConditionStatus string
 
@@ -50,6 +68,59 @@ const (
 	Unknown ConditionStatus = "Unknown"
 )
 
+// MergePolicy decides which condition is exported when several problem daemons report the same
+// condition type.
+type MergePolicy string
+
+const (
+	// MergePolicyAnyTrue exports True if any problem daemon reports True, otherwise False if any
+	// problem daemon reports False, and Unknown only if all problem daemons report Unknown.
+	MergePolicyAnyTrue MergePolicy = "any-true"
+	// MergePolicyHighestSeverity exports the most severe condition status reported, where
+	// True is more severe than Unknown, and Unknown is more severe than False.
+	MergePolicyHighestSeverity MergePolicy = "highest-severity"
+)
+
+// MergePolicies are all supported merge policies.
+var MergePolicies = []MergePolicy{MergePolicyAnyTrue, MergePolicyHighestSeverity}
+
+// IsValidMergePolicy returns true if the merge policy is one of MergePolicies.
+func IsValidMergePolicy(policy MergePolicy) bool {
+	for _, p := range MergePolicies {
+		if policy == p {
+			return true
+		}
+	}
+	return false
+}
+
+// OverflowPolicy decides what an exporter queue does with a new status when it is full.
+type OverflowPolicy string
+
+const (
+	// OverflowPolicyBlock blocks until the exporter catches up.
+	OverflowPolicyBlock OverflowPolicy = "block"
+	// OverflowPolicyDropOldest drops the oldest status in the queue.
+	OverflowPolicyDropOldest OverflowPolicy = "drop-oldest"
+	// OverflowPolicyCoalesce merges the new status into the queued status from the same
+	// problem daemon, keeping the events of both and the conditions of the new one. The oldest
+	// status in the queue is dropped if there is no queued status from the same problem daemon.
+	OverflowPolicyCoalesce OverflowPolicy = "coalesce"
+)
+
+// OverflowPolicies are all supported overflow policies.
+var OverflowPolicies = []OverflowPolicy{OverflowPolicyBlock, OverflowPolicyDropOldest, OverflowPolicyCoalesce}
+
+// IsValidOverflowPolicy returns true if the overflow policy is one of OverflowPolicies.
+func IsValidOverflowPolicy(policy OverflowPolicy) bool {
+	for _, p := range OverflowPolicies {
+		if policy == p {
+			return true
+		}
+	}
+	return false
+}
+
 // Condition is the node condition used internally by problem detector.
 type Condition struct {
 	// Type is the condition type. It should describe the condition of node in problem. For example
@@ -63,6 +134,8 @@ type Condition struct {
 	Reason string `json:"reason"`
 	// Message is a human readable message of why node goes into this condition.
 	Message string `json:"message"`
+	// Labels are the structured details of the problem, e.g. the process or the device involved.
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 // Event is the event used internally by node problem detector.
@@ -75,6 +148,8 @@ type Event struct {
 	Reason string `json:"reason"`
 	// Message is a human readable message of why the event is generated.
 	Message string `json:"message"`
+	// Labels are the structured details of the problem, e.g. the process or the device involved.
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 // Status is the status other problem daemons should report to node problem detector.
@@ -104,17 +179,50 @@ type Monitor interface {
 	// Start starts the monitor.
 	// The Status channel is used to report problems. If the Monitor does not report any
 	// problem (i.e. metrics reporting only), the channel should be set to nil.
+	// The Status channel should be closed once the monitor is stopped.
 	Start() (<-chan *Status, error)
 	// Stop stops the monitor.
 	Stop()
 }
 
+// ConditionRestorer is implemented by monitors which are able to restore previously reported
+// conditions, e.g. to keep the node conditions when the monitor is recreated on config reload.
+type ConditionRestorer interface {
+	// RestoreConditions sets the conditions the monitor starts with. It should be called before
+	// Start. Conditions of types which are not handled by the monitor are ignored.
+	RestoreConditions([]Condition)
+}
+
+// Heartbeat is the latest sign of life of a monitor.
+type Heartbeat struct {
+	// Timestamp is the time of the latest activity of the monitor.
+	Timestamp time.Time `json:"timestamp"`
+	// Message describes the latest activity of the monitor, e.g. the last log line processed.
+	Message string `json:"message"`
+}
+
+// HeartbeatReporter is implemented by monitors which report their liveness, so that a monitor
+// which stops making progress can be detected.
+type HeartbeatReporter interface {
+	// Heartbeat returns the latest heartbeat of the monitor. It may be called concurrently while
+	// the monitor is running.
+	Heartbeat() Heartbeat
+}
+
 // Exporter exports machine health data to certain control plane.
 type Exporter interface {
 	// Export problems to the control plane.
 	ExportProblems(*Status)
 }
 
+// FlushableExporter is an exporter which exports problems asynchronously. It should be
+// flushed before node problem detector exits, so that no problem is lost.
+type FlushableExporter interface {
+	Exporter
+	// Flush blocks until all problems received so far are exported.
+	Flush()
+}
+
 // ProblemDaemonType is the type of the problem daemon.
 // One type of problem daemon may be used to initialize multiple problem daemon instances.
 type ProblemDaemonType string
@@ -126,8 +234,12 @@ type ProblemDaemonConfigPathMap map[ProblemDaemonType]*[]string
 
 // ProblemDaemonHandler represents the initialization handler for a type problem daemon.
 type ProblemDaemonHandler struct {
-	// CreateProblemDaemonOrDie initializes a problem daemon, panic if error occurs.
-	CreateProblemDaemonOrDie func(string) Monitor
+	// CreateProblemDaemon initializes a problem daemon, returns error if the problem daemon
+	// can't be created, e.g. the configuration file is invalid.
+	CreateProblemDaemon func(string) (Monitor, error)
+	// ValidateConfig loads and validates a configuration file of the problem daemon without
+	// creating the problem daemon.
+	ValidateConfig func(string) error
 	// CmdOptionDescription explains how to configure the problem daemon from command line arguments.
 	CmdOptionDescription string
 }
@@ -0,0 +1,56 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// IsConfigFile returns true if the file name has an extension of a supported configuration format.
+func IsConfigFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// UnmarshalConfig unmarshals the content of a configuration file in JSON or YAML into config.
+// The format is detected by the file extension, ".yaml" and ".yml" for YAML, ".json" for JSON.
+// For other extensions, the content is treated as JSON if it starts with "{", otherwise YAML.
+// The json tags of config are used for both formats.
+func UnmarshalConfig(path string, data []byte, config interface{}) error {
+	if isYAML(path, data) {
+		return yaml.Unmarshal(data, config)
+	}
+	return json.Unmarshal(data, config)
+}
+
+func isYAML(path string, data []byte) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	case ".json":
+		return false
+	}
+	return !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshalConfig(t *testing.T) {
+	type rule struct {
+		Reason  string `json:"reason"`
+		Pattern string `json:"pattern"`
+	}
+	type config struct {
+		Source string `json:"source"`
+		Rules  []rule `json:"rules"`
+	}
+	expected := config{
+		Source: "kernel-monitor",
+		Rules: []rule{
+			{Reason: "OOMKilling", Pattern: `Kill process \d+ (.+) score \d+ or sacrifice child\nKilled process \d+ (.+) total-vm:\d+kB, anon-rss:\d+kB, file-rss:\d+kB.*`},
+		},
+	}
+	jsonConfig := `{
+	"source": "kernel-monitor",
+	"rules": [
+		{
+			"reason": "OOMKilling",
+			"pattern": "Kill process \\d+ (.+) score \\d+ or sacrifice child\\nKilled process \\d+ (.+) total-vm:\\d+kB, anon-rss:\\d+kB, file-rss:\\d+kB.*"
+		}
+	]
+}`
+	yamlConfig := `# Comments are allowed in YAML.
+source: kernel-monitor
+rules:
+- reason: OOMKilling
+  # Patterns don't need to be escaped in block scalars.
+  pattern: |-
+    Kill process \d+ (.+) score \d+ or sacrifice child\nKilled process \d+ (.+) total-vm:\d+kB, anon-rss:\d+kB, file-rss:\d+kB.*
+`
+	for desc, test := range map[string]struct {
+		path    string
+		content string
+		isError bool
+	}{
+		"JSON detected by extension":  {path: "kernel-monitor.json", content: jsonConfig},
+		"YAML detected by extension":  {path: "kernel-monitor.yaml", content: yamlConfig},
+		"YML detected by extension":   {path: "kernel-monitor.yml", content: yamlConfig},
+		"JSON detected by content":    {path: "kernel-monitor.conf", content: jsonConfig},
+		"YAML detected by content":    {path: "kernel-monitor.conf", content: yamlConfig},
+		"YAML with JSON extension":    {path: "kernel-monitor.json", content: yamlConfig, isError: true},
+		"invalid YAML with extension": {path: "kernel-monitor.yaml", content: "source: [", isError: true},
+	} {
+		var c config
+		err := UnmarshalConfig(test.path, []byte(test.content), &c)
+		if test.isError {
+			assert.Error(t, err, desc)
+			continue
+		}
+		assert.NoError(t, err, desc)
+		assert.Equal(t, expected, c, desc)
+	}
+}
@@ -46,3 +46,16 @@ func TestConvertToAPICondition(t *testing.T) {
 		t.Errorf("expected %+v, got %+v", expected, apiCondition)
 	}
 }
+
+func TestConvertToAPIEventType(t *testing.T) {
+	for severity, expected := range map[types.Severity]string{
+		types.Info:     v1.EventTypeNormal,
+		types.Warn:     v1.EventTypeWarning,
+		types.Error:    v1.EventTypeWarning,
+		types.Critical: v1.EventTypeWarning,
+	} {
+		if eventType := ConvertToAPIEventType(severity); eventType != expected {
+			t.Errorf("severity %q: expected event type %q, got %q", severity, expected, eventType)
+		}
+	}
+}
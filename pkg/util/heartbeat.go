@@ -0,0 +1,44 @@
+/*
+Copyright 2019 The Kubernetes Authors All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/node-problem-detector/pkg/types"
+)
+
+// HeartbeatTracker tracks the latest heartbeat of a monitor. It is safe to be used concurrently.
+type HeartbeatTracker struct {
+	sync.Mutex
+	heartbeat types.Heartbeat
+}
+
+// Beat records the latest activity of the monitor.
+func (h *HeartbeatTracker) Beat(timestamp time.Time, message string) {
+	h.Lock()
+	defer h.Unlock()
+	h.heartbeat = types.Heartbeat{Timestamp: timestamp, Message: message}
+}
+
+// Heartbeat returns the latest heartbeat.
+func (h *HeartbeatTracker) Heartbeat() types.Heartbeat {
+	h.Lock()
+	defer h.Unlock()
+	return h.heartbeat
+}
@@ -17,6 +17,8 @@ package util
 
 import (
 	"fmt"
+	"io/ioutil"
+	"strings"
 	"syscall"
 	"time"
 
@@ -27,6 +29,8 @@ import (
 
 var osReleasePath = "/etc/os-release"
 
+var bootIDPath = "/proc/sys/kernel/random/boot_id"
+
 // GenerateConditionChangeEvent generates an event for condition change.
 func GenerateConditionChangeEvent(t string, status types.ConditionStatus, reason string, timestamp time.Time) types.Event {
 	return types.Event{
@@ -45,6 +49,15 @@ func GetUptimeDuration() (time.Duration, error) {
 	return time.Duration(info.Uptime) * time.Second, nil
 }
 
+// GetBootID returns the boot ID of the node, which is different after each reboot.
+func GetBootID() (string, error) {
+	data, err := ioutil.ReadFile(bootIDPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read boot ID: %v", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
 func GetStartTime(now time.Time, uptimeDuration time.Duration, lookbackStr string, delayStr string) (time.Time, error) {
 	startTime := now.Add(-uptimeDuration)
 
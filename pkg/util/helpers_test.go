@@ -197,3 +197,24 @@ func TestGetOSVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestGetBootID(t *testing.T) {
+	originalBootIDPath := bootIDPath
+	defer func() {
+		bootIDPath = originalBootIDPath
+	}()
+
+	bootIDPath = "testdata/boot-id"
+	bootID, err := GetBootID()
+	if err != nil {
+		t.Errorf("Expect to get no error, but got returned error: %v", err)
+	}
+	if bootID != "3b3e2a71-8f4c-4d6e-9f0a-5c1d2e3f4a5b" {
+		t.Errorf("Wanted: %q. \nGot: %q", "3b3e2a71-8f4c-4d6e-9f0a-5c1d2e3f4a5b", bootID)
+	}
+
+	bootIDPath = "testdata/boot-id-missing"
+	if _, err := GetBootID(); err == nil {
+		t.Errorf("Expect to get error, but got no returned error.")
+	}
+}
@@ -23,13 +23,18 @@ import (
 
 // ReturnHTTPJson generates json http response.
 func ReturnHTTPJson(w http.ResponseWriter, object interface{}) {
+	ReturnHTTPJsonWithStatus(w, http.StatusOK, object)
+}
+
+// ReturnHTTPJsonWithStatus generates json http response with the status code.
+func ReturnHTTPJsonWithStatus(w http.ResponseWriter, code int, object interface{}) {
 	data, err := json.Marshal(object)
 	if err != nil {
 		ReturnHTTPError(w, err)
 		return
 	}
 	w.Header().Set("Content-type", "application/json")
-	w.WriteHeader(http.StatusOK)
+	w.WriteHeader(code)
 	w.Write(data)
 }
 